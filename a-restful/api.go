@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,13 +9,124 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"google.golang.org/grpc"
 )
 
 // UpdateBot is request body to update robot state
 type UpdateBot struct {
-	Commands string `json:"commands"`
+	Commands string `json:"commands" description:"whitespace delimited sequence of N, S, E, W and C commands, e.g. 'N E 2(N E)'"`
+}
+
+// AddBot is request body to add a robot to the warehouse
+// - the position and priority are optional, defaulting to 0
+type AddBot struct {
+	ID       string `json:"id"`
+	X        uint   `json:"x,omitempty"`
+	Y        uint   `json:"y,omitempty"`
+	Priority int    `json:"priority,omitempty" description:"robots of lower priority yield to robots of higher priority"`
+}
+
+// HealthResponse is response body of the server health endpoint
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// StateResponse is response body of the current state of a robot
+// - Battery is only set if the warehouse models batteries
+type StateResponse struct {
+	X       uint  `json:"x"`
+	Y       uint  `json:"y"`
+	Battery *uint `json:"battery,omitempty" description:"battery level of the robot; only set if the warehouse models batteries"`
+}
+
+// newStateResponse converts a state of the robot to its response body
+func newStateResponse(robot *Bot, state RobotState) StateResponse {
+	res := StateResponse{X: state.X, Y: state.Y}
+	if robot.warehouse != nil && robot.warehouse.Battery().enabled() {
+		battery := state.Battery
+		res.Battery = &battery
+	}
+	return res
+}
+
+// TaskIDResponse is response body of a queued task
+type TaskIDResponse struct {
+	TaskID string `json:"taskID"`
+}
+
+// TaskResponse is response body of the execution status of a task
+type TaskResponse struct {
+	Task TaskStatusResponse `json:"task"`
+}
+
+// TaskStatusResponse is the execution status of a task within a TaskResponse
+type TaskStatusResponse struct {
+	ID        string `json:"id"`
+	Command   string `json:"command"`
+	Executed  bool   `json:"executed"`
+	Cancelled bool   `json:"cancelled"`
+	Success   bool   `json:"success"`
+	CreatedBy string `json:"createdBy,omitempty" description:"principal which queued the task"`
+}
+
+// newTaskStatusResponse converts a task to its execution status
+func newTaskStatusResponse(task Task) TaskStatusResponse {
+	return TaskStatusResponse{task.id, task.command, task.executed, task.cancelled, task.success, task.createdBy}
+}
+
+// TaskEvent is the body of a `task` event of a robot which executed a task; the problem details are set if the task failed
+type TaskEvent struct {
+	Robot   string             `json:"robot"`
+	Task    TaskStatusResponse `json:"task"`
+	Problem *Problem           `json:"problem,omitempty"`
+}
+
+// RobotResponse is response body of a robot operating within the warehouse
+type RobotResponse struct {
+	ID string `json:"id"`
+	X  uint   `json:"x"`
+	Y  uint   `json:"y"`
+}
+
+// Position is a cell of the warehouse
+type Position struct {
+	X uint `json:"x"`
+	Y uint `json:"y"`
+}
+
+// Layout is request and response body of the layout of the warehouse
+type Layout struct {
+	ChargingStations []Position `json:"chargingStations" description:"cells robots may charge their battery on using the C command"`
+}
+
+// newLayout returns the layout of the warehouse
+func newLayout(warehouse *RobotWarehouse) Layout {
+	layout := Layout{ChargingStations: []Position{}}
+	for _, s := range warehouse.ChargingStations() {
+		layout.ChargingStations = append(layout.ChargingStations, Position{s.X, s.Y})
+	}
+	return layout
+}
+
+// String describes the layout for the audit log, e.g. `charging stations (5, 5), (0, 9)`
+func (l Layout) String() string {
+	if len(l.ChargingStations) == 0 {
+		return "no charging stations"
+	}
+	stations := make([]string, len(l.ChargingStations))
+	for i, p := range l.ChargingStations {
+		stations[i] = fmt.Sprintf("(%d, %d)", p.X, p.Y)
+	}
+	return "charging stations " + strings.Join(stations, ", ")
+}
+
+// RobotsResponse is response body of the robots operating within the warehouse
+type RobotsResponse struct {
+	Robots []RobotResponse `json:"robots"`
 }
 
 // BodyToUpdateBot marshals request body to UpdateBot struct
@@ -28,167 +140,733 @@ func BodyToUpdateBot(reqBody io.Reader) (UpdateBot, error) {
 	return obj, nil
 }
 
-// validateCommandSequence will validate string delimited movement input
-// only `N`, `S`, `E` and `W` characters are allowed within space-delimited string
+// validateCommandSequence will validate movement input
+// only whitespace delimited `N`, `S`, `E` and `W` commands (with optional repeat counts, groups and comments - see `cmdlang`) are allowed
+// - invalid command sequences result in a `*cmdlang.Error` identifying the offending token, its position and the reason
 func validateCommandSequence(commands string) error {
-	// Check for empty string
-	trimmedCommands := strings.TrimSpace(commands)
-	if trimmedCommands == "" {
-		return errors.New("failed to execute empty commands")
+	_, err := cmdlang.Validate(commands)
+	return err
+}
+
+// ServerOption configures optional behaviour of the RobotAPIServer
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	assetsDir         string
+	validateResponses bool
+	authenticator     *Authenticator
+	auditLog          AuditLog
+	idempotencyStore  IdempotencyStore
+	corsOrigins       []string
+	grpcOptions       []grpc.ServerOption
+}
+
+// WithAssetsDir serves the frontend and swagger ui from a directory (containing `public` and `swaggerui`)
+// instead of the files embedded into the binary
+func WithAssetsDir(dir string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.assetsDir = dir
 	}
+}
 
-	// check for multiple whitespaces
-	if strings.Contains(commands, "  ") {
-		return fmt.Errorf(`invalid command '%s'; command  cannot contain multiple whitespaces`, commands)
+// WithAuditLog records operator actions (task creation and cancellation, robots added to the warehouse) in an audit log
+// - without an audit log, actions are recorded in-memory
+func WithAuditLog(log AuditLog) ServerOption {
+	return func(opts *serverOptions) {
+		opts.auditLog = log
 	}
+}
 
-	// Check for invalid command types
-	commandSeq := strings.Split(trimmedCommands, " ")
-	for _, command := range commandSeq {
-		if !strings.Contains("NEWS", command) {
-			return fmt.Errorf(`invalid command '%s', command can only be one of 'N', 'S', 'E' or 'W'`, command)
-		}
+// WithAuthenticator requires requests (other than health, spec and static files) to be authenticated, and authorizes them by role
+// - without an authenticator, requests are performed by an anonymous admin
+func WithAuthenticator(a *Authenticator) ServerOption {
+	return func(opts *serverOptions) {
+		opts.authenticator = a
+	}
+}
+
+// WithCORS allows browsers of the origins to call the API (`*` allows any origin)
+// - without CORS origins, only the subscription endpoint may be called from any origin
+func WithCORS(origins []string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.corsOrigins = origins
+	}
+}
+
+// WithIdempotencyStore stores the responses of task creation requests by `Idempotency-Key`, so retries do not queue tasks twice
+// - without a store, keys are stored in-memory for 24 hours
+func WithIdempotencyStore(store IdempotencyStore) ServerOption {
+	return func(opts *serverOptions) {
+		opts.idempotencyStore = store
 	}
+}
 
-	return nil
+// WithResponseValidation validates responses against the generated OpenAPI spec; used by tests so drift of the spec fails them
+// - responses which do not match the spec are replaced by a `500` problem details response
+func WithResponseValidation() ServerOption {
+	return func(opts *serverOptions) {
+		opts.validateResponses = true
+	}
 }
 
 // RobotAPIServer is the Restful API server exposed by robot which enables ground control station to communicate with it
+// - if the robot operates within a warehouse, the other robots of the warehouse are exposed under `/api/v1/robots`
+// - the OpenAPI spec is generated from the registered endpoints and served at `/openapi.json`; requests are validated against it
 // Note: This could require `Robot` instead of `Bot` - but `Robot` does not have the `GetTask` method - which is a requirement...
 // - requirement: "Create a RESTful API to report the command series's execution status"
-func RobotAPIServer(robot *Bot) http.Handler {
+func RobotAPIServer(robot *Bot, options ...ServerOption) http.Handler {
+	opts := serverOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if opts.auditLog == nil {
+		opts.auditLog = NewInMemoryAuditLog(nil)
+	}
+	audit := auditor{opts.auditLog}
+	if opts.idempotencyStore == nil {
+		opts.idempotencyStore = NewInMemoryIdempotencyStore(24 * time.Hour)
+	}
+
 	router := mux.NewRouter()
+	router.Use(requestIDMiddleware)
+	router.Use(robot.metrics.middleware)
+	spec := newAPISpec(opts.validateResponses)
+	if opts.authenticator != nil {
+		router.Use(opts.authenticator.middleware(spec))
+	}
+	router.Use(spec.middleware)
 
-	// static file server for frontend - NOTE: go1.6 can embed files directory into binary
+	// static files are embedded into the binary, unless overridden by a directory
+	static, err := newAssets(opts.assetsDir)
+	if err != nil {
+		log.Printf("failed to serve assets from '%s', serving embedded assets instead: %v", opts.assetsDir, err)
+		static, _ = newAssets("")
+	}
+
+	// static file server for frontend
 	log.Println(`serving frontend at "/"...`)
-	router.Handle("/", http.FileServer(http.Dir("./public")))
+	router.Handle("/", static.handler("public"))
 
-	// serve swagger ui - NOTE: go1.6 can embed files directory into binary
+	// serve swagger ui
 	log.Println(`serving open api spec at "/swaggerui/"...`)
-	swaggerui := http.StripPrefix("/swaggerui/", http.FileServer(http.Dir("./swaggerui/")))
+	swaggerui := http.StripPrefix("/swaggerui/", static.handler("swaggerui"))
 	router.PathPrefix("/swaggerui/").Handler(swaggerui)
 
+	// generated open api spec
+	spec.handle(router, endpoint{
+		method: "GET", path: "/openapi.json", tag: "Health", public: true,
+		summary:   "Open API spec of the robot server",
+		responses: map[int]content{200: {"application/json": map[string]interface{}{}}},
+	}, spec.serveSpec)
+
 	// server health endpoint
-	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy"}`))
-	}).Methods("GET")
+	spec.handle(router, endpoint{
+		method: "GET", path: "/health", tag: "Health", public: true,
+		summary:   "Robot server status",
+		responses: map[int]content{200: {"application/json": HealthResponse{}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, HealthResponse{Status: "healthy"})
+	})
+
+	// metrics of the robots (of the warehouse) and the server
+	spec.handle(router, endpoint{
+		method: "GET", path: "/metrics", tag: "Health",
+		summary:     "Prometheus metrics",
+		description: "Task outcomes, commands executed, queue depths and task latencies of the robots, server-sent events subscribers and HTTP request metrics, in the Prometheus text format.",
+		responses:   map[int]content{200: {"text/plain": ""}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		robots := []*Bot{robot}
+		if robot.warehouse != nil {
+			robots = robots[:0]
+			for _, b := range robot.warehouse.Robots() {
+				robots = append(robots, b.(*Bot))
+			}
+		}
+		w.Header().Set("Content-Type", metricsContentType)
+		robot.metrics.write(w, robots)
+	})
 
 	// Robot state
-	router.HandleFunc("/api/v1/state", func(w http.ResponseWriter, r *http.Request) {
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/state", tag: "State",
+		summary:   "Gets current robot state",
+		responses: map[int]content{200: {"application/json": StateResponse{}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
 		// TODO use request context for cancellations
-		w.Header().Set("Content-Type", "application/json")
-
-		state := robot.CurrentState()
-		fmt.Fprintf(w, `{"x": %d, "y": %d}`, state.X, state.Y)
-	}).Methods("GET")
+		writeRobotState(w, robot)
+	})
 
 	// Robot movement
-	router.HandleFunc("/api/v1/state", func(w http.ResponseWriter, r *http.Request) {
+	spec.handle(router, endpoint{
+		method: "PUT", path: "/api/v1/state", tag: "State", role: RoleOperator,
+		summary:     "Update robot state",
+		description: "Queues a task moving the robot by a sequence of commands; see the command language for repeat counts, groups and comments.",
+		request:     UpdateBot{},
+		headers:     []param{idempotencyKeyParam},
+		responses: map[int]content{
+			200: {"application/json": TaskIDResponse{}},
+			400: {problemContentType: CommandProblem{}},
+			409: {problemContentType: Problem{}},
+			422: {problemContentType: Problem{}},
+			429: {problemContentType: Problem{}},
+			503: {problemContentType: Problem{}},
+		},
+	}, idempotent(opts.idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
 		// TODO use request context for cancellations
-		w.Header().Set("Content-Type", "application/json")
-
-		body, err := BodyToUpdateBot(r.Body)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		err = validateCommandSequence(body.Commands)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		taskID, _, _ := robot.EnqueueTask(body.Commands)
+		enqueueRobotTask(w, r, robot, audit)
+	}))
 
-		fmt.Fprintf(w, `{"taskID": "%s"}`, taskID)
-	}).Methods("PUT")
+	if robot.warehouse != nil {
+		warehouseRoutes(router, spec, audit, opts.idempotencyStore, opts.corsOrigins, robot)
+	}
+	auditRoutes(router, spec, opts.auditLog)
 
 	// GetTask by id
-	router.HandleFunc("/api/v1/task/{id}", func(w http.ResponseWriter, r *http.Request) {
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/task/{id}", tag: "Task",
+		summary: "Get task execution status",
+		responses: map[int]content{
+			200: {"application/json": TaskResponse{}},
+			400: {problemContentType: Problem{}},
+			404: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
 		// TODO use request context for cancellations
 		vars := mux.Vars(r)
 		id, ok := vars["id"]
 		if !ok {
-			http.Error(w, "missing request id", http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, errors.New("missing request id"))
 			return
 		}
 
 		task, err := robot.repository.GetTask(id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, r, http.StatusNotFound, CodeTaskNotFound, err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"task":{"id":"%s","command":"%s","executed":%t,"cancelled":%t,"success":%t}}`, task.id, task.command, task.executed, task.cancelled, task.success)
-	}).Methods("GET")
+		writeJSON(w, http.StatusOK, TaskResponse{Task: newTaskStatusResponse(task)})
+	})
 
 	// Cancel Task by id
-	router.HandleFunc("/api/v1/task/{id}", func(w http.ResponseWriter, r *http.Request) {
+	// - cancelling a cancelled task succeeds, so cancellations can be retried safely
+	spec.handle(router, endpoint{
+		method: "DELETE", path: "/api/v1/task/{id}", tag: "Task", role: RoleOperator,
+		summary:     "Cancel task",
+		description: "Cancels a queued task, returning the cancelled task. Tasks which are being executed cannot be cancelled (`409`), executed tasks are gone (`410`).",
+		responses: map[int]content{
+			200: {"application/json": TaskResponse{}},
+			400: {problemContentType: Problem{}},
+			404: {problemContentType: Problem{}},
+			409: {problemContentType: Problem{}},
+			410: {problemContentType: Problem{}},
+			500: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		id, ok := vars["id"]
 		if !ok {
-			http.Error(w, "missing request id", http.StatusBadRequest)
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, errors.New("missing request id"))
 			return
 		}
 
-		err := robot.CancelTask(id)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		owner := taskOwner(robot, id)
+		err := owner.CancelTask(id)
+		switch {
+		case errors.Is(err, ErrTaskNotFound):
+			writeError(w, r, http.StatusNotFound, CodeTaskNotFound, err)
+			return
+		case errors.Is(err, ErrTaskRunning):
+			writeError(w, r, http.StatusConflict, CodeTaskRunning, err)
+			return
+		case errors.Is(err, ErrTaskFinished):
+			writeError(w, r, http.StatusGone, CodeTaskFinished, err)
+			return
+		case err != nil && !errors.Is(err, ErrTaskCancelled):
+			writeError(w, r, http.StatusInternalServerError, CodeTaskFailed, err)
 			return
 		}
 
-		// TODO return 400 for scenario where task has already been executed
+		if err == nil {
+			audit.record(r, AuditEntry{Action: AuditTaskCancel, Robot: owner.id, Task: id})
+		}
 
-		w.WriteHeader(http.StatusNoContent)
-	}).Methods("DELETE")
+		task, err := owner.repository.GetTask(id)
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeTaskNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, TaskResponse{Task: newTaskStatusResponse(task)})
+	})
 
 	// CHALLENGE
 	// HTTP2 SSE - realtime unidirectional communication
-	router.HandleFunc("/api/v1/state/subscribe", func(w http.ResponseWriter, r *http.Request) {
-		log.Println("established handshake with client...")
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/state/subscribe", tag: "State",
+		summary:     "Get real-time robot state (POC)",
+		description: "Server-sent events stream of `robotstate` (state), `roboterror` (problem details), `task` (executed tasks) and `deadlock` events; a final `shutdown` event (problem details) is sent once the tasks are drained by a server shutting down.",
+		responses: map[int]content{
+			200: {"text/event-stream": ""},
+			500: {problemContentType: Problem{}},
+		},
+		streaming: true,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, robot, opts.corsOrigins)
+	})
+
+	if len(opts.corsOrigins) > 0 {
+		return cors(opts.corsOrigins, router)
+	}
+	return router
+}
 
-		// ensure writer supports streaming
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+// warehouseRoutes registers endpoints to manage and move all robots of the warehouse of the robot of the server
+func warehouseRoutes(router *mux.Router, spec *apiSpec, audit auditor, idempotencyStore IdempotencyStore, corsOrigins []string, server *Bot) {
+	warehouse, repository := server.warehouse, server.repository
+
+	// List robots
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/robots", tag: "Robots",
+		summary:   "List robots",
+		responses: map[int]content{200: {"application/json": RobotsResponse{}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		res := RobotsResponse{Robots: []RobotResponse{}}
+		for _, robot := range warehouse.Robots() {
+			bot := robot.(*Bot)
+			state := bot.CurrentState()
+			res.Robots = append(res.Robots, RobotResponse{bot.id, state.X, state.Y})
+		}
+		writeJSON(w, http.StatusOK, res)
+	})
+
+	// Add robot to warehouse
+	spec.handle(router, endpoint{
+		method: "POST", path: "/api/v1/robots", tag: "Robots", role: RoleAdmin,
+		summary: "Add robot",
+		request: AddBot{},
+		responses: map[int]content{
+			201: {"application/json": RobotResponse{}},
+			400: {problemContentType: Problem{}},
+			409: {problemContentType: Problem{}},
+			503: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		var body AddBot
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ID == "" {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, errors.New("failed to read request body"))
+			return
+		}
+
+		bot, err := warehouse.AddRobot(body.ID, body.X, body.Y, body.Priority, repository)
+		if errors.Is(err, ErrOutOfBounds) {
+			writeError(w, r, http.StatusBadRequest, CodeOutOfBounds, err)
+			return
+		}
+		if errors.Is(err, ErrShuttingDown) {
+			writeError(w, r, http.StatusServiceUnavailable, CodeShuttingDown, err)
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusConflict, CodeRobotConflict, err)
+			return
+		}
+		go bot.listen(context.Background()) // stopped by removing the robot or shutting down the warehouse
+		audit.record(r, AuditEntry{Action: AuditRobotAdd, Robot: body.ID, Detail: fmt.Sprintf("(%d, %d)", body.X, body.Y)})
+		log.Printf("Initialising robot '%s' at (%d, %d)...", body.ID, body.X, body.Y)
+
+		writeJSON(w, http.StatusCreated, RobotResponse{body.ID, body.X, body.Y})
+	})
+
+	// Remove robot from warehouse
+	// - the robot of the server (`/api/v1/state`) cannot be removed
+	spec.handle(router, endpoint{
+		method: "DELETE", path: "/api/v1/robots/{id}", tag: "Robots", role: RoleAdmin,
+		summary:     "Remove robot",
+		description: "Shuts a robot down like the server does (queued tasks are cancelled, the task in progress finishes, subscriptions end with a `shutdown` event) and removes it, returning its final state. The task in progress is aborted if the request is cancelled.",
+		responses: map[int]content{
+			200: {"application/json": RobotResponse{}},
+			404: {problemContentType: Problem{}},
+			409: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		robot, err := warehouse.Robot(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeRobotNotFound, err)
+			return
+		}
+		if robot == server {
+			writeError(w, r, http.StatusConflict, CodeRobotConflict, fmt.Errorf("Robot with ID '%s' is the robot of the server", robot.id))
+			return
+		}
+
+		err = warehouse.RemoveRobot(r.Context(), robot.id)
+		state := robot.CurrentState()
+		detail := fmt.Sprintf("(%d, %d)", state.X, state.Y)
+		if err != nil {
+			// the robot is removed nonetheless
+			detail += fmt.Sprintf("; task in progress aborted: %v", err)
+			log.Printf("aborted task of removed robot '%s': %v", robot.id, err)
+		}
+		audit.record(r, AuditEntry{Action: AuditRobotRemove, Robot: robot.id, Detail: detail})
+		log.Printf("Removed robot '%s' at (%d, %d)", robot.id, state.X, state.Y)
+
+		writeJSON(w, http.StatusOK, RobotResponse{robot.id, state.X, state.Y})
+	})
+
+	// Layout of warehouse
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/layout", tag: "Layout",
+		summary:   "Get layout of the warehouse",
+		responses: map[int]content{200: {"application/json": Layout{}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, newLayout(warehouse))
+	})
+
+	// Change layout of warehouse
+	spec.handle(router, endpoint{
+		method: "PUT", path: "/api/v1/layout", tag: "Layout", role: RoleAdmin,
+		summary:     "Change layout of the warehouse",
+		description: "Replaces the charging stations of the warehouse; tasks in progress charge at the new stations from their next `C` command.",
+		request:     Layout{},
+		responses: map[int]content{
+			200: {"application/json": Layout{}},
+			400: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		var body Layout
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, errors.New("failed to read request body"))
+			return
+		}
+
+		stations := make([]RobotState, len(body.ChargingStations))
+		for i, p := range body.ChargingStations {
+			stations[i] = RobotState{X: p.X, Y: p.Y}
+		}
+		if err := warehouse.SetChargingStations(stations); err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeOutOfBounds, err)
+			return
+		}
+		layout := newLayout(warehouse)
+		audit.record(r, AuditEntry{Action: AuditLayoutChange, Detail: layout.String()})
+		log.Printf("Changed layout: %s", layout)
+
+		writeJSON(w, http.StatusOK, layout)
+	})
+
+	// Robot state by robot id
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/robots/{id}/state", tag: "Robots",
+		summary: "Gets current state of a robot",
+		responses: map[int]content{
+			200: {"application/json": StateResponse{}},
+			404: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		robot, err := warehouse.Robot(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeRobotNotFound, err)
+			return
+		}
+		writeRobotState(w, robot)
+	})
+
+	// Real-time robot state by robot id
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/robots/{id}/state/subscribe", tag: "Robots",
+		summary:     "Get real-time state of a robot",
+		description: "Server-sent events stream of a robot; the events of `/api/v1/state/subscribe`.",
+		responses: map[int]content{
+			200: {"text/event-stream": ""},
+			404: {problemContentType: Problem{}},
+			500: {problemContentType: Problem{}},
+		},
+		streaming: true,
+	}, func(w http.ResponseWriter, r *http.Request) {
+		robot, err := warehouse.Robot(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeRobotNotFound, err)
+			return
+		}
+		streamEvents(w, r, robot, corsOrigins)
+	})
+
+	// Robot movement by robot id
+	spec.handle(router, endpoint{
+		method: "PUT", path: "/api/v1/robots/{id}/state", tag: "Robots", role: RoleOperator,
+		summary: "Update state of a robot",
+		request: UpdateBot{},
+		headers: []param{idempotencyKeyParam},
+		responses: map[int]content{
+			200: {"application/json": TaskIDResponse{}},
+			400: {problemContentType: CommandProblem{}},
+			404: {problemContentType: Problem{}},
+			409: {problemContentType: Problem{}},
+			422: {problemContentType: Problem{}},
+			429: {problemContentType: Problem{}},
+			503: {problemContentType: Problem{}},
+		},
+	}, idempotent(idempotencyStore, func(w http.ResponseWriter, r *http.Request) {
+		robot, err := warehouse.Robot(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeRobotNotFound, err)
+			return
+		}
+		enqueueRobotTask(w, r, robot, audit)
+	}))
+
+	// Simulate robot movement by robot id (dry-run) without queueing a task
+	spec.handle(router, endpoint{
+		method: "POST", path: "/api/v1/robots/{id}/plan", tag: "Robots", role: RoleOperator,
+		summary:     "Plan robot task (dry-run)",
+		description: "Simulates a task against the current state of the warehouse (including queued tasks of other robots) without queueing it; the collision policy of the warehouse applies to the cells other robots are expected to occupy.",
+		request:     UpdateBot{},
+		responses: map[int]content{
+			200: {"application/json": PlanResponse{}},
+			400: {problemContentType: CommandProblem{}},
+			404: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		robot, err := warehouse.Robot(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, CodeRobotNotFound, err)
 			return
 		}
 
-		// set SSE headers
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
+		body, err := BodyToUpdateBot(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err)
+			return
+		}
+		if err := validateCommandSequence(body.Commands); err != nil {
+			writeCommandProblem(w, r, err)
+			return
+		}
+
+		plan, err := warehouse.Plan(robot, body.Commands)
+		if err != nil {
+			writeCommandProblem(w, r, err)
+			return
+		}
+
+		res := PlanResponse{
+			Robot:               plan.RobotID,
+			Success:             plan.Failure == nil,
+			Final:               newPlanStateResponse(robot, plan.Final),
+			EstimatedDuration:   plan.Duration.String(),
+			EstimatedDurationMs: plan.Duration.Milliseconds(),
+		}
+		for _, state := range plan.Path {
+			res.Path = append(res.Path, newPlanStateResponse(robot, state))
+		}
+		if f := plan.Failure; f != nil {
+			res.Failure = &PlanFailureResponse{f.Index, string(f.Command), f.X, f.Y, f.BlockingRobotID, f.Err.Error()}
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+// PlanResponse is response body of a simulated (dry-run) task
+type PlanResponse struct {
+	Robot               string               `json:"robot"`
+	Success             bool                 `json:"success"`
+	Path                []PlanStateResponse  `json:"path"`
+	Final               PlanStateResponse    `json:"final"`
+	EstimatedDuration   string               `json:"estimatedDuration"`
+	EstimatedDurationMs int64                `json:"estimatedDurationMs"`
+	Failure             *PlanFailureResponse `json:"failure,omitempty"`
+}
+
+// PlanStateResponse is a robot position within a PlanResponse
+// - Battery is only set if the warehouse models batteries
+type PlanStateResponse struct {
+	X       uint  `json:"x"`
+	Y       uint  `json:"y"`
+	Battery *uint `json:"battery,omitempty" description:"battery level of the robot; only set if the warehouse models batteries"`
+}
+
+// newPlanStateResponse converts a planned state of the robot to its response body
+func newPlanStateResponse(robot *Bot, state RobotState) PlanStateResponse {
+	res := newStateResponse(robot, state)
+	return PlanStateResponse{X: res.X, Y: res.Y, Battery: res.Battery}
+}
+
+// PlanFailureResponse is the first failing command within a PlanResponse
+type PlanFailureResponse struct {
+	Index         int    `json:"index"`
+	Command       string `json:"command"`
+	X             uint   `json:"x"`
+	Y             uint   `json:"y"`
+	BlockingRobot string `json:"blockingRobot,omitempty"`
+	Error         string `json:"error"`
+}
+
+// streamEvents streams the events of a robot as server-sent events until the client disconnects or the robot is shut down
+// - the response header is flushed once the robot is subscribed to, so clients can rely on receiving the events of tasks queued afterwards
+func streamEvents(w http.ResponseWriter, r *http.Request, robot *Bot, corsOrigins []string) {
+	log.Println("established handshake with client...")
+
+	// ensure writer supports streaming
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, CodeStreamingUnsupported, errors.New("streaming unsupported"))
+		return
+	}
+
+	// set SSE headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	if len(corsOrigins) == 0 {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
 
-		// TODO - gracefully handle client disconnections
-		// - this is just a POC to demonstrate real-time updates to single client using SSE)
-
-		// enqueue empty task to hook into state and error channels
-		_, stateCh, errorsCh := robot.EnqueueTask("")
-
-		// Event stream format/spec: https://developer.mozilla.org/en-US/docs/Web/API/Server-sent_events/Using_server-sent_events#Event_stream_format
-		for {
-			select {
-			case state := <-stateCh:
-				log.Printf("SSE recieving - robot state %v", state)
-				fmt.Fprint(w, "event: robotstate\n")
-				fmt.Fprintf(w, `data: {"x": %d, "y": %d}%s`, state.X, state.Y, "\n")
-				fmt.Fprint(w, "\n")
-				flusher.Flush()
-			case err := <-errorsCh:
-				log.Printf("SSE recieving - error %v", err)
-				fmt.Fprint(w, "event: roboterror\n")
-				fmt.Fprintf(w, `data: %s%s`, err.Error(), "\n")
-				fmt.Fprint(w, "\n")
-				flusher.Flush()
-			case <-r.Context().Done():
+	robot.metrics.subscribed(robot.id, 1)
+	defer robot.metrics.subscribed(robot.id, -1)
+
+	// every subscriber receives every event of the robot; robots operating within a warehouse additionally report deadlocks with other robots
+	events, unsubscribe := robot.events.subscribe()
+	defer unsubscribe()
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Event stream format/spec: https://developer.mozilla.org/en-US/docs/Web/API/Server-sent_events/Using_server-sent_events#Event_stream_format
+	for {
+		select {
+		case event, ok := <-events:
+			// the stream ends with a `shutdown` event, so subscribers can reconnect once the server is up again; it ends
+			// without one if the subscriber fell behind, so it can resubscribe and resync (e.g. get the status of its tasks)
+			if !ok {
 				return
 			}
+			log.Printf("SSE sending - %s event", event.Name)
+			writeEvent(w, event.Name, eventBody(robot, event))
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
 		}
-	}).Methods("GET")
+	}
+}
 
-	return router
+// writeRobotState writes the current state of the robot as the response
+func writeRobotState(w http.ResponseWriter, robot *Bot) {
+	writeJSON(w, http.StatusOK, newStateResponse(robot, robot.CurrentState()))
+}
+
+// enqueueRobotTask validates the command sequence of the request body and queues it as a task of the robot
+func enqueueRobotTask(w http.ResponseWriter, r *http.Request, robot *Bot, audit auditor) {
+	body, err := BodyToUpdateBot(r.Body)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, err)
+		return
+	}
+
+	err = validateCommandSequence(body.Commands)
+	if err != nil {
+		writeCommandProblem(w, r, err)
+		return
+	}
+
+	taskID, _, _, err := robot.enqueue(body.Commands, PrincipalFrom(r.Context()).Name)
+	if errors.Is(err, ErrQueueFull) {
+		writeError(w, r, http.StatusTooManyRequests, CodeQueueFull, err)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusServiceUnavailable, CodeShuttingDown, err)
+		return
+	}
+	audit.record(r, AuditEntry{Action: AuditTaskCreate, Robot: robot.id, Task: taskID, Detail: body.Commands})
+
+	writeJSON(w, http.StatusOK, TaskIDResponse{taskID})
+}
+
+// taskOwner returns the robot of the warehouse executing a task; tasks of other robots are cancelled by the robot executing them
+// - defaults to the robot itself, e.g. if the task does not exist
+func taskOwner(robot *Bot, taskID string) *Bot {
+	if robot.warehouse != nil {
+		if bot, ok := robot.warehouse.owner(taskID); ok {
+			return bot
+		}
+	}
+	return robot
+}
+
+// writeJSON writes a value as a JSON response body
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	writeBody(w, "application/json", status, v)
+}
+
+// writeBody writes a value as a JSON encoded response body of the content type
+// - values are encoded before any header is written, so encoding errors result in a `500` rather than a truncated body
+func writeBody(w http.ResponseWriter, contentType string, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("failed to encode response body: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// eventBody is the body of a robot event; errors are problem details
+func eventBody(robot *Bot, event RobotEvent) interface{} {
+	switch event.Name {
+	case EventState:
+		return newStateResponse(robot, event.State)
+	case EventDeadlock:
+		return event.Deadlock
+	case EventShutdown:
+		return newProblem(CodeShuttingDown, 0, event.Err)
+	case EventTask:
+		body := TaskEvent{Robot: robot.id, Task: newTaskStatusResponse(event.Task)}
+		if event.Err != nil {
+			problem := taskProblem(event.Err)
+			body.Problem = &problem
+		}
+		return body
+	}
+	return taskProblem(event.Err)
+}
+
+// writeEvent writes a value as a JSON encoded server-sent event
+func writeEvent(w io.Writer, event string, v interface{}) {
+	data, _ := json.Marshal(v)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// cors allows browsers of the origins (`*` allows any origin) to call the API; preflight requests are answered before routing
+func cors(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		allowed := false
+		for _, o := range origins {
+			allowed = allowed || o == "*" || o == origin
+		}
+		if !allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, Idempotency-Key")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
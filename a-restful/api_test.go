@@ -2,18 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func getHTTPHandler() http.Handler {
 	robot := NewBot(0, 0, NewInMemoryDB())
-	go robot.listen()
-	handler := RobotAPIServer(&robot)
+	go robot.listen(context.Background())
+	handler := RobotAPIServer(&robot, WithResponseValidation())
 	return handler
 }
 
@@ -126,17 +128,25 @@ func TestMoveRobotEndpointInvalidCommands(t *testing.T) {
 	}
 
 	// check content type
-	contentTypeWant := "text/plain; charset=utf-8"
+	contentTypeWant := "application/problem+json"
 	contentTypeGot := rr.Result().Header.Get("Content-Type")
 	if contentTypeWant != contentTypeGot {
 		t.Errorf(`incorrect content type header response; want: "%s", got: "%s"`, contentTypeWant, contentTypeGot)
 	}
 
 	// check response
-	resWant := `invalid command 'A', command can only be one of 'N', 'S', 'E' or 'W'`
-	resGot := strings.TrimSpace(rr.Body.String())
-	if resWant != resGot {
-		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
+	var problem CommandProblem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+
+	resWant := `column 7: invalid command 'A', command can only be one of 'N', 'S', 'E', 'W' or 'C'`
+	if resWant != problem.Detail {
+		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, problem.Detail)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Instance != "/api/v1/state" {
+		t.Errorf("incorrect problem status or instance; got: %+v", problem)
+	}
+	if problem.Reason != "invalid-command" || problem.Token != "A" || problem.Offset != 6 || problem.Column != 7 {
+		t.Errorf("problem should identify token `A` at offset 6 (column 7); got: %+v", problem)
 	}
 }
 
@@ -159,25 +169,30 @@ func TestMoveRobotEndpointEmptyCommands(t *testing.T) {
 	}
 
 	// check content type
-	contentTypeWant := "text/plain; charset=utf-8"
+	contentTypeWant := "application/problem+json"
 	contentTypeGot := rr.Result().Header.Get("Content-Type")
 	if contentTypeWant != contentTypeGot {
 		t.Errorf(`incorrect content type header response; want: "%s", got: "%s"`, contentTypeWant, contentTypeGot)
 	}
 
 	// check response
-	resWant := `failed to execute empty commands`
-	resGot := strings.TrimSpace(rr.Body.String())
-	if resWant != resGot {
-		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
+	var problem CommandProblem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+
+	resWant := `column 2: failed to execute empty commands`
+	if resWant != problem.Detail {
+		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, problem.Detail)
+	}
+	if problem.Reason != "empty-sequence" {
+		t.Errorf("incorrect problem reason; want: empty-sequence, got: %s", problem.Reason)
 	}
 }
 
-func TestMoveRobotEndpointMultipleWhitespaceCommands(t *testing.T) {
+func TestMoveRobotEndpointMultiLetterCommands(t *testing.T) {
 	handler := getHTTPHandler()
 	rr := httptest.NewRecorder()
 
-	req, err := http.NewRequest("PUT", "/api/v1/state", bytes.NewBuffer([]byte(`{"commands":"N  E N"}`)))
+	req, err := http.NewRequest("PUT", "/api/v1/state", bytes.NewBuffer([]byte(`{"commands":"N EW N"}`)))
 	if err != nil {
 		t.Error(err)
 	}
@@ -191,18 +206,36 @@ func TestMoveRobotEndpointMultipleWhitespaceCommands(t *testing.T) {
 		t.Errorf("handler returned wrong status code; want %v, got %v", statusWant, statusGot)
 	}
 
-	// check content type
-	contentTypeWant := "text/plain; charset=utf-8"
-	contentTypeGot := rr.Result().Header.Get("Content-Type")
-	if contentTypeWant != contentTypeGot {
-		t.Errorf(`incorrect content type header response; want: "%s", got: "%s"`, contentTypeWant, contentTypeGot)
+	// check response
+	var problem CommandProblem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+
+	resWant := `column 3: invalid command 'EW', commands must be delimited by whitespace`
+	if resWant != problem.Detail {
+		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, problem.Detail)
+	}
+	if problem.Reason != "multi-letter-command" || problem.Token != "EW" {
+		t.Errorf("problem should identify multi-letter token `EW`; got: %+v", problem)
 	}
+}
 
-	// check response
-	resWant := `invalid command 'N  E N'; command  cannot contain multiple whitespaces`
-	resGot := strings.TrimSpace(rr.Body.String())
-	if resWant != resGot {
-		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
+// TestMoveRobotEndpointMultipleWhitespaceCommands ensures runs of whitespace (spaces, tabs and newlines) are treated consistently as delimiters
+func TestMoveRobotEndpointMultipleWhitespaceCommands(t *testing.T) {
+	handler := getHTTPHandler()
+	rr := httptest.NewRecorder()
+
+	req, err := http.NewRequest("PUT", "/api/v1/state", bytes.NewBuffer([]byte(`{"commands":"N  E\t\tN\n E"}`)))
+	if err != nil {
+		t.Error(err)
+	}
+
+	handler.ServeHTTP(rr, req)
+
+	// check response status code
+	statusWant := http.StatusOK
+	statusGot := rr.Code
+	if statusWant != statusGot {
+		t.Errorf("handler returned wrong status code; want %v, got %v", statusWant, statusGot)
 	}
 }
 
@@ -255,6 +288,54 @@ func TestGetTaskEndpointSuccess(t *testing.T) {
 	}
 }
 
+func TestGetTaskEndpointEscapesCommand(t *testing.T) {
+	handler := getHTTPHandler()
+	commands := "N # say \"hi\"\nE"
+
+	body, _ := json.Marshal(UpdateBot{Commands: commands})
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBuffer(body))
+	handler.ServeHTTP(rr, req)
+
+	var taskIDResponse TaskIDResponse
+	json.Unmarshal(rr.Body.Bytes(), &taskIDResponse)
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/task/%s", taskIDResponse.TaskID), nil)
+	handler.ServeHTTP(rr, req)
+
+	var res TaskResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("response must be valid JSON; got: %s", rr.Body.String())
+	}
+	if res.Task.Command != commands {
+		t.Errorf("incorrect task command; want: %q, got: %q", commands, res.Task.Command)
+	}
+}
+
+func TestTaskProblem(t *testing.T) {
+	tests := []struct {
+		err  error
+		code ErrorCode
+	}{
+		{fmt.Errorf(`command 'N' of "N" %w`, ErrOutOfBounds), CodeOutOfBounds},
+		{&CollisionError{RobotID: "r1", BlockingRobotID: "r2"}, CodeCollision},
+		{&DeadlockError{RobotID: "r1", RobotIDs: []string{"r1", "r2"}}, CodeDeadlock},
+		{fmt.Errorf("robot 'r1' %w", ErrBatteryDepleted), CodeBatteryDepleted},
+		{fmt.Errorf("robot 'r1' %w", ErrNoChargingStation), CodeNoChargingStation},
+		{fmt.Errorf("unknown"), CodeTaskFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("test %s error", tt.code), func(t *testing.T) {
+			problem := taskProblem(tt.err)
+			if problem.Code != tt.code || problem.Detail != tt.err.Error() || problem.Status != 0 {
+				t.Errorf("incorrect task problem; got: %+v", problem)
+			}
+		})
+	}
+}
+
 func TestGetTaskEndpointNotFound(t *testing.T) {
 	handler := getHTTPHandler()
 	rr := httptest.NewRecorder()
@@ -274,8 +355,13 @@ func TestGetTaskEndpointNotFound(t *testing.T) {
 	}
 
 	// check response
+	var problem Problem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+	if problem.Code != CodeTaskNotFound {
+		t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeTaskNotFound, problem.Code)
+	}
 	resWant := `Task with ID 'non-existent' not found`
-	resGot := strings.TrimSpace(rr.Body.String())
+	resGot := problem.Detail
 	if resWant != resGot {
 		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
 	}
@@ -287,7 +373,7 @@ func TestGetTaskEndpointNotFound(t *testing.T) {
 func TestDeleteTaskEndpointSuccess(t *testing.T) {
 	robot := NewBot(0, 0, NewInMemoryDB())
 	go func() { <-robot.tasks }() // prevent channel blocking
-	handler := RobotAPIServer(&robot)
+	handler := RobotAPIServer(&robot, WithResponseValidation())
 
 	rr := httptest.NewRecorder()
 
@@ -309,11 +395,83 @@ func TestDeleteTaskEndpointSuccess(t *testing.T) {
 	handler.ServeHTTP(rr, req)
 
 	// check response status code
-	statusWant := http.StatusNoContent
+	statusWant := http.StatusOK
 	statusGot := rr.Code
 	if statusWant != statusGot {
 		t.Errorf("handler returned wrong status code; want %v, got %v", statusWant, statusGot)
 	}
+
+	// check response contains the cancelled task
+	var res TaskResponse
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	if res.Task.ID != taskID || !res.Task.Cancelled {
+		t.Errorf("response must contain the cancelled task; got: %+v", res.Task)
+	}
+
+	t.Run("test repeated cancel succeeds", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/task/%s", taskID), nil)
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+	})
+}
+
+func TestDeleteTaskEndpointConflicts(t *testing.T) {
+	robot := NewBot(0, 0, NewInMemoryDB())
+	go func() {
+		for range robot.tasks {
+		}
+	}() // prevent channel blocking
+	handler := RobotAPIServer(&robot, WithResponseValidation())
+
+	tests := []struct {
+		name   string
+		setup  func(taskID string)
+		status int
+		code   ErrorCode
+	}{
+		{
+			name: "running task",
+			setup: func(taskID string) {
+				robot.running = taskID
+			},
+			status: http.StatusConflict,
+			code:   CodeTaskRunning,
+		},
+		{
+			name: "executed task",
+			setup: func(taskID string) {
+				task, _ := robot.repository.GetTask(taskID)
+				task.executed = true
+				robot.repository.UpdateTask(task)
+			},
+			status: http.StatusGone,
+			code:   CodeTaskFinished,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("test %s cannot be cancelled", tt.name), func(t *testing.T) {
+			taskID, _, _ := robot.EnqueueTask("N E")
+			tt.setup(taskID)
+
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/task/%s", taskID), nil)
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+			var problem Problem
+			json.Unmarshal(rr.Body.Bytes(), &problem)
+			if problem.Code != tt.code {
+				t.Errorf(`incorrect error code; want: "%s", got: "%s"`, tt.code, problem.Code)
+			}
+		})
+	}
 }
 
 func TestDeleteTaskEndpointNotFound(t *testing.T) {
@@ -335,9 +493,448 @@ func TestDeleteTaskEndpointNotFound(t *testing.T) {
 	}
 
 	// check response
+	var problem Problem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+	if problem.Code != CodeTaskNotFound {
+		t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeTaskNotFound, problem.Code)
+	}
 	resWant := `Task with ID 'non-existent' not found`
-	resGot := strings.TrimSpace(rr.Body.String())
+	resGot := problem.Detail
 	if resWant != resGot {
 		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
 	}
 }
+
+func getWarehouseHTTPHandler() http.Handler {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	return RobotAPIServer(robot, WithResponseValidation())
+}
+
+func TestListRobotsEndpoint(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/v1/robots", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(rr, req)
+
+	// check response status code
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// check response
+	resWant := `{"robots":[{"id":"r1","x":0,"y":0}]}`
+	resGot := rr.Body.String()
+	if resWant != resGot {
+		t.Errorf(`incorrect robots response; want: "%s", got: "%s"`, resWant, resGot)
+	}
+}
+
+func TestAddRobotEndpoint(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+
+	t.Run("test successfully adds robot", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/robots", bytes.NewBuffer([]byte(`{"id":"r2","x":1,"y":0}`)))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusCreated {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
+		}
+	})
+
+	t.Run("test fails to add robot on occupied cell", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/robots", bytes.NewBuffer([]byte(`{"id":"r3","x":1,"y":0}`)))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+		}
+
+		var problem Problem
+		json.Unmarshal(rr.Body.Bytes(), &problem)
+		if problem.Code != CodeRobotConflict {
+			t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeRobotConflict, problem.Code)
+		}
+		resWant := `Robot position (1, 0) is occupied by robot 'r2'`
+		resGot := problem.Detail
+		if resWant != resGot {
+			t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
+		}
+	})
+
+	t.Run("test successfully moves added robot", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/robots/r2/state", bytes.NewBuffer([]byte(`{"commands":"N E"}`)))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var responseBody map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &responseBody)
+		if _, ok := responseBody["taskID"]; !ok {
+			t.Error("response must contain `taskID`")
+		}
+	})
+}
+
+func TestRemoveRobotEndpoint(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	req, _ := http.NewRequest("POST", "/api/v1/robots", bytes.NewBuffer([]byte(`{"id":"r2","x":1,"y":0}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	t.Run("test successfully removes robot", func(t *testing.T) {
+		rr := serve("DELETE", "/api/v1/robots/r2")
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		resWant := `{"id":"r2","x":1,"y":0}`
+		if resGot := rr.Body.String(); resWant != resGot {
+			t.Errorf(`incorrect robot response; want: "%s", got: "%s"`, resWant, resGot)
+		}
+		if status := serve("GET", "/api/v1/robots/r2/state").Code; status != http.StatusNotFound {
+			t.Errorf("removed robot should not be found: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("test fails to remove unknown robot", func(t *testing.T) {
+		if status := serve("DELETE", "/api/v1/robots/r2").Code; status != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+		}
+	})
+
+	t.Run("test fails to remove robot of the server", func(t *testing.T) {
+		rr := serve("DELETE", "/api/v1/robots/r1")
+		if status := rr.Code; status != http.StatusConflict {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusConflict)
+		}
+		var problem Problem
+		json.Unmarshal(rr.Body.Bytes(), &problem)
+		if problem.Code != CodeRobotConflict {
+			t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeRobotConflict, problem.Code)
+		}
+	})
+}
+
+func TestLayoutEndpoints(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/v1/layout", bytes.NewBufferString(body))
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("test layout without charging stations", func(t *testing.T) {
+		resWant := `{"chargingStations":[]}`
+		if resGot := serve("GET", "").Body.String(); resWant != resGot {
+			t.Errorf(`incorrect layout response; want: "%s", got: "%s"`, resWant, resGot)
+		}
+	})
+
+	t.Run("test successfully changes layout", func(t *testing.T) {
+		rr := serve("PUT", `{"chargingStations":[{"x":5,"y":5},{"x":0,"y":9}]}`)
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		resWant := `{"chargingStations":[{"x":5,"y":5},{"x":0,"y":9}]}`
+		if resGot := serve("GET", "").Body.String(); resWant != resGot {
+			t.Errorf(`incorrect layout response; want: "%s", got: "%s"`, resWant, resGot)
+		}
+	})
+
+	t.Run("test fails to change layout outside of warehouse", func(t *testing.T) {
+		rr := serve("PUT", `{"chargingStations":[{"x":10,"y":0}]}`)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+		var problem Problem
+		json.Unmarshal(rr.Body.Bytes(), &problem)
+		if problem.Code != CodeOutOfBounds {
+			t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeOutOfBounds, problem.Code)
+		}
+	})
+}
+
+func TestGetRobotStateByIDEndpointNotFound(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/v1/robots/r9/state", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler.ServeHTTP(rr, req)
+
+	// check response status code
+	statusWant := http.StatusNotFound
+	statusGot := rr.Code
+	if statusWant != statusGot {
+		t.Errorf("handler returned wrong status code; want %v, got %v", statusWant, statusGot)
+	}
+
+	// check response
+	var problem Problem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+	if problem.Code != CodeRobotNotFound {
+		t.Errorf(`incorrect error code; want: "%s", got: "%s"`, CodeRobotNotFound, problem.Code)
+	}
+	resWant := `Robot with ID 'r9' not found`
+	resGot := problem.Detail
+	if resWant != resGot {
+		t.Errorf(`incorrect error response; want: "%s", got: "%s"`, resWant, resGot)
+	}
+}
+
+func TestStateResponseBattery(t *testing.T) {
+	t.Run("test battery is omitted without battery model", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		if data, _ := json.Marshal(newStateResponse(r1, r1.CurrentState())); string(data) != `{"x":0,"y":0}` {
+			t.Errorf("incorrect state response; got: %s, want: %s", data, `{"x":0,"y":0}`)
+		}
+	})
+
+	t.Run("test battery is included with battery model", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 20, MoveCost: 1})
+		r1, _ := warehouse.AddRobot("r1", 1, 2, 0, NewInMemoryDB())
+		if data, _ := json.Marshal(newStateResponse(r1, r1.CurrentState())); string(data) != `{"x":1,"y":2,"battery":20}` {
+			t.Errorf("incorrect state response; got: %s, want: %s", data, `{"x":1,"y":2,"battery":20}`)
+		}
+	})
+}
+
+func TestSubscribeRobotEndpoint(t *testing.T) {
+	server := httptest.NewServer(getWarehouseHTTPHandler())
+	defer server.Close()
+
+	t.Run("test unknown robot", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/api/v1/robots/r9/state/subscribe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("handler returned wrong status code: got %v want %v", res.StatusCode, http.StatusNotFound)
+		}
+	})
+
+	t.Run("test events of tasks queued once subscribed", func(t *testing.T) {
+		// the response header is received once the robot is subscribed to
+		res, err := http.Get(server.URL + "/api/v1/robots/r1/state/subscribe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); res.StatusCode != http.StatusOK || ct != "text/event-stream" {
+			t.Fatalf("incorrect response; got: %d %s", res.StatusCode, ct)
+		}
+
+		req, _ := http.NewRequest("PUT", server.URL+"/api/v1/robots/r1/state", strings.NewReader(`{"commands":"N"}`))
+		if _, err := http.DefaultClient.Do(req); err != nil {
+			t.Fatal(err)
+		}
+
+		buf := make([]byte, 4096)
+		var body string
+		for !strings.Contains(body, "event: task\n") {
+			n, err := res.Body.Read(buf)
+			if err != nil {
+				t.Fatalf("stream ended before task event; got: %s", body)
+			}
+			body += string(buf[:n])
+		}
+		if !strings.HasPrefix(body, "event: robotstate\ndata: {\"x\":0,\"y\":1}\n\n") || !strings.Contains(body, `"robot":"r1"`) {
+			t.Errorf("incorrect events; got: %s", body)
+		}
+	})
+}
+
+func TestPlanRobotEndpoint(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+
+	t.Run("test plan reports first failing command", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/robots/r1/plan", bytes.NewBuffer([]byte(`{"commands":"N E S S"}`)))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var res PlanResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		if res.Success || res.Failure == nil || res.Failure.Index != 3 || res.Failure.Command != "S" {
+			t.Fatalf("plan should fail at the fourth command; got: %+v", res)
+		}
+		if len(res.Path) != 4 || res.Final != (PlanStateResponse{X: 1, Y: 0}) {
+			t.Errorf("plan should visit 4 cells and stop at (1,0); got: %+v", res)
+		}
+	})
+
+	t.Run("test plan does not queue a task", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v1/robots/r1/state", nil)
+		handler.ServeHTTP(rr, req)
+
+		if got, want := rr.Body.String(), `{"x":0,"y":0}`; got != want {
+			t.Errorf("robot should not move; got: %s, want: %s", got, want)
+		}
+	})
+
+	t.Run("test plan rejects invalid commands", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/v1/robots/r1/plan", bytes.NewBuffer([]byte(`{"commands":"N NE"}`)))
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestShutdownEndpoints(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	handler := RobotAPIServer(robot, WithResponseValidation())
+
+	subscription := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		req, _ := http.NewRequest("GET", "/api/v1/state/subscribe", nil)
+		handler.ServeHTTP(subscription, req)
+		close(done)
+	}()
+
+	warehouse.Shutdown(context.Background())
+
+	t.Run("test subscriptions end with a shutdown event", func(t *testing.T) {
+		<-done
+		if body := subscription.Body.String(); !strings.HasPrefix(body, "event: shutdown\n") || !strings.Contains(body, `"code":"shutting-down"`) {
+			t.Errorf("subscription should end with a shutdown event; got: %s", body)
+		}
+	})
+
+	t.Run("test new tasks are rejected", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBufferString(`{"commands":"N"}`))
+		handler.ServeHTTP(rr, req)
+
+		var problem Problem
+		json.Unmarshal(rr.Body.Bytes(), &problem)
+		if rr.Code != http.StatusServiceUnavailable || problem.Code != CodeShuttingDown {
+			t.Errorf("handler returned wrong response: got: %v %s, want: %v", rr.Code, rr.Body.String(), http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func TestMoveRobotEndpointQueueFull(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	warehouse.SetMaxQueuedTasks(1)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go func() {
+		for range robot.tasks {
+		}
+	}() // tasks remain queued
+	handler := RobotAPIServer(robot, WithResponseValidation())
+
+	serve := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBufferString(`{"commands":"N"}`))
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(); rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	rr := serve()
+	var problem Problem
+	json.Unmarshal(rr.Body.Bytes(), &problem)
+	if rr.Code != http.StatusTooManyRequests || problem.Code != CodeQueueFull {
+		t.Errorf("handler returned wrong response: got: %v %s, want: %v", rr.Code, rr.Body.String(), http.StatusTooManyRequests)
+	}
+}
+
+func TestMoveRobotEndpointDoesNotWaitForTaskInProgress(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	warehouse.SetCommandDuration(200 * time.Millisecond)
+	warehouse.SetMaxQueuedTasks(2)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go robot.listen(ctx)
+	handler := RobotAPIServer(robot)
+
+	serve := func() (*httptest.ResponseRecorder, time.Duration) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBufferString(`{"commands":"N5"}`))
+		started := time.Now()
+		handler.ServeHTTP(rr, req)
+		return rr, time.Since(started)
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		rr, took := serve()
+		if rr.Code != want {
+			t.Errorf("request %d returned wrong status code: got %v want %v", i, rr.Code, want)
+		}
+		if took > 100*time.Millisecond {
+			t.Errorf("request %d should not wait for the task in progress; took: %s", i, took)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	robot := NewBot(0, 0, NewInMemoryDB())
+	handler := RobotAPIServer(&robot, WithCORS([]string{"https://allowed.example.com"}))
+
+	serve := func(method, origin string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/v1/state", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("test preflight of allowed origin", func(t *testing.T) {
+		rr := serve(http.MethodOptions, "https://allowed.example.com")
+		if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://allowed.example.com" ||
+			!strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key") {
+			t.Errorf("preflight should be allowed; got: %v %v", rr.Code, rr.Header())
+		}
+	})
+
+	t.Run("test request of allowed origin", func(t *testing.T) {
+		rr := serve(http.MethodGet, "https://allowed.example.com")
+		if rr.Code != http.StatusOK || rr.Header().Get("Access-Control-Allow-Origin") != "https://allowed.example.com" {
+			t.Errorf("request should be allowed; got: %v %v", rr.Code, rr.Header())
+		}
+	})
+
+	t.Run("test request of other origin", func(t *testing.T) {
+		rr := serve(http.MethodGet, "https://other.example.com")
+		if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("request should not be allowed; got: %v", rr.Header())
+		}
+	})
+}
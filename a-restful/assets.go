@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// embeddedAssets contains the frontend (`public`) and Swagger UI (`swaggerui`) compiled into the binary
+//
+//go:embed public swaggerui
+var embeddedAssets embed.FS
+
+// assets serves static files with `ETag` and `Cache-Control` headers
+// - files of the embedded filesystem never change, hence their ETags are computed once
+type assets struct {
+	fsys     fs.FS
+	embedded bool
+	etags    sync.Map // file name -> ETag
+}
+
+// newAssets serves the embedded static files, or the files of `dir` (containing `public` and `swaggerui`) if set
+// - an override directory enables editing the frontend without rebuilding the binary
+func newAssets(dir string) (*assets, error) {
+	if dir == "" {
+		return &assets{fsys: embeddedAssets, embedded: true}, nil
+	}
+	for _, sub := range []string{"public", "swaggerui"} {
+		if info, err := os.Stat(path.Join(dir, sub)); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("assets directory '%s' must contain a '%s' directory", dir, sub)
+		}
+	}
+	return &assets{fsys: os.DirFS(dir)}, nil
+}
+
+// handler serves the files of a directory of the assets; requests for a directory serve its `index.html`
+func (a *assets) handler(dir string) http.Handler {
+	sub, _ := fs.Sub(a.fsys, dir)
+	fileServer := http.FileServer(http.FS(sub))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+
+		// assets are not fingerprinted, hence clients must revalidate (cheaply, via `If-None-Match`) before reuse
+		w.Header().Set("Cache-Control", "no-cache")
+		if etag, err := a.etag(path.Join(dir, name)); err == nil {
+			w.Header().Set("ETag", etag)
+		}
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+// etag returns a strong ETag derived from the content of a file
+func (a *assets) etag(name string) (string, error) {
+	if etag, ok := a.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+
+	if a.embedded {
+		a.etags.Store(name, etag)
+	}
+	return etag, nil
+}
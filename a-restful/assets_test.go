@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedAssets(t *testing.T) {
+	handler := getHTTPHandler()
+
+	t.Run("test frontend is served with cache headers", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		if !strings.Contains(rr.Body.String(), "<title>Robot State</title>") {
+			t.Error("frontend should be served from embedded files")
+		}
+		if rr.Header().Get("ETag") == "" {
+			t.Error("response must contain `ETag` header")
+		}
+		if got := rr.Header().Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("incorrect `Cache-Control` header; got: %s, want: no-cache", got)
+		}
+	})
+
+	t.Run("test unchanged swagger ui stylesheet is not modified", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/swaggerui/swagger-ui.css", nil)
+		handler.ServeHTTP(rr, req)
+
+		etag := rr.Header().Get("ETag")
+		if rr.Code != http.StatusOK || etag == "" {
+			t.Fatalf("swagger ui stylesheet should be served with `ETag`; got status: %d", rr.Code)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+			t.Errorf("incorrect content type; got: %s", got)
+		}
+
+		rr = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/swaggerui/swagger-ui.css", nil)
+		req.Header.Set("If-None-Match", etag)
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusNotModified {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotModified)
+		}
+	})
+
+	t.Run("test swagger ui index is served", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/swaggerui/", nil)
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Header().Get("ETag") == "" {
+			t.Errorf("swagger ui index should be served with `ETag`; got status: %d", rr.Code)
+		}
+	})
+}
+
+func TestAssetsDirOverride(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"public", "swaggerui"} {
+		os.Mkdir(path.Join(dir, sub), 0755)
+	}
+	os.WriteFile(path.Join(dir, "public", "index.html"), []byte("v1"), 0644)
+
+	robot := NewBot(0, 0, NewInMemoryDB())
+	handler := RobotAPIServer(&robot, WithAssetsDir(dir))
+
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get()
+	if first.Body.String() != "v1" {
+		t.Fatalf("frontend should be served from override directory; got: %s", first.Body.String())
+	}
+
+	os.WriteFile(path.Join(dir, "public", "index.html"), []byte("v2"), 0644)
+	second := get()
+	if second.Body.String() != "v2" {
+		t.Errorf("changes to override directory should be served; got: %s", second.Body.String())
+	}
+	if first.Header().Get("ETag") == second.Header().Get("ETag") {
+		t.Error("`ETag` should change with file contents")
+	}
+
+	t.Run("test invalid override directory", func(t *testing.T) {
+		if _, err := newAssets(path.Join(dir, "public")); err == nil {
+			t.Error("directory without `public` and `swaggerui` should be rejected")
+		}
+	})
+}
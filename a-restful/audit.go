@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+)
+
+// AuditAction identifies the kind of an operator action
+type AuditAction string
+
+const (
+	// AuditTaskCreate is a task queued on a robot
+	AuditTaskCreate AuditAction = "task.create"
+	// AuditTaskCancel is a cancelled task
+	AuditTaskCancel AuditAction = "task.cancel"
+	// AuditRobotAdd is a robot added to the warehouse
+	AuditRobotAdd AuditAction = "robot.add"
+	// AuditRobotRemove is a robot removed from the warehouse
+	AuditRobotRemove AuditAction = "robot.remove"
+	// AuditLayoutChange is a change of the layout of the warehouse, e.g. its charging stations
+	AuditLayoutChange AuditAction = "layout.change"
+)
+
+// AuditEntry records an operator action; who performed it, from where, when and as part of which request
+type AuditEntry struct {
+	Sequence  int64       `json:"sequence" description:"position of the entry within the audit log, starting at 1"`
+	Time      time.Time   `json:"time"`
+	RequestID string      `json:"requestId"`
+	Principal string      `json:"principal"`
+	SourceIP  string      `json:"sourceIp"`
+	Action    AuditAction `json:"action" enum:"task.create,task.cancel,robot.add,robot.remove,layout.change"`
+	Robot     string      `json:"robot,omitempty"`
+	Task      string      `json:"task,omitempty"`
+	Detail    string      `json:"detail,omitempty" description:"e.g. the command sequence of a created task"`
+}
+
+// AuditFilter selects entries of the audit log; empty fields match every entry
+// - Limit selects the most recent matching entries
+type AuditFilter struct {
+	Principal string
+	Action    AuditAction
+	Robot     string
+	Task      string
+	Since     time.Time
+	Limit     int
+}
+
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	return (f.Principal == "" || entry.Principal == f.Principal) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Robot == "" || entry.Robot == f.Robot) &&
+		(f.Task == "" || entry.Task == f.Task) &&
+		!entry.Time.Before(f.Since)
+}
+
+// AuditResponse is response body of the audit log
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// jsonLinesContentType is the media type of JSON Lines (newline delimited JSON) exports
+const jsonLinesContentType = "application/x-ndjson"
+
+type requestIDKey struct{}
+
+// requestIDMiddleware identifies each request by the `X-Request-ID` header of the request (e.g. set by a proxy) or a generated ID
+// - the request ID is echoed as `X-Request-ID` header of the response, so clients can correlate audit entries
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = uuid.NewV4().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID of a request
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// sourceIP returns the IP address of the client of a request
+// - forwarding headers are not trusted, as they can be set by any client
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// auditor records the actions of requests in an audit log
+type auditor struct {
+	log AuditLog
+}
+
+// record appends an action of a request to the audit log, completing the entry with the principal, source IP, time and request ID
+// - failing to record an action does not fail the action, as it has already been performed
+func (a auditor) record(r *http.Request, entry AuditEntry) {
+	a.recordContext(r.Context(), sourceIP(r), entry)
+}
+
+// recordContext appends an action to the audit log, completing the entry with the principal and request ID of the context
+// - used by APIs other than the RESTful API (e.g. gRPC), which identify requests by context
+func (a auditor) recordContext(ctx context.Context, sourceIP string, entry AuditEntry) {
+	entry.Time = time.Now().UTC()
+	entry.RequestID, _ = ctx.Value(requestIDKey{}).(string)
+	entry.Principal = PrincipalFrom(ctx).Name
+	entry.SourceIP = sourceIP
+	if _, err := a.log.Append(entry); err != nil {
+		log.Printf("failed to record audit entry %+v: %v", entry, err)
+	}
+}
+
+// auditRoutes registers the endpoint to query and export the audit log
+func auditRoutes(router *mux.Router, spec *apiSpec, audit AuditLog) {
+	spec.handle(router, endpoint{
+		method: "GET", path: "/api/v1/audit", tag: "Audit", role: RoleAdmin,
+		summary:     "Query audit log",
+		description: "Returns the audit trail of operator actions, oldest first. With `format=jsonl` (or `Accept: application/x-ndjson`) entries are exported as JSON Lines.",
+		query: []param{
+			{"principal", "entries of a principal"},
+			{"action", "entries of an action; one of 'task.create', 'task.cancel', 'robot.add', 'robot.remove' or 'layout.change'"},
+			{"robot", "entries of a robot"},
+			{"task", "entries of a task"},
+			{"since", "entries recorded at or after a time (RFC 3339)"},
+			{"limit", "maximum number of (most recent) entries"},
+			{"format", "'json' (default) or 'jsonl'"},
+		},
+		responses: map[int]content{
+			200: {"application/json": AuditResponse{}, jsonLinesContentType: ""},
+			400: {problemContentType: Problem{}},
+			500: {problemContentType: Problem{}},
+		},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := AuditFilter{
+			Principal: query.Get("principal"),
+			Action:    AuditAction(query.Get("action")),
+			Robot:     query.Get("robot"),
+			Task:      query.Get("task"),
+		}
+		if since := query.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Errorf("invalid 'since' time '%s'; time must be RFC 3339 formatted", since))
+				return
+			}
+			filter.Since = t
+		}
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Errorf("invalid 'limit' '%s'; limit must be a positive number", limit))
+				return
+			}
+			filter.Limit = n
+		}
+
+		entries, err := audit.Entries(filter)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, CodeAuditUnavailable, err)
+			return
+		}
+
+		if query.Get("format") != "jsonl" && r.Header.Get("Accept") != jsonLinesContentType {
+			writeJSON(w, http.StatusOK, AuditResponse{entries})
+			return
+		}
+
+		w.Header().Set("Content-Type", jsonLinesContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		encoder := json.NewEncoder(w)
+		for _, entry := range entries {
+			encoder.Encode(entry)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditEndpoint(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go func() {
+		for range robot.tasks {
+		}
+	}() // tasks remain queued, so they can be cancelled
+	handler := RobotAPIServer(robot, WithResponseValidation(), WithAuthenticator(NewAuthenticator(map[string]Principal{
+		"viewer-key":   {"dashboard", RoleViewer},
+		"operator-key": {"ground-station", RoleOperator},
+		"admin-key":    {"admin", RoleAdmin},
+	}, nil)))
+
+	serve := func(method, path, body, key string, header map[string]string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-API-Key", key)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("PUT", "/api/v1/state", `{"commands":"N E"}`, "operator-key", map[string]string{"X-Request-ID": "req-1"})
+	var taskIDResponse TaskIDResponse
+	json.Unmarshal(rr.Body.Bytes(), &taskIDResponse)
+	serve("DELETE", "/api/v1/task/"+taskIDResponse.TaskID, "", "operator-key", nil)
+	serve("DELETE", "/api/v1/task/"+taskIDResponse.TaskID, "", "operator-key", nil) // repeated cancel is not an action
+	serve("POST", "/api/v1/robots", `{"id":"r2","x":5}`, "admin-key", nil)
+	serve("DELETE", "/api/v1/robots/r2", "", "admin-key", nil)
+	serve("PUT", "/api/v1/layout", `{"chargingStations":[{"x":0,"y":9},{"x":5,"y":5}]}`, "admin-key", nil)
+
+	t.Run("test records operator actions", func(t *testing.T) {
+		rr := serve("GET", "/api/v1/audit", "", "admin-key", nil)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var res AuditResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		if len(res.Entries) != 5 {
+			t.Fatalf("audit log should contain 5 entries; got: %+v", res.Entries)
+		}
+
+		create := res.Entries[0]
+		if create.Sequence != 1 || create.Action != AuditTaskCreate || create.Principal != "ground-station" || create.SourceIP != "192.0.2.1" ||
+			create.RequestID != "req-1" || create.Robot != "r1" || create.Task != taskIDResponse.TaskID || create.Detail != "N E" || create.Time.IsZero() {
+			t.Errorf("incorrect task creation entry; got: %+v", create)
+		}
+		if cancel := res.Entries[1]; cancel.Action != AuditTaskCancel || cancel.Task != taskIDResponse.TaskID || cancel.RequestID == "" {
+			t.Errorf("incorrect task cancellation entry; got: %+v", cancel)
+		}
+		if add := res.Entries[2]; add.Action != AuditRobotAdd || add.Principal != "admin" || add.Robot != "r2" {
+			t.Errorf("incorrect robot entry; got: %+v", add)
+		}
+		if remove := res.Entries[3]; remove.Action != AuditRobotRemove || remove.Principal != "admin" || remove.Robot != "r2" || remove.Detail != "(5, 0)" {
+			t.Errorf("incorrect robot removal entry; got: %+v", remove)
+		}
+		if layout := res.Entries[4]; layout.Action != AuditLayoutChange || layout.Robot != "" || layout.Detail != "charging stations (5, 5), (0, 9)" {
+			t.Errorf("incorrect layout entry; got: %+v", layout)
+		}
+	})
+
+	t.Run("test filters entries", func(t *testing.T) {
+		rr := serve("GET", "/api/v1/audit?principal=ground-station&limit=1", "", "admin-key", nil)
+
+		var res AuditResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		if len(res.Entries) != 1 || res.Entries[0].Action != AuditTaskCancel {
+			t.Errorf("audit log should contain the latest entry of ground-station; got: %+v", res.Entries)
+		}
+	})
+
+	t.Run("test exports entries as json lines", func(t *testing.T) {
+		rr := serve("GET", "/api/v1/audit?format=jsonl", "", "admin-key", nil)
+		if got := rr.Header().Get("Content-Type"); got != jsonLinesContentType {
+			t.Errorf("incorrect content type; got: %s, want: %s", got, jsonLinesContentType)
+		}
+
+		var lines int
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Errorf("line %d is not an audit entry: %v", lines+1, err)
+			}
+			lines++
+		}
+		if lines != 5 {
+			t.Errorf("export should contain 5 lines; got: %d", lines)
+		}
+	})
+
+	t.Run("test rejects invalid since", func(t *testing.T) {
+		if rr := serve("GET", "/api/v1/audit?since=yesterday", "", "admin-key", nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("test audit log requires admin", func(t *testing.T) {
+		if rr := serve("GET", "/api/v1/audit", "", "viewer-key", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("test responses carry request id", func(t *testing.T) {
+		if rr := serve("GET", "/health", "", "", nil); rr.Header().Get("X-Request-ID") == "" {
+			t.Error("response must contain `X-Request-ID` header")
+		}
+	})
+}
+
+func TestAuditRobotRemoveAbort(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	warehouse.SetCommandDuration(time.Second)
+	r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	r2, _ := warehouse.AddRobot("r2", 5, 0, 0, NewInMemoryDB())
+	go r2.listen(context.Background())
+	auditLog := NewInMemoryAuditLog(nil)
+	handler := RobotAPIServer(r1, WithAuditLog(auditLog))
+
+	running, _, _ := r2.EnqueueTask("N N N")
+	for started := false; !started; time.Sleep(time.Millisecond) {
+		r2.mu.Lock()
+		started = r2.running == running
+		r2.mu.Unlock()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "DELETE", "/api/v1/robots/r2", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, _ := auditLog.Entries(AuditFilter{Action: AuditRobotRemove})
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Detail, "; task in progress aborted: context canceled") {
+		t.Errorf("removal entry should record the aborted task; got: %+v", entries)
+	}
+}
+
+func TestOpenAuditLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	auditLog, f, err := OpenAuditLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditLog.Append(AuditEntry{Action: AuditTaskCreate, Task: "t1"})
+	auditLog.Append(AuditEntry{Action: AuditTaskCancel, Task: "t1"})
+	f.Close()
+
+	auditLog, f, err = OpenAuditLogFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entry, _ := auditLog.Append(AuditEntry{Action: AuditRobotAdd, Robot: "r2"})
+	if entry.Sequence != 3 {
+		t.Errorf("sequence should continue after entries of the file; got: %d", entry.Sequence)
+	}
+	entries, _ := auditLog.Entries(AuditFilter{Task: "t1"})
+	if len(entries) != 2 || !strings.HasPrefix(string(entries[1].Action), "task.") {
+		t.Errorf("entries of the file should be loaded; got: %+v", entries)
+	}
+}
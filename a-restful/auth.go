@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Role grants access to endpoints; each role is granted the access of the roles below it
+type Role int
+
+const (
+	// RoleViewer may read robot states and tasks, and subscribe to robot state changes
+	RoleViewer Role = iota + 1
+	// RoleOperator may additionally queue, plan and cancel tasks
+	RoleOperator
+	// RoleAdmin may additionally manage the robots of the warehouse
+	RoleAdmin
+)
+
+// ParseRole converts a role name (`viewer`, `operator` or `admin`) to a Role
+func ParseRole(name string) (Role, error) {
+	switch strings.ToLower(name) {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return 0, fmt.Errorf("invalid role '%s'; role can only be one of 'viewer', 'operator' or 'admin'", name)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "unknown"
+}
+
+// Principal is the authenticated identity performing a request
+type Principal struct {
+	Name string
+	Role Role
+}
+
+// anonymous is the principal of requests when authentication is disabled
+var anonymous = Principal{Name: "anonymous", Role: RoleAdmin}
+
+// Errors of authentication and authorization
+var (
+	// ErrUnauthenticated is the cause of an error of a request without valid credentials
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden is the cause of an error of a principal lacking the role required by an endpoint
+	ErrForbidden = errors.New("forbidden")
+)
+
+type principalKey struct{}
+
+// PrincipalFrom returns the principal of a request context; requests are anonymous if authentication is disabled
+func PrincipalFrom(ctx context.Context) Principal {
+	if p, ok := ctx.Value(principalKey{}).(Principal); ok {
+		return p
+	}
+	return anonymous
+}
+
+// Authenticator verifies the credentials of requests; either a static API key (`X-API-Key` header)
+// or an HMAC-SHA256 signed JWT (`Authorization: Bearer <token>` header)
+// - API keys are stored as SHA-256 hashes, so looking up a key does not leak the keys via timing
+// - JWTs must be signed (`HS256`) with the secret, and carry the principal as `sub` and its role as `role` claims
+type Authenticator struct {
+	keys   map[[sha256.Size]byte]Principal
+	secret []byte
+}
+
+// NewAuthenticator creates an authenticator of API keys (key -> principal) and JWTs signed with the secret
+// - JWTs are rejected if the secret is empty
+func NewAuthenticator(keys map[string]Principal, jwtSecret []byte) *Authenticator {
+	a := &Authenticator{keys: make(map[[sha256.Size]byte]Principal, len(keys)), secret: jwtSecret}
+	for key, principal := range keys {
+		a.keys[sha256.Sum256([]byte(key))] = principal
+	}
+	return a
+}
+
+// LoadAPIKeys reads API keys from a file; each line contains a key, a role and optionally the name of the principal
+// - blank lines and lines starting with `#` are ignored; the name defaults to the role
+//
+//	# key                              role      name
+//	5a0d9f8c3e1b4c7d9a2e6f1b0c3d5e7f   operator  ground-station
+func LoadAPIKeys(path string) (map[string]Principal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keys := make(map[string]Principal)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: expected '<key> <role> [name]'", path, line)
+		}
+		role, err := ParseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		principal := Principal{Name: role.String(), Role: role}
+		if len(fields) == 3 {
+			principal.Name = fields[2]
+		}
+		keys[fields[0]] = principal
+	}
+	return keys, scanner.Err()
+}
+
+// Authenticate returns the principal of the credentials of a request
+// - if `allowQuery` is set, the credentials may be passed as `access_token` query parameter (as browsers cannot set headers of event streams)
+func (a *Authenticator) Authenticate(r *http.Request, allowQuery bool) (Principal, error) {
+	key, auth := r.Header.Get("X-API-Key"), r.Header.Get("Authorization")
+	if token := r.URL.Query().Get("access_token"); key == "" && auth == "" && allowQuery && token != "" {
+		if strings.Count(token, ".") == 2 {
+			return a.jwt(token, time.Now())
+		}
+		return a.apiKey(token)
+	}
+	return a.credentials(key, auth)
+}
+
+// credentials returns the principal of an API key or, if the key is empty, an authorization (`Bearer <token>`)
+// - shared by the RESTful API (headers) and the gRPC API (metadata)
+func (a *Authenticator) credentials(key string, authorization string) (Principal, error) {
+	if key != "" {
+		return a.apiKey(key)
+	}
+	if authorization != "" {
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if token == authorization {
+			return Principal{}, fmt.Errorf("%w: unsupported authorization scheme", ErrUnauthenticated)
+		}
+		return a.jwt(token, time.Now())
+	}
+	return Principal{}, fmt.Errorf("%w: missing credentials", ErrUnauthenticated)
+}
+
+// apiKey returns the principal of an API key
+func (a *Authenticator) apiKey(key string) (Principal, error) {
+	if principal, ok := a.keys[sha256.Sum256([]byte(key))]; ok {
+		return principal, nil
+	}
+	return Principal{}, fmt.Errorf("%w: invalid API key", ErrUnauthenticated)
+}
+
+// jwtClaims are the claims of a JWT identifying a principal
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// jwt verifies the signature and validity period of a JWT, returning the principal of its claims
+func (a *Authenticator) jwt(token string, now time.Time) (Principal, error) {
+	if len(a.secret) == 0 {
+		return Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", ErrUnauthenticated)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, fmt.Errorf("%w: malformed token", ErrUnauthenticated)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, fmt.Errorf("%w: token must be signed using HS256", ErrUnauthenticated)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return Principal{}, fmt.Errorf("%w: invalid token signature", ErrUnauthenticated)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed token claims", ErrUnauthenticated)
+	}
+	if claims.ExpiresAt != nil && now.Unix() >= *claims.ExpiresAt {
+		return Principal{}, fmt.Errorf("%w: token has expired", ErrUnauthenticated)
+	}
+	if claims.NotBefore != nil && now.Unix() < *claims.NotBefore {
+		return Principal{}, fmt.Errorf("%w: token is not valid yet", ErrUnauthenticated)
+	}
+	if claims.Subject == "" {
+		return Principal{}, fmt.Errorf("%w: token must identify its subject", ErrUnauthenticated)
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	return Principal{Name: claims.Subject, Role: role}, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a JWT
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// middleware authenticates requests of the endpoints of the spec, and authorizes the principal against the role required by the endpoint
+// - requests of public endpoints (and routes which are not part of the spec, e.g. static files) are not authenticated
+func (a *Authenticator) middleware(spec *apiSpec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			e, _, ok := spec.operation(r)
+			if !ok || e.public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := a.Authenticate(r, e.streaming)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="robot"`)
+				writeError(w, r, http.StatusUnauthorized, CodeUnauthenticated, err)
+				return
+			}
+			if principal.Role < e.requiredRole() {
+				err := fmt.Errorf("%w: %s '%s' requires the %s role", ErrForbidden, principal.Role, principal.Name, e.requiredRole())
+				writeError(w, r, http.StatusForbidden, CodeForbidden, err)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
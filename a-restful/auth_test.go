@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT signs claims as an HS256 JWT
+func signJWT(secret []byte, alg string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range []Role{RoleViewer, RoleOperator, RoleAdmin} {
+		t.Run(fmt.Sprintf("test parses %s role", role), func(t *testing.T) {
+			got, err := ParseRole(role.String())
+			if err != nil || got != role {
+				t.Errorf("incorrect role; want: %s, got: %s (%v)", role, got, err)
+			}
+		})
+	}
+
+	t.Run("test fails to parse unknown role", func(t *testing.T) {
+		if _, err := ParseRole("root"); err == nil {
+			t.Error("role 'root' should be invalid")
+		}
+	})
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("test loads keys", func(t *testing.T) {
+		path := filepath.Join(dir, "keys")
+		ioutil.WriteFile(path, []byte("# key role name\nk1 viewer\n\nk2 operator ground-station\n"), 0600)
+
+		keys, err := LoadAPIKeys(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := keys["k1"], (Principal{"viewer", RoleViewer}); got != want {
+			t.Errorf("incorrect principal of k1; want: %+v, got: %+v", want, got)
+		}
+		if got, want := keys["k2"], (Principal{"ground-station", RoleOperator}); got != want {
+			t.Errorf("incorrect principal of k2; want: %+v, got: %+v", want, got)
+		}
+	})
+
+	t.Run("test fails to load key with invalid role", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid")
+		ioutil.WriteFile(path, []byte("k1 root\n"), 0600)
+
+		_, err := LoadAPIKeys(path)
+		if err == nil || err.Error() != path+":1: invalid role 'root'; role can only be one of 'viewer', 'operator' or 'admin'" {
+			t.Errorf("incorrect error; got: %v", err)
+		}
+	})
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	secret := []byte("secret")
+	a := NewAuthenticator(nil, secret)
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"valid token", signJWT(secret, "HS256", map[string]interface{}{"sub": "alice", "role": "operator", "exp": now.Add(time.Minute).Unix()}), ""},
+		{"expired token", signJWT(secret, "HS256", map[string]interface{}{"sub": "alice", "role": "operator", "exp": now.Add(-time.Minute).Unix()}), "unauthenticated: token has expired"},
+		{"premature token", signJWT(secret, "HS256", map[string]interface{}{"sub": "alice", "role": "operator", "nbf": now.Add(time.Minute).Unix()}), "unauthenticated: token is not valid yet"},
+		{"token of other secret", signJWT([]byte("other"), "HS256", map[string]interface{}{"sub": "alice", "role": "operator"}), "unauthenticated: invalid token signature"},
+		{"unsigned token", signJWT(secret, "none", map[string]interface{}{"sub": "alice", "role": "operator"}), "unauthenticated: token must be signed using HS256"},
+		{"token without role", signJWT(secret, "HS256", map[string]interface{}{"sub": "alice"}), "unauthenticated: invalid role ''; role can only be one of 'viewer', 'operator' or 'admin'"},
+		{"malformed token", "a.b", "unauthenticated: malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run("test "+tt.name, func(t *testing.T) {
+			principal, err := a.jwt(tt.token, now)
+			if tt.err == "" {
+				if err != nil || principal != (Principal{"alice", RoleOperator}) {
+					t.Errorf("token should authenticate alice as operator; got: %+v (%v)", principal, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err || !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("incorrect error; want: %s, got: %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("test tokens are rejected without secret", func(t *testing.T) {
+		token := signJWT(nil, "HS256", map[string]interface{}{"sub": "alice", "role": "operator"})
+		if _, err := NewAuthenticator(nil, nil).jwt(token, now); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("token should be rejected; got: %v", err)
+		}
+	})
+}
+
+func TestAuthorization(t *testing.T) {
+	secret := []byte("secret")
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	handler := RobotAPIServer(robot, WithResponseValidation(), WithAuthenticator(NewAuthenticator(map[string]Principal{
+		"viewer-key":   {"dashboard", RoleViewer},
+		"operator-key": {"ground-station", RoleOperator},
+		"admin-key":    {"admin", RoleAdmin},
+	}, secret)))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header map[string]string
+		status int
+	}{
+		{"public health without credentials", "GET", "/health", "", nil, http.StatusOK},
+		{"public spec without credentials", "GET", "/openapi.json", "", nil, http.StatusOK},
+		{"state without credentials", "GET", "/api/v1/state", "", nil, http.StatusUnauthorized},
+		{"state with invalid key", "GET", "/api/v1/state", "", map[string]string{"X-API-Key": "unknown"}, http.StatusUnauthorized},
+		{"state with query token", "GET", "/api/v1/state?access_token=viewer-key", "", nil, http.StatusUnauthorized},
+		{"state as viewer", "GET", "/api/v1/state", "", map[string]string{"X-API-Key": "viewer-key"}, http.StatusOK},
+		{"move as viewer", "PUT", "/api/v1/state", `{"commands":"N"}`, map[string]string{"X-API-Key": "viewer-key"}, http.StatusForbidden},
+		{"move as operator", "PUT", "/api/v1/state", `{"commands":"N"}`, map[string]string{"X-API-Key": "operator-key"}, http.StatusOK},
+		{"add robot as operator", "POST", "/api/v1/robots", `{"id":"r2","x":5}`, map[string]string{"X-API-Key": "operator-key"}, http.StatusForbidden},
+		{"add robot as admin", "POST", "/api/v1/robots", `{"id":"r2","x":5}`, map[string]string{"X-API-Key": "admin-key"}, http.StatusCreated},
+		{"remove robot as operator", "DELETE", "/api/v1/robots/r2", "", map[string]string{"X-API-Key": "operator-key"}, http.StatusForbidden},
+		{"change layout as operator", "PUT", "/api/v1/layout", `{"chargingStations":[]}`, map[string]string{"X-API-Key": "operator-key"}, http.StatusForbidden},
+		{"layout as viewer", "GET", "/api/v1/layout", "", map[string]string{"X-API-Key": "viewer-key"}, http.StatusOK},
+		{"move with bearer token", "PUT", "/api/v1/state", `{"commands":"S"}`, map[string]string{
+			"Authorization": "Bearer " + signJWT(secret, "HS256", map[string]interface{}{"sub": "alice", "role": "operator"}),
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run("test "+tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Errorf("handler returned wrong status code: got %v want %v; %s", status, tt.status, rr.Body.String())
+			}
+			if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+				t.Error("response must contain `WWW-Authenticate` header")
+			}
+		})
+	}
+
+	t.Run("test task records principal", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBufferString(`{"commands":"E"}`))
+		req.Header.Set("X-API-Key", "operator-key")
+		handler.ServeHTTP(rr, req)
+
+		var taskIDResponse TaskIDResponse
+		json.Unmarshal(rr.Body.Bytes(), &taskIDResponse)
+
+		rr = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/api/v1/task/"+taskIDResponse.TaskID, nil)
+		req.Header.Set("X-API-Key", "viewer-key")
+		handler.ServeHTTP(rr, req)
+
+		var res TaskResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		if res.Task.CreatedBy != "ground-station" {
+			t.Errorf("task should be created by ground-station; got: %+v", res.Task)
+		}
+	})
+}
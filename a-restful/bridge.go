@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Topics of a robot, relative to `warehouse/{warehouse}/robot/{robot}/`
+const (
+	topicState      = "state"       // current state of the robot (retained), e.g. `{"x":0,"y":1}`
+	topicTask       = "task"        // tasks executed by the robot (TaskEvent)
+	topicCommand    = "command"     // commands queued as tasks of the robot (MQTTCommand)
+	topicCommandAck = "command/ack" // acknowledgements of commands (MQTTCommandAck)
+)
+
+// MQTTCommand is a message of the command topic of a robot; queues a task like `PUT /api/v1/robots/{id}/state`
+type MQTTCommand struct {
+	Commands      string `json:"commands"`
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+// MQTTCommandAck acknowledges a command with the ID of the queued task, or the problem details of a rejected command
+// - the correlation ID of the command is echoed, so clients can match acknowledgements to their commands
+type MQTTCommandAck struct {
+	CorrelationID string   `json:"correlationId,omitempty"`
+	TaskID        string   `json:"taskID,omitempty"`
+	Problem       *Problem `json:"problem,omitempty"`
+}
+
+// mqttPrincipal is the principal of commands received from the broker; access to the command topics is controlled by the broker
+var mqttPrincipal = Principal{Name: "mqtt", Role: RoleOperator}
+
+// MQTTBridge publishes the state and executed tasks of the robots of a warehouse to a broker, and queues the commands received from it
+// - the bridge is fed by the same events as the state subscription (`/api/v1/state/subscribe`)
+// - robots added to the warehouse later are bridged too
+// - commands are recorded in the audit log as actions of the `mqtt` principal, identified by their correlation ID
+type MQTTBridge struct {
+	broker    Broker
+	prefix    string // `warehouse/{warehouse}/robot/`
+	warehouse *RobotWarehouse
+	audit     auditor
+
+	mu          sync.Mutex
+	closed      bool
+	unsubscribe []func()
+	wg          sync.WaitGroup
+}
+
+// NewMQTTBridge bridges the robots of a warehouse (identified by `warehouseID` within the topics) to a broker
+// - without an audit log, commands are recorded in-memory
+func NewMQTTBridge(warehouseID string, warehouse *RobotWarehouse, broker Broker, auditLog AuditLog) (*MQTTBridge, error) {
+	if !validTopicLevel(warehouseID) {
+		return nil, fmt.Errorf("invalid warehouse ID '%s'; topic levels cannot be empty or contain '/', '+' or '#'", warehouseID)
+	}
+	if auditLog == nil {
+		auditLog = NewInMemoryAuditLog(nil)
+	}
+
+	b := &MQTTBridge{broker: broker, prefix: "warehouse/" + warehouseID + "/robot/", warehouse: warehouse, audit: auditor{auditLog}}
+	unsubscribe, err := broker.Subscribe(b.prefix+"+/"+topicCommand, b.command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to commands: %w", err)
+	}
+	b.unsubscribe = append(b.unsubscribe, unsubscribe)
+	warehouse.OnAddRobot(b.attach)
+	return b, nil
+}
+
+// Close stops bridging the robots; messages in progress are published before Close returns
+func (b *MQTTBridge) Close() {
+	b.mu.Lock()
+	b.closed = true
+	unsubscribe := b.unsubscribe
+	b.unsubscribe = nil
+	b.mu.Unlock()
+
+	for _, f := range unsubscribe {
+		f()
+	}
+	b.wg.Wait()
+}
+
+// attach publishes the current state of a robot, and then the events of the robot until the robot is shut down or the bridge is closed
+// - the robot is subscribed to again if the bridge falls behind its events, republishing its current state
+func (b *MQTTBridge) attach(robot *Bot) {
+	if !validTopicLevel(robot.id) {
+		log.Printf("robot '%s' is not bridged; topic levels cannot be empty or contain '/', '+' or '#'", robot.id)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	// the robot is subscribed to before its current state is published, so no state change is missed
+	events, unsubscribe := robot.events.subscribe()
+	b.unsubscribe = append(b.unsubscribe, unsubscribe)
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+		for events != nil {
+			b.publish(robot, topicState, newStateResponse(robot, robot.CurrentState()), true)
+			for event := range events {
+				switch event.Name {
+				case EventState:
+					b.publish(robot, topicState, newStateResponse(robot, event.State), true)
+				case EventTask:
+					b.publish(robot, topicTask, eventBody(robot, event), false)
+				}
+			}
+			events = b.resubscribe(robot)
+		}
+	}()
+}
+
+// resubscribe subscribes to a robot again once the bridge fell behind its events; nil once the robot is shut down or the bridge is closed
+func (b *MQTTBridge) resubscribe(robot *Bot) <-chan RobotEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || robot.events.closed() {
+		return nil
+	}
+	log.Printf("robot '%s' is bridged again after the bridge fell behind its events", robot.id)
+	events, unsubscribe := robot.events.subscribe()
+	b.unsubscribe = append(b.unsubscribe, unsubscribe)
+	return events
+}
+
+// command queues the command of a message as a task of the robot of the topic, and acknowledges it
+func (b *MQTTBridge) command(topic string, payload []byte) {
+	robotID := strings.TrimSuffix(strings.TrimPrefix(topic, b.prefix), "/"+topicCommand)
+
+	var cmd MQTTCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		b.acknowledge(robotID, MQTTCommandAck{Problem: problemOf(CodeInvalidRequest, fmt.Errorf("invalid command message: %w", err))})
+		return
+	}
+	ack := MQTTCommandAck{CorrelationID: cmd.CorrelationID}
+
+	robot, err := b.warehouse.Robot(robotID)
+	if err != nil {
+		ack.Problem = problemOf(CodeRobotNotFound, err)
+		b.acknowledge(robotID, ack)
+		return
+	}
+	if err := validateCommandSequence(cmd.Commands); err != nil {
+		ack.Problem = problemOf(CodeInvalidCommandSequence, err)
+		b.acknowledge(robotID, ack)
+		return
+	}
+
+	taskID, _, _, err := robot.enqueue(cmd.Commands, mqttPrincipal.Name)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		ack.Problem = problemOf(CodeQueueFull, err)
+	case err != nil:
+		ack.Problem = problemOf(CodeShuttingDown, err)
+	default:
+		ack.TaskID = taskID
+		requestID := cmd.CorrelationID
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewV4().String()
+		}
+		ctx := context.WithValue(context.WithValue(context.Background(), principalKey{}, mqttPrincipal), requestIDKey{}, requestID)
+		b.audit.recordContext(ctx, "", AuditEntry{Action: AuditTaskCreate, Robot: robot.id, Task: taskID, Detail: cmd.Commands})
+	}
+	b.acknowledge(robotID, ack)
+}
+
+// acknowledge publishes the acknowledgement of a command of a robot
+func (b *MQTTBridge) acknowledge(robotID string, ack MQTTCommandAck) {
+	data, _ := json.Marshal(ack)
+	if err := b.broker.Publish(b.prefix+robotID+"/"+topicCommandAck, data, false); err != nil {
+		log.Printf("failed to acknowledge command of robot '%s': %v", robotID, err)
+	}
+}
+
+// publish publishes a JSON encoded message to a topic of a robot
+func (b *MQTTBridge) publish(robot *Bot, topic string, v interface{}, retain bool) {
+	data, _ := json.Marshal(v)
+	if err := b.broker.Publish(b.prefix+robot.id+"/"+topic, data, retain); err != nil {
+		log.Printf("failed to publish %s of robot '%s': %v", topic, robot.id, err)
+	}
+}
+
+// problemOf creates the problem details of an error which is not a response
+func problemOf(code ErrorCode, err error) *Problem {
+	problem := newProblem(code, 0, err)
+	return &problem
+}
+
+// validTopicLevel reports whether an ID can be a level of a topic
+func validTopicLevel(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/+#")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// inbox buffers the messages received from a broker per topic, so messages of other topics are kept until they are awaited
+type inbox struct {
+	mu       sync.Mutex
+	topics   map[string][][]byte
+	received chan struct{} // closed (and replaced) whenever a message is received
+}
+
+func newInbox() *inbox {
+	return &inbox{topics: make(map[string][][]byte), received: make(chan struct{})}
+}
+
+// deliver buffers a message of a topic
+func (in *inbox) deliver(topic string, payload []byte) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.topics[topic] = append(in.topics[topic], append([]byte(nil), payload...))
+	close(in.received)
+	in.received = make(chan struct{})
+}
+
+// next takes the next buffered message of a topic (if any)
+func (in *inbox) next(topic string) ([]byte, bool, <-chan struct{}) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if queue := in.topics[topic]; len(queue) > 0 {
+		in.topics[topic] = queue[1:]
+		return queue[0], true, nil
+	}
+	return nil, false, in.received
+}
+
+// receive returns the next message of a topic, waiting for it to be received
+func (in *inbox) receive(t *testing.T, topic string) []byte {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		payload, ok, received := in.next(topic)
+		if ok {
+			return payload
+		}
+		select {
+		case <-received:
+		case <-timeout:
+			t.Fatalf("no message received on topic '%s'", topic)
+		}
+	}
+}
+
+func TestMQTTBridge(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	broker := NewInMemoryBroker()
+	auditLog := NewInMemoryAuditLog(nil)
+
+	bridge, err := NewMQTTBridge("w1", warehouse, broker, auditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	messages := newInbox()
+	broker.Subscribe("warehouse/w1/#", messages.deliver)
+	command := func(robotID string, payload string) MQTTCommandAck {
+		broker.Publish("warehouse/w1/robot/"+robotID+"/command", []byte(payload), false)
+		var ack MQTTCommandAck
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/"+robotID+"/command/ack"), &ack)
+		return ack
+	}
+
+	t.Run("test current state is retained", func(t *testing.T) {
+		var state StateResponse
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/r1/state"), &state)
+		if state != (StateResponse{X: 0, Y: 0}) {
+			t.Errorf("incorrect state; got: %v, want: %v", state, StateResponse{X: 0, Y: 0})
+		}
+	})
+
+	t.Run("test command is queued and executed", func(t *testing.T) {
+		ack := command("r1", `{"commands": "N E", "correlationId": "c1"}`)
+		if ack.CorrelationID != "c1" || ack.TaskID == "" || ack.Problem != nil {
+			t.Fatalf("command should be acknowledged with a task; got: %+v", ack)
+		}
+
+		var state StateResponse
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/r1/state"), &state)
+		if state != (StateResponse{X: 1, Y: 1}) {
+			t.Errorf("incorrect state; got: %v, want: %v", state, StateResponse{X: 1, Y: 1})
+		}
+		var completed TaskEvent
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/r1/task"), &completed)
+		if completed.Robot != "r1" || completed.Task.ID != ack.TaskID || !completed.Task.Success || completed.Task.CreatedBy != "mqtt" {
+			t.Errorf("incorrect task completion; got: %+v", completed)
+		}
+		if retained, _ := broker.Retained("warehouse/w1/robot/r1/state"); string(retained) != `{"x":1,"y":1}` {
+			t.Errorf("state should be retained; got: %s", retained)
+		}
+
+		entries, _ := auditLog.Entries(AuditFilter{Task: ack.TaskID})
+		if len(entries) != 1 || entries[0].Principal != "mqtt" || entries[0].RequestID != "c1" {
+			t.Errorf("command should be audited; got: %+v", entries)
+		}
+	})
+
+	t.Run("test failed task", func(t *testing.T) {
+		ack := command("r1", `{"commands": "S S"}`)
+		var completed TaskEvent
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/r1/task"), &completed)
+		if completed.Task.ID != ack.TaskID || completed.Task.Success || completed.Problem == nil || completed.Problem.Code != CodeOutOfBounds {
+			t.Errorf("task completion should carry the problem; got: %+v", completed)
+		}
+	})
+
+	t.Run("test rejected commands", func(t *testing.T) {
+		tests := []struct {
+			robot   string
+			payload string
+			code    ErrorCode
+		}{
+			{"r1", `N E`, CodeInvalidRequest},
+			{"r1", `{"commands": "N X"}`, CodeInvalidCommandSequence},
+			{"r9", `{"commands": "N"}`, CodeRobotNotFound},
+		}
+		for _, tt := range tests {
+			if ack := command(tt.robot, tt.payload); ack.Problem == nil || ack.Problem.Code != tt.code || ack.TaskID != "" {
+				t.Errorf("command %s of robot '%s' should be rejected with %s; got: %+v", tt.payload, tt.robot, tt.code, ack)
+			}
+		}
+	})
+
+	t.Run("test robots added later are bridged", func(t *testing.T) {
+		r2, _ := warehouse.AddRobot("r2", 5, 5, 0, NewInMemoryDB())
+		go r2.listen(context.Background())
+
+		var state StateResponse
+		json.Unmarshal(messages.receive(t, "warehouse/w1/robot/r2/state"), &state)
+		if state != (StateResponse{X: 5, Y: 5}) {
+			t.Errorf("incorrect state; got: %v, want: %v", state, StateResponse{X: 5, Y: 5})
+		}
+		if ack := command("r2", `{"commands": "W"}`); ack.TaskID == "" {
+			t.Errorf("command should be queued; got: %+v", ack)
+		}
+		messages.receive(t, "warehouse/w1/robot/r2/task")
+	})
+
+	t.Run("test closed bridge ignores commands", func(t *testing.T) {
+		bridge.Close()
+		broker.Publish("warehouse/w1/robot/r1/command", []byte(`{"commands": "N"}`), false)
+		time.Sleep(50 * time.Millisecond)
+		if payload, ok, _ := messages.next("warehouse/w1/robot/r1/command/ack"); ok {
+			t.Errorf("closed bridge should not acknowledge commands; got: %s", payload)
+		}
+	})
+}
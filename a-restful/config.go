@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix prefixes the environment variables of settings, e.g. `ROBOT_LISTEN` for the `listen` flag
+const envPrefix = "ROBOT_"
+
+// Config is the configuration of the server
+// - settings are read from (in increasing precedence) defaults, a JSON config file, environment variables and flags; see `LoadConfig`
+type Config struct {
+	Listen            string          `json:"listen"`
+	GRPCListen        string          `json:"grpcListen"`
+	TLS               TLSConfig       `json:"tls"`
+	AssetsDir         string          `json:"assetsDir"`
+	IdempotencyWindow Duration        `json:"idempotencyWindow"`
+	ShutdownTimeout   Duration        `json:"shutdownTimeout"`
+	Warehouse         WarehouseConfig `json:"warehouse"`
+	Robots            []RobotConfig   `json:"robots"`
+	Storage           StorageConfig   `json:"storage"`
+	Auth              AuthConfig      `json:"auth"`
+	CORS              CORSConfig      `json:"cors"`
+	MQTT              MQTTConfig      `json:"mqtt"`
+}
+
+// TLSConfig enables HTTPS if both the certificate and key files are set
+type TLSConfig struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+// WarehouseConfig configures how the robots of the warehouse move
+// - the warehouse is a 10x10 grid; the layout of the warehouse is the position of its robots (see `RobotConfig`) and charging stations
+type WarehouseConfig struct {
+	Collision          string           `json:"collision"`
+	WaitTimeout        Duration         `json:"waitTimeout"`
+	DeadlockRule       string           `json:"deadlockRule"`
+	DeadlockResolution string           `json:"deadlockResolution"`
+	CommandDuration    Duration         `json:"commandDuration"`
+	MaxQueuedTasks     int              `json:"maxQueuedTasks"`
+	Battery            BatteryConfig    `json:"battery"`
+	ChargingStations   []PositionConfig `json:"chargingStations"`
+}
+
+// BatteryConfig models the batteries of the robots if the capacity is set; see `BatteryModel`
+type BatteryConfig struct {
+	Capacity uint `json:"capacity"`
+	MoveCost uint `json:"moveCost"`
+}
+
+// PositionConfig is a location on the warehouse grid
+type PositionConfig struct {
+	X uint `json:"x"`
+	Y uint `json:"y"`
+}
+
+// RobotConfig is a robot operating in the warehouse; the first robot is served at `/api/v1/state`
+type RobotConfig struct {
+	ID       string `json:"id"`
+	X        uint   `json:"x"`
+	Y        uint   `json:"y"`
+	Priority int    `json:"priority"`
+}
+
+// StorageConfig selects where tasks and the audit log are stored
+type StorageConfig struct {
+	Backend   string `json:"backend"`
+	AuditFile string `json:"auditFile"`
+}
+
+// AuthConfig enables authentication if either the API keys file or JWT secret file is set
+type AuthConfig struct {
+	APIKeys       string `json:"apiKeys"`
+	JWTSecretFile string `json:"jwtSecretFile"`
+}
+
+// MQTTConfig bridges the robots to an MQTT server if the broker URL is set
+// - topics are prefixed by `warehouse/{warehouseId}/robot/{robot}/`
+type MQTTConfig struct {
+	Broker      string `json:"broker"`
+	ClientID    string `json:"clientId"`
+	WarehouseID string `json:"warehouseId"`
+}
+
+// CORSConfig allows browsers of other origins to call the API; `*` allows any origin
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowedOrigins"`
+}
+
+// DefaultConfig is the configuration of the server without config file, environment variables or flags
+func DefaultConfig() Config {
+	return Config{
+		Listen:            ":8000",
+		GRPCListen:        ":50051",
+		IdempotencyWindow: Duration(24 * time.Hour),
+		ShutdownTimeout:   Duration(30 * time.Second),
+		Warehouse: WarehouseConfig{
+			Collision:          "fail",
+			WaitTimeout:        Duration(10 * time.Second),
+			DeadlockRule:       "priority",
+			DeadlockResolution: "abort",
+			Battery:            BatteryConfig{MoveCost: 1},
+			ChargingStations:   []PositionConfig{},
+		},
+		Robots:  []RobotConfig{{ID: "r1"}},
+		Storage: StorageConfig{Backend: "memory"},
+		CORS:    CORSConfig{AllowedOrigins: []string{}},
+		MQTT:    MQTTConfig{ClientID: "robot-server", WarehouseID: "w1"},
+	}
+}
+
+// Validate checks the settings which cannot be expressed by their types
+func (c Config) Validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("listen address must be set")
+	}
+	if c.GRPCListen == c.Listen {
+		return fmt.Errorf("gRPC listen address must differ from the listen address")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		return fmt.Errorf("both the TLS certificate and key must be set")
+	}
+	if _, err := ParseCollisionPolicy(c.Warehouse.Collision); err != nil {
+		return err
+	}
+	if _, err := ParseDeadlockRule(c.Warehouse.DeadlockRule); err != nil {
+		return err
+	}
+	if _, err := ParseDeadlockResolution(c.Warehouse.DeadlockResolution); err != nil {
+		return err
+	}
+	if c.Warehouse.MaxQueuedTasks < 0 {
+		return fmt.Errorf("maximum queued tasks must not be negative")
+	}
+	for _, p := range c.Warehouse.ChargingStations {
+		if p.X > 9 || p.Y > 9 {
+			return fmt.Errorf("invalid position (%d, %d) of charging station; co-ordinates must satisfy 0 <= x, y < 10", p.X, p.Y)
+		}
+	}
+	if len(c.Robots) == 0 {
+		return fmt.Errorf("at least one robot must be configured")
+	}
+	for _, r := range c.Robots {
+		if r.ID == "" {
+			return fmt.Errorf("robot ID must be set")
+		}
+		if r.X > 9 || r.Y > 9 {
+			return fmt.Errorf("invalid position (%d, %d) of robot '%s'; co-ordinates must satisfy 0 <= x, y < 10", r.X, r.Y, r.ID)
+		}
+	}
+	if c.MQTT.Broker != "" && !validTopicLevel(c.MQTT.WarehouseID) {
+		return fmt.Errorf("invalid MQTT warehouse ID '%s'; topic levels cannot be empty or contain '/', '+' or '#'", c.MQTT.WarehouseID)
+	}
+	if c.Storage.Backend != "memory" {
+		return fmt.Errorf("invalid storage backend '%s'; backend can only be 'memory'", c.Storage.Backend)
+	}
+	return nil
+}
+
+// setting is a configuration setting which can be set by flag and environment variable
+type setting struct {
+	name  string // name of the flag; the environment variable is the prefixed, upper-cased name (dashes replaced by underscores)
+	usage string
+	bind  func(c *Config) flag.Value
+}
+
+// env is the environment variable of the setting, e.g. `ROBOT_WAIT_TIMEOUT`
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.name, "-", "_"))
+}
+
+// settings are the settings of the configuration which can be set by flags and environment variables
+// - robots beyond the first and charging stations can only be configured by the config file
+var settings = []setting{
+	{"listen", "`address` the server listens on", func(c *Config) flag.Value { return (*stringValue)(&c.Listen) }},
+	{"grpc-listen", "`address` the gRPC server listens on (empty disables the gRPC API)", func(c *Config) flag.Value { return (*stringValue)(&c.GRPCListen) }},
+	{"tls-cert", "TLS certificate `file`; enables HTTPS together with 'tls-key'", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.Cert) }},
+	{"tls-key", "TLS private key `file`; enables HTTPS together with 'tls-cert'", func(c *Config) flag.Value { return (*stringValue)(&c.TLS.Key) }},
+	{"assets-dir", "serve frontend and swagger ui from a `directory` containing 'public' and 'swaggerui' instead of the embedded files (development)", func(c *Config) flag.Value { return (*stringValue)(&c.AssetsDir) }},
+	{"idempotency-window", "`time` within which retries of a task creation request (with the same 'Idempotency-Key' header) return the original response", func(c *Config) flag.Value { return &c.IdempotencyWindow }},
+	{"shutdown-timeout", "`time` tasks in progress may take to finish on shutdown (SIGINT or SIGTERM); queued tasks are cancelled, tasks still in progress are aborted once elapsed", func(c *Config) flag.Value { return &c.ShutdownTimeout }},
+	{"id", "robot `identifier` within the warehouse", func(c *Config) flag.Value { return (*stringValue)(&c.robot().ID) }},
+	{"x", "robot initialisation x `co-ordinate`", func(c *Config) flag.Value { return (*uintValue)(&c.robot().X) }},
+	{"y", "robot initialisation y `co-ordinate`", func(c *Config) flag.Value { return (*uintValue)(&c.robot().Y) }},
+	{"priority", "robot `priority`; lower priority robots give way when resolving deadlocks", func(c *Config) flag.Value { return (*intValue)(&c.robot().Priority) }},
+	{"collision", "`behaviour` when a robot is blocked by another robot; one of 'fail', 'wait' or 'replan'", func(c *Config) flag.Value { return (*stringValue)(&c.Warehouse.Collision) }},
+	{"wait-timeout", "maximum `time` a robot waits for an occupied cell with the 'wait' collision policy (0 waits indefinitely)", func(c *Config) flag.Value { return &c.Warehouse.WaitTimeout }},
+	{"deadlock-rule", "robot selected to resolve a deadlock (`rule`); one of 'priority' (lowest priority) or 'youngest' (youngest task)", func(c *Config) flag.Value { return (*stringValue)(&c.Warehouse.DeadlockRule) }},
+	{"deadlock-resolution", "`resolution` of the robot selected to resolve a deadlock; one of 'abort' or 'replan'", func(c *Config) flag.Value { return (*stringValue)(&c.Warehouse.DeadlockResolution) }},
+	{"command-duration", "`time` a robot takes to perform each command", func(c *Config) flag.Value { return &c.Warehouse.CommandDuration }},
+	{"max-queued-tasks", "maximum `number` of tasks queued per robot (0 is limited to 1000); further tasks are rejected", func(c *Config) flag.Value { return (*intValue)(&c.Warehouse.MaxQueuedTasks) }},
+	{"battery-capacity", "battery `capacity` of the robots (0 disables the battery model); robots charge at charging stations using the 'C' command", func(c *Config) flag.Value { return (*uintValue)(&c.Warehouse.Battery.Capacity) }},
+	{"battery-move-cost", "battery `charge` drained by each move of a robot", func(c *Config) flag.Value { return (*uintValue)(&c.Warehouse.Battery.MoveCost) }},
+	{"storage", "storage `backend` of tasks; only 'memory' is supported", func(c *Config) flag.Value { return (*stringValue)(&c.Storage.Backend) }},
+	{"audit-file", "`file` the audit log of operator actions is appended to (JSON Lines); the audit log is kept in-memory only if unset", func(c *Config) flag.Value { return (*stringValue)(&c.Storage.AuditFile) }},
+	{"api-keys", "`file` of API keys ('<key> <role> [name]' per line); enables authentication", func(c *Config) flag.Value { return (*stringValue)(&c.Auth.APIKeys) }},
+	{"jwt-secret-file", "`file` containing the secret verifying HS256 signed bearer tokens; enables authentication", func(c *Config) flag.Value { return (*stringValue)(&c.Auth.JWTSecretFile) }},
+	{"mqtt-broker", "`url` of the MQTT server the robots are bridged to, e.g. 'tcp://localhost:1883'; the bridge is disabled if unset", func(c *Config) flag.Value { return (*stringValue)(&c.MQTT.Broker) }},
+	{"mqtt-client-id", "client `identifier` of the connection to the MQTT server", func(c *Config) flag.Value { return (*stringValue)(&c.MQTT.ClientID) }},
+	{"mqtt-warehouse-id", "warehouse `identifier` within the MQTT topics ('warehouse/{id}/robot/{robot}/...')", func(c *Config) flag.Value { return (*stringValue)(&c.MQTT.WarehouseID) }},
+	{"cors-origins", "comma separated `origins` allowed to call the API from browsers ('*' allows any origin)", func(c *Config) flag.Value { return (*listValue)(&c.CORS.AllowedOrigins) }},
+}
+
+// robot returns the first robot, which is configured by flags and environment variables
+func (c *Config) robot() *RobotConfig {
+	if len(c.Robots) == 0 {
+		c.Robots = []RobotConfig{{ID: "r1"}}
+	}
+	return &c.Robots[0]
+}
+
+// LoadConfig reads the configuration from a config file (`-config` flag or `ROBOT_CONFIG`), environment variables and flags
+// - flags take precedence over environment variables, which take precedence over the config file, which takes precedence over defaults
+// - printOnly is set by the `-print-config` flag, requesting the effective configuration to be printed rather than served
+func LoadConfig(fs *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (config Config, printOnly bool, err error) {
+	// flags are parsed into a separate configuration first, as they are applied last
+	flagged := DefaultConfig()
+	for _, s := range settings {
+		fs.Var(s.bind(&flagged), s.name, s.usage+fmt.Sprintf(" (env %s)", s.env()))
+	}
+	path := fs.String("config", "", fmt.Sprintf("JSON config `file` (env %sCONFIG)", envPrefix))
+	printConfig := fs.Bool("print-config", false, "print the effective configuration (JSON) and exit")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	config = DefaultConfig()
+	if *path == "" {
+		*path, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+	if *path != "" {
+		if err := readConfigFile(*path, &config); err != nil {
+			return Config{}, false, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env()); ok {
+			if err := s.bind(&config).Set(v); err != nil {
+				return Config{}, false, fmt.Errorf("invalid value '%s' of %s: %v", v, s.env(), err)
+			}
+		}
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if set[s.name] {
+			s.bind(&config).Set(s.bind(&flagged).String())
+		}
+	}
+
+	return config, *printConfig, config.Validate()
+}
+
+// readConfigFile reads a JSON config file on top of the configuration; unknown settings are rejected
+func readConfigFile(path string, config *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return fmt.Errorf("invalid config file '%s': %v", path, err)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string in config files, e.g. `"10s"`
+type Duration time.Duration
+
+// String formats the duration, e.g. `1m30s`
+// * implements flag.Value
+func (d *Duration) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set parses a duration, e.g. `1m30s`
+// * implements flag.Value
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	*d = Duration(v)
+	return err
+}
+
+// MarshalText formats the duration
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+// stringValue, uintValue, intValue and listValue bind flags (and environment variables) to configuration settings
+type (
+	stringValue string
+	uintValue   uint
+	intValue    int
+	listValue   []string
+)
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *uintValue) String() string { return strconv.FormatUint(uint64(*v), 10) }
+
+func (v *uintValue) Set(s string) error {
+	u, err := strconv.ParseUint(s, 10, 0)
+	*v = uintValue(u)
+	return err
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	*v = intValue(i)
+	return err
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+// Set parses a comma separated list; empty elements are dropped
+func (v *listValue) Set(s string) error {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	*v = list
+	return nil
+}
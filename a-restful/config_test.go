@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	ioutil.WriteFile(path, []byte(`{
+		"listen": ":9000",
+		"warehouse": {"collision": "wait", "waitTimeout": "5s", "commandDuration": "1s", "battery": {"capacity": 50}, "chargingStations": [{"x": 9, "y": 9}]},
+		"robots": [{"id": "r1", "x": 1, "y": 1}, {"id": "r2", "x": 5, "y": 5, "priority": 2}],
+		"cors": {"allowedOrigins": ["https://example.com"]}
+	}`), 0600)
+
+	load := func(args []string, env map[string]string) (Config, bool, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		return LoadConfig(fs, args, func(name string) (string, bool) {
+			v, ok := env[name]
+			return v, ok
+		})
+	}
+
+	t.Run("test defaults", func(t *testing.T) {
+		config, printOnly, err := load(nil, nil)
+		if err != nil || printOnly {
+			t.Fatalf("default configuration should be loaded; got: %v", err)
+		}
+		if config.Listen != ":8000" || len(config.Robots) != 1 || config.Robots[0].ID != "r1" || config.Warehouse.Collision != "fail" ||
+			time.Duration(config.Warehouse.WaitTimeout) != 10*time.Second {
+			t.Errorf("incorrect default configuration; got: %+v", config)
+		}
+	})
+
+	t.Run("test precedence of flags over environment variables over config file", func(t *testing.T) {
+		config, _, err := load([]string{"-config", path, "-x", "3", "-wait-timeout", "2s"}, map[string]string{
+			"ROBOT_X":            "2",
+			"ROBOT_Y":            "2",
+			"ROBOT_LISTEN":       ":9001",
+			"ROBOT_CORS_ORIGINS": "https://a.example.com, https://b.example.com",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if config.Listen != ":9001" {
+			t.Errorf("environment variable should override config file; got: %s", config.Listen)
+		}
+		if r := config.Robots[0]; r.X != 3 || r.Y != 2 {
+			t.Errorf("flag should override environment variable; got: (%d, %d), want: (3, 2)", r.X, r.Y)
+		}
+		if r := config.Robots[1]; r.ID != "r2" || r.X != 5 || r.Priority != 2 {
+			t.Errorf("further robots should be read from the config file; got: %+v", r)
+		}
+		if config.Warehouse.Collision != "wait" || time.Duration(config.Warehouse.WaitTimeout) != 2*time.Second || time.Duration(config.Warehouse.CommandDuration) != time.Second {
+			t.Errorf("incorrect warehouse configuration; got: %+v", config.Warehouse)
+		}
+		if b := config.Warehouse.Battery; b.Capacity != 50 || b.MoveCost != 1 || len(config.Warehouse.ChargingStations) != 1 {
+			t.Errorf("incorrect battery configuration; got: %+v, %v", b, config.Warehouse.ChargingStations)
+		}
+		if origins := config.CORS.AllowedOrigins; len(origins) != 2 || origins[1] != "https://b.example.com" {
+			t.Errorf("incorrect CORS origins; got: %v", origins)
+		}
+		if config.Storage.Backend != "memory" {
+			t.Errorf("settings missing from the config file should be defaulted; got: %+v", config.Storage)
+		}
+	})
+
+	t.Run("test config file from environment variable", func(t *testing.T) {
+		config, printOnly, err := load([]string{"-print-config"}, map[string]string{"ROBOT_CONFIG": path})
+		if err != nil || !printOnly || config.Listen != ":9000" {
+			t.Errorf("config file should be read; got: %+v, %v", config, err)
+		}
+	})
+
+	t.Run("test invalid configuration", func(t *testing.T) {
+		unknown := filepath.Join(t.TempDir(), "unknown.json")
+		ioutil.WriteFile(unknown, []byte(`{"lisen": ":9000"}`), 0600)
+		station := filepath.Join(t.TempDir(), "station.json")
+		ioutil.WriteFile(station, []byte(`{"warehouse": {"chargingStations": [{"x": 10, "y": 0}]}}`), 0600)
+
+		for name, args := range map[string][]string{
+			"unknown setting":  {"-config", unknown},
+			"charging station": {"-config", station},
+			"missing file":     {"-config", filepath.Join(t.TempDir(), "missing.json")},
+			"out of bounds":    {"-y", "10"},
+			"collision policy": {"-collision", "crash"},
+			"storage backend":  {"-storage", "postgres"},
+			"incomplete tls":   {"-tls-cert", "cert.pem"},
+			"negative queue":   {"-max-queued-tasks", "-1"},
+			"invalid duration": {"-shutdown-timeout", "soon"},
+			"same addresses":   {"-grpc-listen", ":8000"},
+			"mqtt warehouse":   {"-mqtt-broker", "tcp://localhost:1883", "-mqtt-warehouse-id", "w/1"},
+		} {
+			if _, _, err := load(args, nil); err == nil {
+				t.Errorf("%s should be rejected: %v", name, args)
+			}
+		}
+
+		if _, _, err := load(nil, map[string]string{"ROBOT_X": "east"}); err == nil {
+			t.Error("invalid environment variable should be rejected")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// Names of robot events; server-sent events are named accordingly
+const (
+	EventState    = "robotstate"
+	EventError    = "roboterror"
+	EventDeadlock = "deadlock"
+	EventShutdown = "shutdown"
+	EventTask     = "task"
+)
+
+// RobotEvent is an event of a robot: a state change, a failed task, a completed task, a deadlock of the warehouse or the robot shutting down
+type RobotEvent struct {
+	Name     string
+	State    RobotState    // state of a `robotstate` event
+	Err      error         // error of a `roboterror` or `shutdown` event; error of the failed task of a `task` event
+	Deadlock DeadlockEvent // deadlock of a `deadlock` event
+	Task     Task          // executed task of a `task` event
+}
+
+// eventBuffer is the number of events buffered per subscriber; subscribers which fall behind are unsubscribed rather than
+// missing events, so they can resubscribe and resync
+const eventBuffer = 64
+
+// eventHub fans the events of a robot out to its subscribers (server-sent events, gRPC watchers)
+// - unlike the `States` and `Errors` channels of a bot, every subscriber receives every event
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan RobotEvent]struct{}
+	final       *RobotEvent // the last event, once the hub is closed
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan RobotEvent]struct{})}
+}
+
+// subscribe returns a channel of the events published from now on, which is closed once the hub is closed (after the final event)
+// - cancel unsubscribes, closing the channel
+func (h *eventHub) subscribe() (events <-chan RobotEvent, cancel func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan RobotEvent, eventBuffer)
+	if h.final != nil {
+		ch <- *h.final
+		close(ch)
+		return ch, func() {}
+	}
+	h.subscribers[ch] = struct{}{}
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends an event to all subscribers without blocking
+// - subscribers whose buffer is full are unsubscribed, closing their channel without a final event
+func (h *eventHub) publish(event RobotEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("unsubscribed a subscriber falling behind at %s event", event.Name)
+			close(ch)
+			delete(h.subscribers, ch)
+		}
+	}
+}
+
+// closed reports whether the hub is closed; channels closed before are closed as their subscriber fell behind
+func (h *eventHub) closed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.final != nil
+}
+
+// close sends the final event to all subscribers and closes their channels; later subscribers only receive the final event
+func (h *eventHub) close(final RobotEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.final != nil {
+		return
+	}
+	h.final = &final
+	for ch := range h.subscribers {
+		select {
+		case ch <- final:
+		default:
+		}
+		close(ch)
+		delete(h.subscribers, ch)
+	}
+}
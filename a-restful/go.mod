@@ -1,9 +1,14 @@
 module github.com/zees-dev/robot-challenge/a-restful
 
-go 1.15
+go 1.16
 
 require (
+	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/gorilla/mux v1.8.0
 	github.com/satori/go.uuid v1.2.0
-	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	github.com/zees-dev/robot-challenge/b-librobot v0.0.0
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.31.0
 )
+
+replace github.com/zees-dev/robot-challenge/b-librobot => ../b-librobot
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/zees-dev/robot-challenge/a-restful/robotpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpcRoles are the roles required by the RPCs which are not read-only; other RPCs require the viewer role
+var grpcRoles = map[string]Role{
+	robotpb.RobotService_EnqueueTask_FullMethodName: RoleOperator,
+	robotpb.RobotService_CancelTask_FullMethodName:  RoleOperator,
+}
+
+// WithGRPCOptions configures the gRPC server, e.g. with TLS credentials (`grpc.Creds`)
+// - ignored by the RESTful API
+func WithGRPCOptions(options ...grpc.ServerOption) ServerOption {
+	return func(opts *serverOptions) {
+		opts.grpcOptions = append(opts.grpcOptions, options...)
+	}
+}
+
+// NewGRPCServer creates a gRPC server of the robot API (see `robotpb/robot.proto`), mirroring the RESTful API of RobotAPIServer
+// - requests are authenticated by the `x-api-key` or `authorization` metadata, if the server is configured with an authenticator
+// - requests are identified by the `x-request-id` metadata (or a generated ID), which is sent back as header metadata
+// - tasks created and cancelled are recorded in the audit log; share the audit log with the RESTful API to record all actions
+// - the assets, CORS, idempotency and response validation options only apply to the RESTful API
+func NewGRPCServer(robot *Bot, options ...ServerOption) *grpc.Server {
+	opts := serverOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+	if opts.auditLog == nil {
+		opts.auditLog = NewInMemoryAuditLog(nil)
+	}
+
+	service := &robotService{robot: robot, audit: auditor{opts.auditLog}, authenticator: opts.authenticator}
+	server := grpc.NewServer(append([]grpc.ServerOption{
+		grpc.UnaryInterceptor(service.unaryInterceptor),
+		grpc.StreamInterceptor(service.streamInterceptor),
+	}, opts.grpcOptions...)...)
+	robotpb.RegisterRobotServiceServer(server, service)
+	return server
+}
+
+// robotService serves the robot (and the warehouse it operates within) over gRPC
+// * implements robotpb.RobotServiceServer
+type robotService struct {
+	robotpb.UnimplementedRobotServiceServer
+
+	robot         *Bot
+	audit         auditor
+	authenticator *Authenticator
+}
+
+// authorize identifies, authenticates and authorizes a call by its metadata; the request ID and principal are added to the context
+func (s *robotService) authorize(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstValue(md, "x-request-id")
+	if id == "" || len(id) > 128 {
+		id = uuid.NewV4().String()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", id))
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+	principal, err := s.authenticator.credentials(firstValue(md, "x-api-key"), firstValue(md, "authorization"))
+	if err != nil {
+		return nil, grpcError(codes.Unauthenticated, CodeUnauthenticated, err)
+	}
+	required, ok := grpcRoles[method]
+	if !ok {
+		required = RoleViewer
+	}
+	if principal.Role < required {
+		err := fmt.Errorf("%w: %s '%s' requires the %s role", ErrForbidden, principal.Role, principal.Name, required)
+		return nil, grpcError(codes.PermissionDenied, CodeForbidden, err)
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+func (s *robotService) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *robotService) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, contextStream{ss, ctx})
+}
+
+// contextStream is a server stream of an authorized call
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// robotByID returns a robot of the warehouse; the robot of the server if the ID is empty
+func (s *robotService) robotByID(id string) (*Bot, error) {
+	if id == "" || id == s.robot.id {
+		return s.robot, nil
+	}
+	if s.robot.warehouse == nil {
+		return nil, grpcError(codes.NotFound, CodeRobotNotFound, fmt.Errorf("Robot with ID '%s' not found", id))
+	}
+	bot, err := s.robot.warehouse.Robot(id)
+	if err != nil {
+		return nil, grpcError(codes.NotFound, CodeRobotNotFound, err)
+	}
+	return bot, nil
+}
+
+// EnqueueTask validates the command sequence and queues it as a task of the robot
+func (s *robotService) EnqueueTask(ctx context.Context, req *robotpb.EnqueueTaskRequest) (*robotpb.EnqueueTaskResponse, error) {
+	robot, err := s.robotByID(req.RobotId)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCommandSequence(req.Commands); err != nil {
+		return nil, grpcError(codes.InvalidArgument, CodeInvalidCommandSequence, err)
+	}
+
+	taskID, _, _, err := robot.enqueue(req.Commands, PrincipalFrom(ctx).Name)
+	if errors.Is(err, ErrQueueFull) {
+		return nil, grpcError(codes.ResourceExhausted, CodeQueueFull, err)
+	}
+	if err != nil {
+		return nil, grpcError(codes.Unavailable, CodeShuttingDown, err)
+	}
+	s.audit.recordContext(ctx, peerIP(ctx), AuditEntry{Action: AuditTaskCreate, Robot: robot.id, Task: taskID, Detail: req.Commands})
+
+	return &robotpb.EnqueueTaskResponse{TaskId: taskID}, nil
+}
+
+// CancelTask cancels a queued task; cancelling a cancelled task succeeds, so cancellations can be retried safely
+func (s *robotService) CancelTask(ctx context.Context, req *robotpb.CancelTaskRequest) (*robotpb.Task, error) {
+	owner := taskOwner(s.robot, req.TaskId)
+	err := owner.CancelTask(req.TaskId)
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		return nil, grpcError(codes.NotFound, CodeTaskNotFound, err)
+	case errors.Is(err, ErrTaskRunning):
+		return nil, grpcError(codes.FailedPrecondition, CodeTaskRunning, err)
+	case errors.Is(err, ErrTaskFinished):
+		return nil, grpcError(codes.FailedPrecondition, CodeTaskFinished, err)
+	case err != nil && !errors.Is(err, ErrTaskCancelled):
+		return nil, grpcError(codes.Internal, CodeTaskFailed, err)
+	}
+
+	if err == nil {
+		s.audit.recordContext(ctx, peerIP(ctx), AuditEntry{Action: AuditTaskCancel, Robot: owner.id, Task: req.TaskId})
+	}
+
+	task, err := owner.repository.GetTask(req.TaskId)
+	if err != nil {
+		return nil, grpcError(codes.NotFound, CodeTaskNotFound, err)
+	}
+	return newTaskMessage(task), nil
+}
+
+// GetTask gets the execution status of a task
+func (s *robotService) GetTask(ctx context.Context, req *robotpb.GetTaskRequest) (*robotpb.Task, error) {
+	task, err := s.robot.repository.GetTask(req.TaskId)
+	if err != nil {
+		return nil, grpcError(codes.NotFound, CodeTaskNotFound, err)
+	}
+	return newTaskMessage(task), nil
+}
+
+// ListRobots lists the robots of the warehouse; only the robot of the server if it does not operate within a warehouse
+func (s *robotService) ListRobots(ctx context.Context, req *robotpb.ListRobotsRequest) (*robotpb.ListRobotsResponse, error) {
+	res := &robotpb.ListRobotsResponse{}
+	if s.robot.warehouse == nil {
+		res.Robots = append(res.Robots, newRobotMessage(s.robot, s.robot.CurrentState()))
+		return res, nil
+	}
+	for _, robot := range s.robot.warehouse.Robots() {
+		res.Robots = append(res.Robots, newRobotMessage(robot.(*Bot), robot.CurrentState()))
+	}
+	return res, nil
+}
+
+// GetRobotState gets the current state of a robot
+func (s *robotService) GetRobotState(ctx context.Context, req *robotpb.GetRobotStateRequest) (*robotpb.Robot, error) {
+	robot, err := s.robotByID(req.RobotId)
+	if err != nil {
+		return nil, err
+	}
+	return newRobotMessage(robot, robot.CurrentState()), nil
+}
+
+// WatchRobot streams the events of a robot (the events of `/api/v1/state/subscribe`) until the client cancels the call
+// - the stream ends with a shutdown event once the robot is shutting down
+// - the call is aborted if the client falls behind the events of the robot, so it can watch again and resync
+func (s *robotService) WatchRobot(req *robotpb.WatchRobotRequest, stream robotpb.RobotService_WatchRobotServer) error {
+	robot, err := s.robotByID(req.RobotId)
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := robot.events.subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok && !robot.events.closed() {
+				return status.Errorf(codes.Aborted, "fell behind the events of robot '%s'; watch the robot again to resync", robot.id)
+			}
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(newEventMessage(robot, event)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
+
+// newTaskMessage converts a task to its execution status
+func newTaskMessage(task Task) *robotpb.Task {
+	return &robotpb.Task{
+		Id:        task.id,
+		Command:   task.command,
+		Executed:  task.executed,
+		Cancelled: task.cancelled,
+		Success:   task.success,
+		CreatedBy: task.createdBy,
+	}
+}
+
+// newRobotMessage converts a state of a robot to a message; the battery is only set if the warehouse models batteries
+func newRobotMessage(robot *Bot, state RobotState) *robotpb.Robot {
+	msg := &robotpb.Robot{Id: robot.id, X: uint32(state.X), Y: uint32(state.Y)}
+	if battery := newStateResponse(robot, state).Battery; battery != nil {
+		msg.Battery = proto.Uint32(uint32(*battery))
+	}
+	return msg
+}
+
+// newProblemMessage converts problem details to a message
+func newProblemMessage(problem Problem) *robotpb.Problem {
+	return &robotpb.Problem{Code: string(problem.Code), Title: problem.Title, Detail: problem.Detail}
+}
+
+// newEventMessage converts an event of a robot to a message; errors are problems, like the bodies of server-sent events
+func newEventMessage(robot *Bot, event RobotEvent) *robotpb.RobotEvent {
+	switch event.Name {
+	case EventState:
+		return &robotpb.RobotEvent{Event: &robotpb.RobotEvent_State{State: newRobotMessage(robot, event.State)}}
+	case EventDeadlock:
+		return &robotpb.RobotEvent{Event: &robotpb.RobotEvent_Deadlock{Deadlock: &robotpb.Deadlock{
+			RobotIds:      event.Deadlock.RobotIDs,
+			TaskIds:       event.Deadlock.TaskIDs,
+			VictimRobotId: event.Deadlock.VictimRobotID,
+			VictimTaskId:  event.Deadlock.VictimTaskID,
+			Resolution:    event.Deadlock.Resolution.String(),
+		}}}
+	case EventShutdown:
+		return &robotpb.RobotEvent{Event: &robotpb.RobotEvent_Shutdown{
+			Shutdown: newProblemMessage(newProblem(CodeShuttingDown, 0, event.Err)),
+		}}
+	case EventTask:
+		completed := &robotpb.TaskCompleted{RobotId: robot.id, Task: newTaskMessage(event.Task)}
+		if event.Err != nil {
+			completed.Problem = newProblemMessage(taskProblem(event.Err))
+		}
+		return &robotpb.RobotEvent{Event: &robotpb.RobotEvent_Task{Task: completed}}
+	}
+	return &robotpb.RobotEvent{Event: &robotpb.RobotEvent_Error{Error: newProblemMessage(taskProblem(event.Err))}}
+}
+
+// grpcError creates the status of an error; the status carries the problem (code, title and detail) of the RESTful API
+func grpcError(c codes.Code, code ErrorCode, err error) error {
+	st := status.New(c, err.Error())
+	if detailed, derr := st.WithDetails(newProblemMessage(newProblem(code, 0, err))); derr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// firstValue returns the first value of a metadata key
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// peerIP returns the IP address of the client of a call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/a-restful/robotpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// getGRPCClient serves the gRPC API of the robot over an in-process listener
+func getGRPCClient(t *testing.T, robot *Bot, options ...ServerOption) robotpb.RobotServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	server := NewGRPCServer(robot, options...)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return robotpb.NewRobotServiceClient(conn)
+}
+
+// grpcProblem returns the problem carried by the status of an error, if any
+func grpcProblem(err error) (*robotpb.Problem, bool) {
+	for _, detail := range status.Convert(err).Details() {
+		if problem, ok := detail.(*robotpb.Problem); ok {
+			return problem, true
+		}
+	}
+	return nil, false
+}
+
+// assertGRPCError checks the status code and problem code of an error
+func assertGRPCError(t *testing.T, err error, c codes.Code, code ErrorCode) {
+	t.Helper()
+	if got := status.Code(err); got != c {
+		t.Errorf("incorrect status code; got: %v, want: %v", got, c)
+	}
+	if problem, ok := grpcProblem(err); !ok || problem.Code != string(code) {
+		t.Errorf("incorrect problem; got: %v, want: %s", problem, code)
+	}
+}
+
+func TestGRPCTasks(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	auditLog := NewInMemoryAuditLog(nil)
+	client := getGRPCClient(t, robot, WithAuditLog(auditLog))
+	ctx := context.Background()
+
+	t.Run("test enqueue and get task", func(t *testing.T) {
+		var header metadata.MD
+		res, err := client.EnqueueTask(metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-1"), &robotpb.EnqueueTaskRequest{Commands: "N E"}, grpc.Header(&header))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-1" {
+			t.Errorf("request ID should be sent back; got: %v", got)
+		}
+
+		var task *robotpb.Task
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if task, err = client.GetTask(ctx, &robotpb.GetTaskRequest{TaskId: res.TaskId}); err != nil || task.Executed {
+				break
+			}
+		}
+		if err != nil || !task.Executed || !task.Success || task.Command != "N E" || task.CreatedBy != "anonymous" {
+			t.Errorf("task should be executed; got: %v, %v", task, err)
+		}
+
+		entries, _ := auditLog.Entries(AuditFilter{Action: AuditTaskCreate})
+		if len(entries) != 1 || entries[0].Task != res.TaskId || entries[0].RequestID != "req-1" || entries[0].Robot != "r1" {
+			t.Errorf("task creation should be audited; got: %+v", entries)
+		}
+
+		_, err = client.CancelTask(ctx, &robotpb.CancelTaskRequest{TaskId: res.TaskId})
+		assertGRPCError(t, err, codes.FailedPrecondition, CodeTaskFinished)
+	})
+
+	t.Run("test invalid command sequence", func(t *testing.T) {
+		_, err := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N X"})
+		assertGRPCError(t, err, codes.InvalidArgument, CodeInvalidCommandSequence)
+	})
+
+	t.Run("test unknown robot", func(t *testing.T) {
+		_, err := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{RobotId: "r9", Commands: "N"})
+		assertGRPCError(t, err, codes.NotFound, CodeRobotNotFound)
+	})
+
+	t.Run("test unknown task", func(t *testing.T) {
+		_, err := client.GetTask(ctx, &robotpb.GetTaskRequest{TaskId: "missing"})
+		assertGRPCError(t, err, codes.NotFound, CodeTaskNotFound)
+		_, err = client.CancelTask(ctx, &robotpb.CancelTaskRequest{TaskId: "missing"})
+		assertGRPCError(t, err, codes.NotFound, CodeTaskNotFound)
+	})
+}
+
+func TestGRPCCancelTask(t *testing.T) {
+	robot := NewBot(0, 0, NewInMemoryDB())
+	go func() {
+		for range robot.tasks {
+		}
+	}() // prevent channel blocking
+	client := getGRPCClient(t, &robot)
+	ctx := context.Background()
+
+	res, err := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		task, err := client.CancelTask(ctx, &robotpb.CancelTaskRequest{TaskId: res.TaskId})
+		if err != nil || !task.Cancelled || task.Executed {
+			t.Errorf("queued task should be cancelled (attempt %d); got: %v, %v", i+1, task, err)
+		}
+	}
+
+	running, _ := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N"})
+	robot.running = running.TaskId
+	_, err = client.CancelTask(ctx, &robotpb.CancelTaskRequest{TaskId: running.TaskId})
+	assertGRPCError(t, err, codes.FailedPrecondition, CodeTaskRunning)
+}
+
+func TestGRPCRobots(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	warehouse.AddRobot("r2", 5, 6, 0, NewInMemoryDB())
+	client := getGRPCClient(t, robot)
+	ctx := context.Background()
+
+	res, err := client.ListRobots(ctx, &robotpb.ListRobotsRequest{})
+	if err != nil || len(res.Robots) != 2 || res.Robots[1].Id != "r2" || res.Robots[1].X != 5 || res.Robots[1].Y != 6 {
+		t.Errorf("robots of the warehouse should be listed; got: %v, %v", res, err)
+	}
+
+	state, err := client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{RobotId: "r2"})
+	if err != nil || state.X != 5 || state.Y != 6 {
+		t.Errorf("incorrect robot state; got: %v, %v", state, err)
+	}
+	state, err = client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{})
+	if err != nil || state.Id != "r1" {
+		t.Errorf("robot of the server should be the default robot; got: %v, %v", state, err)
+	}
+	if err != nil || state.Battery != nil {
+		t.Errorf("battery should not be set without a battery model; got: %v, %v", state, err)
+	}
+	_, err = client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{RobotId: "r9"})
+	assertGRPCError(t, err, codes.NotFound, CodeRobotNotFound)
+
+	warehouse.SetBattery(BatteryModel{Capacity: 10, MoveCost: 1})
+	warehouse.AddRobot("r3", 9, 9, 0, NewInMemoryDB())
+	state, err = client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{RobotId: "r3"})
+	if err != nil || state.Battery == nil || state.GetBattery() != 10 {
+		t.Errorf("battery of the robot should be set; got: %v, %v", state, err)
+	}
+}
+
+func TestGRPCWatchRobot(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	client := getGRPCClient(t, robot)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.WatchRobot(ctx, &robotpb.WatchRobotRequest{RobotId: "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the subscription is established by the server once the call is received
+	for subscribed := false; !subscribed; time.Sleep(time.Millisecond) {
+		robot.events.mu.Lock()
+		subscribed = len(robot.events.subscribers) > 0
+		robot.events.mu.Unlock()
+	}
+
+	t.Run("test state event", func(t *testing.T) {
+		client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N"})
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state := event.GetState(); state == nil || state.Id != "r1" || state.X != 0 || state.Y != 1 {
+			t.Errorf("incorrect state event; got: %v", event)
+		}
+		event, err = stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task := event.GetTask(); task == nil || !task.Task.Success || task.Problem != nil {
+			t.Errorf("state event should be followed by task event; got: %v", event)
+		}
+	})
+
+	t.Run("test error event", func(t *testing.T) {
+		client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "S S"})
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problem := event.GetError(); problem == nil || problem.Code != string(CodeOutOfBounds) {
+			t.Errorf("incorrect error event; got: %v", event)
+		}
+		event, err = stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task := event.GetTask(); task == nil || task.Task.Success || task.Problem.GetCode() != string(CodeOutOfBounds) {
+			t.Errorf("error event should be followed by failed task event; got: %v", event)
+		}
+	})
+
+	t.Run("test stream ends with shutdown event", func(t *testing.T) {
+		go warehouse.Shutdown(ctx)
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problem := event.GetShutdown(); problem == nil || problem.Code != string(CodeShuttingDown) {
+			t.Errorf("incorrect shutdown event; got: %v", event)
+		}
+		if _, err := stream.Recv(); err != io.EOF {
+			t.Errorf("stream should end after the shutdown event; got: %v", err)
+		}
+	})
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	robot := NewBot(0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	client := getGRPCClient(t, &robot, WithAuthenticator(NewAuthenticator(map[string]Principal{
+		"viewer-key":   {"dashboard", RoleViewer},
+		"operator-key": {"ground-station", RoleOperator},
+	}, nil)))
+
+	tests := []struct {
+		name string
+		md   []string
+		call func(ctx context.Context) error
+		code codes.Code
+	}{
+		{"state without credentials", nil, func(ctx context.Context) error {
+			_, err := client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{})
+			return err
+		}, codes.Unauthenticated},
+		{"state with invalid key", []string{"x-api-key", "wrong"}, func(ctx context.Context) error {
+			_, err := client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{})
+			return err
+		}, codes.Unauthenticated},
+		{"state as viewer", []string{"x-api-key", "viewer-key"}, func(ctx context.Context) error {
+			_, err := client.GetRobotState(ctx, &robotpb.GetRobotStateRequest{})
+			return err
+		}, codes.OK},
+		{"enqueue as viewer", []string{"x-api-key", "viewer-key"}, func(ctx context.Context) error {
+			_, err := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N"})
+			return err
+		}, codes.PermissionDenied},
+		{"enqueue as operator", []string{"x-api-key", "operator-key"}, func(ctx context.Context) error {
+			_, err := client.EnqueueTask(ctx, &robotpb.EnqueueTaskRequest{Commands: "N"})
+			return err
+		}, codes.OK},
+		{"watch without credentials", nil, func(ctx context.Context) error {
+			stream, err := client.WatchRobot(ctx, &robotpb.WatchRobotRequest{})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run("test "+tt.name, func(t *testing.T) {
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tt.md...)
+			if got := status.Code(tt.call(ctx)); got != tt.code {
+				t.Errorf("incorrect status code; got: %v, want: %v", got, tt.code)
+			}
+		})
+	}
+}
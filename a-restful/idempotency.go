@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+)
+
+// idempotencyKeyHeader identifies retries of a request; see https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyParam documents the idempotency key header of task creation endpoints
+var idempotencyKeyParam = param{
+	name:        idempotencyKeyHeader,
+	description: "Unique key (at most 255 characters) of the request; retries with the same key within the idempotency window (24 hours by default) return the original response instead of queueing another task.",
+}
+
+// maxIdempotencyKey is the maximum length of an idempotency key
+const maxIdempotencyKey = 255
+
+// idempotent performs a request at most once per idempotency key; retries (with the same key) replay the response of the first request
+// - keys are scoped to the principal, so clients cannot replay responses of each other
+// - only successful responses are stored; otherwise the key is released, so the request can be retried
+// - a retry while the first request is in progress results in a `409`, reusing a key for a different request in a `422`
+func idempotent(store IdempotencyStore, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Errorf("%s must not exceed %d characters", idempotencyKeyHeader, maxIdempotencyKey))
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Errorf("failed to read request body"))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		scoped := PrincipalFrom(r.Context()).Name + ":" + key
+		hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(hash[:])
+
+		record, reserved, err := store.Reserve(scoped, fingerprint)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, CodeIdempotencyUnavailable, err)
+			return
+		}
+		if !reserved {
+			switch {
+			case record.Fingerprint != fingerprint:
+				writeError(w, r, http.StatusUnprocessableEntity, CodeIdempotencyKeyMismatch, fmt.Errorf("%s '%s' has already been used for a different request", idempotencyKeyHeader, key))
+			case record.Response == nil:
+				writeError(w, r, http.StatusConflict, CodeIdempotencyKeyInUse, fmt.Errorf("request of %s '%s' is in progress", idempotencyKeyHeader, key))
+			default:
+				w.Header().Set("Content-Type", record.Response.ContentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.Response.Status)
+				w.Write(record.Response.Body)
+			}
+			return
+		}
+
+		rec := &teeRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		if rec.status < 200 || rec.status >= 300 {
+			err = store.Release(scoped)
+		} else {
+			err = store.Complete(scoped, IdempotentResponse{rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes()})
+		}
+		if err != nil {
+			log.Printf("failed to store response of %s '%s': %v", idempotencyKeyHeader, key, err)
+		}
+	}
+}
+
+// teeRecorder records the status and body of a response while writing it
+type teeRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *teeRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *teeRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
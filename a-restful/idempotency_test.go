@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go func() {
+		for range robot.tasks {
+		}
+	}() // tasks remain queued, so they can be counted
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewInMemoryIdempotencyStore(time.Hour)
+	store.now = func() time.Time { return now }
+	auditLog := NewInMemoryAuditLog(nil)
+	handler := RobotAPIServer(robot, WithResponseValidation(), WithIdempotencyStore(store), WithAuditLog(auditLog), WithAuthenticator(NewAuthenticator(map[string]Principal{
+		"operator-key": {"ground-station", RoleOperator},
+		"admin-key":    {"admin", RoleAdmin},
+	}, nil)))
+
+	serve := func(path, body, key, idempotencyKey string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", path, bytes.NewBufferString(body))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	taskID := func(rr *httptest.ResponseRecorder) string {
+		var res TaskIDResponse
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return res.TaskID
+	}
+	problem := func(rr *httptest.ResponseRecorder) Problem {
+		var res Problem
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		return res
+	}
+
+	t.Run("test retry returns the original response", func(t *testing.T) {
+		first := serve("/api/v1/state", `{"commands":"N E"}`, "operator-key", "retry-1")
+		retry := serve("/api/v1/state", `{"commands":"N E"}`, "operator-key", "retry-1")
+		if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got: %v, %v, want: %v", first.Code, retry.Code, http.StatusOK)
+		}
+		if taskID(first) == "" || taskID(retry) != taskID(first) {
+			t.Errorf("retry should return the original task; got: %s, want: %s", taskID(retry), taskID(first))
+		}
+		if first.Header().Get("Idempotent-Replayed") != "" || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("only the retry should be marked as replayed; got: '%s', '%s'", first.Header().Get("Idempotent-Replayed"), retry.Header().Get("Idempotent-Replayed"))
+		}
+		if queued := robot.queuedTasks(); len(queued) != 1 {
+			t.Errorf("a single task should be queued; got: %v", queued)
+		}
+		if entries, _ := auditLog.Entries(AuditFilter{Action: AuditTaskCreate}); len(entries) != 1 {
+			t.Errorf("a single task creation should be audited; got: %+v", entries)
+		}
+	})
+
+	t.Run("test key is scoped to the principal", func(t *testing.T) {
+		rr := serve("/api/v1/state", `{"commands":"N E"}`, "admin-key", "retry-1")
+		if rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("request of another principal should not be replayed; got: %v %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("test key reused for a different request", func(t *testing.T) {
+		rr := serve("/api/v1/state", `{"commands":"S"}`, "operator-key", "retry-1")
+		if rr.Code != http.StatusUnprocessableEntity || problem(rr).Code != CodeIdempotencyKeyMismatch {
+			t.Errorf("handler returned wrong response: got: %v %s, want: %v", rr.Code, rr.Body.String(), http.StatusUnprocessableEntity)
+		}
+
+		rr = serve("/api/v1/robots/r1/state", `{"commands":"N E"}`, "operator-key", "retry-1")
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("key reused for another endpoint should be rejected; got: %v, want: %v", rr.Code, http.StatusUnprocessableEntity)
+		}
+	})
+
+	t.Run("test failed request releases the key", func(t *testing.T) {
+		rr := serve("/api/v1/robots/r1/state", `{"commands":"N X"}`, "operator-key", "retry-2")
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got: %v, want: %v", rr.Code, http.StatusBadRequest)
+		}
+		rr = serve("/api/v1/robots/r1/state", `{"commands":"N"}`, "operator-key", "retry-2")
+		if rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" {
+			t.Errorf("corrected request should be performed; got: %v %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("test key expires after the window", func(t *testing.T) {
+		first := serve("/api/v1/state", `{"commands":"W"}`, "operator-key", "retry-3")
+		now = now.Add(time.Hour)
+		retry := serve("/api/v1/state", `{"commands":"W"}`, "operator-key", "retry-3")
+		if retry.Code != http.StatusOK || taskID(retry) == taskID(first) {
+			t.Errorf("retry after the window should queue another task; got: %v %s", retry.Code, retry.Body.String())
+		}
+	})
+
+	t.Run("test invalid key", func(t *testing.T) {
+		rr := serve("/api/v1/state", `{"commands":"N"}`, "operator-key", strings.Repeat("k", maxIdempotencyKey+1))
+		if rr.Code != http.StatusBadRequest || problem(rr).Code != CodeInvalidRequest {
+			t.Errorf("handler returned wrong response: got: %v %s, want: %v", rr.Code, rr.Body.String(), http.StatusBadRequest)
+		}
+	})
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := idempotent(NewInMemoryIdempotencyStore(time.Hour), func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		writeJSON(w, http.StatusOK, TaskIDResponse{TaskID: "task-1"})
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/api/v1/state", bytes.NewBufferString(`{"commands":"N"}`))
+		req.Header.Set(idempotencyKeyHeader, "retry-1")
+		handler(rr, req)
+		return rr
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve() }()
+	<-started
+
+	if rr := serve(); rr.Code != http.StatusConflict {
+		t.Errorf("retry of a request in progress should conflict; got: %v, want: %v", rr.Code, http.StatusConflict)
+	}
+	close(release)
+	if rr := <-done; rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got: %v, want: %v", rr.Code, http.StatusOK)
+	}
+	if rr := serve(); rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry of a completed request should be replayed; got: %v %s", rr.Code, rr.Body.String())
+	}
+}
@@ -1,36 +1,203 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	xPtr := flag.Uint("x", 0, "robot initialisation x co-ordinate")
-	yPtr := flag.Uint("y", 0, "robot initialisation y co-ordinate")
-	xDimension, yDimension := uint(10), uint(10)
-	flag.Parse()
-
-	x, y := *xPtr, *yPtr
-	if x >= xDimension {
-		log.Fatalf("Invalid robot x position; x co-ordinate must satisfy 0 <= x < %d", xDimension)
+	config, printOnly, err := LoadConfig(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		log.Fatal(err)
 	}
-	if y >= yDimension {
-		log.Fatalf("Invalid robot x position; y co-ordinate must satisfy 0 <= y < %d", yDimension)
+	if printOnly {
+		data, _ := json.MarshalIndent(config, "", "  ")
+		os.Stdout.Write(append(data, '\n'))
+		return
 	}
 
+	// settings are validated by `LoadConfig`
+	policy, _ := ParseCollisionPolicy(config.Warehouse.Collision)
+	deadlockRule, _ := ParseDeadlockRule(config.Warehouse.DeadlockRule)
+	deadlockResolution, _ := ParseDeadlockResolution(config.Warehouse.DeadlockResolution)
+
 	db := NewInMemoryDB()
+	warehouse := NewRobotWarehouse(policy, time.Duration(config.Warehouse.WaitTimeout))
+	warehouse.SetDeadlockPolicy(deadlockRule, deadlockResolution)
+	warehouse.SetCommandDuration(time.Duration(config.Warehouse.CommandDuration))
+	warehouse.SetMaxQueuedTasks(config.Warehouse.MaxQueuedTasks)
+	battery := config.Warehouse.Battery
+	warehouse.SetBattery(BatteryModel{Capacity: battery.Capacity, MoveCost: battery.MoveCost})
+	for _, p := range config.Warehouse.ChargingStations {
+		warehouse.AddChargingStation(p.X, p.Y) // validated by `LoadConfig`
+	}
+
+	// the first robot is served at `/api/v1/state`; all robots are served at `/api/v1/robots`
+	var robot *Bot
+	for _, rc := range config.Robots {
+		bot, err := warehouse.AddRobot(rc.ID, rc.X, rc.Y, rc.Priority, db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go bot.listen(context.Background()) // stopped by shutting down the warehouse
+		log.Printf("Initialising robot '%s' at (%d, %d)...", rc.ID, rc.X, rc.Y)
+		if robot == nil {
+			robot = bot
+		}
+	}
+
+	if config.AssetsDir != "" {
+		if _, err := newAssets(config.AssetsDir); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Serving assets from '%s'...", config.AssetsDir)
+	}
+
+	storage := []interface{}{db}
+	options := []ServerOption{
+		WithAssetsDir(config.AssetsDir),
+		WithIdempotencyStore(NewInMemoryIdempotencyStore(time.Duration(config.IdempotencyWindow))),
+	}
+	if len(config.CORS.AllowedOrigins) > 0 {
+		options = append(options, WithCORS(config.CORS.AllowedOrigins))
+	}
+	if config.Auth.APIKeys != "" || config.Auth.JWTSecretFile != "" {
+		keys := map[string]Principal{}
+		if config.Auth.APIKeys != "" {
+			if keys, err = LoadAPIKeys(config.Auth.APIKeys); err != nil {
+				log.Fatal(err)
+			}
+		}
+		var secret []byte
+		if config.Auth.JWTSecretFile != "" {
+			if secret, err = ioutil.ReadFile(config.Auth.JWTSecretFile); err != nil {
+				log.Fatal(err)
+			}
+			secret = bytes.TrimSpace(secret)
+		}
+		options = append(options, WithAuthenticator(NewAuthenticator(keys, secret)))
+		log.Printf("Authentication enabled (%d API keys, bearer tokens %t)...", len(keys), len(secret) > 0)
+	}
+
+	// the RESTful and gRPC APIs record operator actions in the same audit log
+	var auditLog AuditLog = NewInMemoryAuditLog(nil)
+	if config.Storage.AuditFile != "" {
+		fileLog, f, err := OpenAuditLogFile(config.Storage.AuditFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		auditLog = fileLog
+		storage = append(storage, auditLog)
+		log.Printf("Appending audit log to '%s'...", config.Storage.AuditFile)
+	}
+	options = append(options, WithAuditLog(auditLog))
+
+	// the robots of the warehouse are bridged to an MQTT server, e.g. for robots in the field
+	var bridge *MQTTBridge
+	if config.MQTT.Broker != "" {
+		broker, err := DialMQTT(config.MQTT.Broker, config.MQTT.ClientID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer broker.Close()
+		if bridge, err = NewMQTTBridge(config.MQTT.WarehouseID, warehouse, broker, auditLog); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Bridging robots to MQTT server as warehouse '%s'...", config.MQTT.WarehouseID)
+	}
+
+	router := RobotAPIServer(robot, options...)
 
-	robot := NewBot(x, y, db)
-	go robot.listen()
-	log.Printf("Initialising robot at (%d, %d)...", x, y)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{Addr: config.Listen, Handler: router}
+	go func() {
+		var err error
+		if config.TLS.Cert != "" {
+			log.Printf("Starting admin server on %s (HTTPS)...", config.Listen)
+			err = server.ListenAndServeTLS(config.TLS.Cert, config.TLS.Key)
+		} else {
+			log.Printf("Starting admin server on %s...", config.Listen)
+			err = server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// the gRPC API is served on a separate port, with the same TLS certificate as the RESTful API
+	var grpcServer *grpc.Server
+	if config.GRPCListen != "" {
+		if config.TLS.Cert != "" {
+			creds, err := credentials.NewServerTLSFromFile(config.TLS.Cert, config.TLS.Key)
+			if err != nil {
+				log.Fatal(err)
+			}
+			options = append(options, WithGRPCOptions(grpc.Creds(creds)))
+		}
+		lis, err := net.Listen("tcp", config.GRPCListen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grpcServer = NewGRPCServer(robot, options...)
+		go func() {
+			log.Printf("Starting gRPC server on %s...", config.GRPCListen)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
-	router := RobotAPIServer(&robot)
+	<-ctx.Done()
+	stop() // a second signal terminates immediately
+	shutdownTimeout := time.Duration(config.ShutdownTimeout)
+	log.Printf("Shutting down, waiting up to %s for tasks in progress...", shutdownTimeout)
 
-	log.Println("Starting admin server on :8000...")
-	err := http.ListenAndServe(":8000", router)
-	log.Fatal(err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	// TODO graceful server shutdown (OS signals)
+	// robots reject new tasks right away (and close event streams once drained), so in-flight requests can complete while tasks are drained
+	drained := make(chan error, 1)
+	go func() { drained <- warehouse.Shutdown(shutdownCtx) }()
+	if grpcServer != nil {
+		// watch streams end once the robots are shut down; calls in progress are aborted once the timeout elapses
+		go func() {
+			<-shutdownCtx.Done()
+			grpcServer.Stop()
+		}()
+		go grpcServer.GracefulStop()
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to close connections: %v", err)
+	}
+	if err := <-drained; err != nil {
+		log.Printf("aborted tasks in progress: %v", err)
+	}
+	if bridge != nil {
+		bridge.Close()
+	}
+
+	for _, s := range storage {
+		if f, ok := s.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				log.Printf("failed to flush storage: %v", err)
+			}
+		}
+	}
+	log.Println("Shut down")
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// metricsContentType is the Prometheus text exposition format; see https://prometheus.io/docs/instrumenting/exposition_formats/
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// latencyBuckets are the upper bounds (in seconds) of the buckets of latency histograms
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// Metrics collects counters, gauges and histograms of robots and the API server, exposed in the Prometheus text format
+// - the robots of a warehouse share the metrics of the warehouse
+type Metrics struct {
+	mu sync.Mutex
+
+	tasks          *metricVec
+	commands       *metricVec
+	taskWait       *histogramVec
+	taskDuration   *histogramVec
+	subscribers    *metricVec
+	requests       *metricVec
+	requestLatency *histogramVec
+}
+
+// NewMetrics instantiates metrics with all counters at zero
+func NewMetrics() *Metrics {
+	return &Metrics{
+		tasks:          newMetricVec("robot_tasks_total", "counter", "Tasks processed by robots, by outcome (success, cancelled or the problem code of the failure).", "robot", "outcome"),
+		commands:       newMetricVec("robot_commands_executed_total", "counter", "Movement commands executed by robots.", "robot"),
+		taskWait:       newHistogramVec("robot_task_wait_seconds", "Time tasks were queued before robots started them.", "robot"),
+		taskDuration:   newHistogramVec("robot_task_duration_seconds", "Time robots took to execute tasks (started to finished).", "robot"),
+		subscribers:    newMetricVec("robot_sse_subscribers", "gauge", "Clients subscribed to robot state changes (server-sent events).", "robot"),
+		requests:       newMetricVec("http_requests_total", "counter", "HTTP requests, by route template and status code.", "method", "route", "status"),
+		requestLatency: newHistogramVec("http_request_duration_seconds", "Time taken to serve HTTP requests, by route template.", "method", "route"),
+	}
+}
+
+// taskStarted records the time a task was queued before the robot started it
+func (m *Metrics) taskStarted(robot string, task Task, started time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.taskWait.observe(started.Sub(task.created).Seconds(), robot)
+}
+
+// taskFinished records the outcome of a task; the duration is only recorded for started tasks
+// - err is the error of a failed task
+func (m *Metrics) taskFinished(robot string, task Task, started time.Time, err error) {
+	outcome := "success"
+	switch {
+	case task.cancelled:
+		outcome = "cancelled"
+	case err != nil:
+		outcome = string(taskProblem(err).Code)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks.add(1, robot, outcome)
+	if !started.IsZero() {
+		m.taskDuration.observe(time.Since(started).Seconds(), robot)
+	}
+}
+
+// commandsExecuted records movement commands executed by a robot
+func (m *Metrics) commandsExecuted(robot string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commands.add(float64(n), robot)
+}
+
+// subscribed records a client subscribing to (delta 1), or unsubscribing from (delta -1), the state changes of a robot
+func (m *Metrics) subscribed(robot string, delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers.add(float64(delta), robot)
+}
+
+// middleware records the status and latency of requests by route template (rather than path, so task IDs do not create new series)
+func (m *Metrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		if flusher, ok := w.(http.Flusher); ok {
+			next.ServeHTTP(flushRecorder{rec, flusher}, r)
+		} else {
+			next.ServeHTTP(rec, r)
+		}
+
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.requests.add(1, r.Method, route, strconv.Itoa(rec.status))
+		m.requestLatency.observe(time.Since(started).Seconds(), r.Method, route)
+	})
+}
+
+// write writes the metrics in the Prometheus text format; the queue depth of robots is collected at the time of writing
+func (m *Metrics) write(w io.Writer, robots []*Bot) {
+	queue := newMetricVec("robot_queue_depth", "gauge", "Tasks queued on robots (including the task in progress).", "robot")
+	for _, b := range robots {
+		queue.add(float64(len(b.queuedTasks())), b.id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tasks.write(w)
+	m.commands.write(w)
+	queue.write(w)
+	m.taskWait.write(w)
+	m.taskDuration.write(w)
+	m.subscribers.write(w)
+	m.requests.write(w)
+	m.requestLatency.write(w)
+}
+
+// metricVec is a counter or gauge partitioned by labels
+type metricVec struct {
+	name, kind, help string
+	labels           []string
+	series           map[string]*series
+}
+
+// series is the value of a metric for a set of label values
+type series struct {
+	values []string
+	value  float64
+	hist   *histogram
+}
+
+func newMetricVec(name, kind, help string, labels ...string) *metricVec {
+	return &metricVec{name: name, kind: kind, help: help, labels: labels, series: make(map[string]*series)}
+}
+
+// get returns the series of the label values, creating it if necessary
+func (v *metricVec) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: values}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (v *metricVec) add(delta float64, values ...string) {
+	v.get(values).value += delta
+}
+
+// sorted returns the series ordered by label values, so the output is stable
+func (v *metricVec) sorted() []*series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sorted := make([]*series, len(keys))
+	for i, key := range keys {
+		sorted[i] = v.series[key]
+	}
+	return sorted
+}
+
+func (v *metricVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	for _, s := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, labelSet(v.labels, s.values), formatFloat(s.value))
+	}
+}
+
+// histogramVec is a histogram partitioned by labels
+type histogramVec struct {
+	metricVec
+	buckets []float64
+}
+
+// histogram counts observations per bucket (non-cumulative)
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{metricVec: *newMetricVec(name, "histogram", help, labels...), buckets: latencyBuckets}
+}
+
+func (v *histogramVec) observe(value float64, values ...string) {
+	s := v.get(values)
+	if s.hist == nil {
+		s.hist = &histogram{counts: make([]uint64, len(v.buckets))}
+	}
+	for i, upper := range v.buckets {
+		if value <= upper {
+			s.hist.counts[i]++
+			break
+		}
+	}
+	s.hist.sum += value
+	s.hist.count++
+}
+
+func (v *histogramVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+	labels := append(append([]string(nil), v.labels...), "le")
+	for _, s := range v.sorted() {
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.hist.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, labelSet(labels, append(append([]string(nil), s.values...), formatFloat(upper))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.name, labelSet(labels, append(append([]string(nil), s.values...), "+Inf")), s.hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, labelSet(v.labels, s.values), formatFloat(s.hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, labelSet(v.labels, s.values), s.hist.count)
+	}
+}
+
+// labelSet formats label names and values, e.g. `{robot="r1",outcome="success"}`
+func labelSet(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i])
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// statusRecorder records the status of a response while writing it
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// flushRecorder is a statusRecorder of a response writer supporting streaming (server-sent events)
+type flushRecorder struct {
+	*statusRecorder
+	http.Flusher
+}
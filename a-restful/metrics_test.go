@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	robot, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go robot.listen(context.Background())
+	handler := RobotAPIServer(robot, WithResponseValidation())
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	serve("PUT", "/api/v1/state", `{"commands":"N E"}`)
+	<-robot.States
+	serve("PUT", "/api/v1/state", `{"commands":"S S"}`)
+	<-robot.Errors
+	serve("GET", "/api/v1/task/unknown-task", "")
+
+	rr := serve("GET", "/metrics", "")
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != metricsContentType {
+		t.Errorf("handler returned wrong content type: got: %v, want: %v", contentType, metricsContentType)
+	}
+
+	for _, want := range []string{
+		"# TYPE robot_tasks_total counter",
+		`robot_tasks_total{robot="r1",outcome="success"} 1`,
+		`robot_tasks_total{robot="r1",outcome="out-of-bounds"} 1`,
+		`robot_commands_executed_total{robot="r1"} 2`,
+		"# TYPE robot_queue_depth gauge",
+		`robot_queue_depth{robot="r1"} 0`,
+		"# TYPE robot_task_wait_seconds histogram",
+		`robot_task_wait_seconds_count{robot="r1"} 2`,
+		`robot_task_duration_seconds_bucket{robot="r1",le="+Inf"} 2`,
+		`http_requests_total{method="PUT",route="/api/v1/state",status="200"} 2`,
+		`http_requests_total{method="GET",route="/api/v1/task/{id}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="PUT",route="/api/v1/state"} 2`,
+	} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("metrics should contain `%s`; got:\n%s", want, rr.Body.String())
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogramVec("latency_seconds", "Latency.", "robot")
+	h.buckets = []float64{0.1, 1}
+	h.observe(0.05, `r"1`)
+	h.observe(0.5, `r"1`)
+	h.observe(5, `r"1`)
+
+	var b bytes.Buffer
+	h.write(&b)
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{robot="r\"1",le="0.1"} 1
+latency_seconds_bucket{robot="r\"1",le="1"} 2
+latency_seconds_bucket{robot="r\"1",le="+Inf"} 3
+latency_seconds_sum{robot="r\"1"} 5.55
+latency_seconds_count{robot="r\"1"} 3
+`
+	if got := b.String(); got != want {
+		t.Errorf("incorrect histogram; got:\n%s\nwant:\n%s", got, want)
+	}
+}
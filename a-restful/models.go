@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
 )
 
 // Warehouse is the structure in which robots operate
@@ -14,18 +18,47 @@ type Warehouse interface {
 	Robots() []Robot
 }
 
-// Robot navigate a warehouse using `N`, `S`, `E`, `W` commands
+// Robot navigate a warehouse using `N`, `S`, `E`, `W` commands, and charge at charging stations using the `C` command
 type Robot interface {
 	EnqueueTask(commands string) (taskID string, position chan RobotState, err chan error)
 	CancelTask(taskID string) error
 	CurrentState() RobotState
 }
 
+// ErrShuttingDown is the cause of an error of a task (or robot) rejected or aborted as the robot (or warehouse) is shutting down
+var ErrShuttingDown = errors.New("is shutting down")
+
+// ErrQueueFull is the cause of an error of a task rejected as the robot has queued the maximum number of tasks
+var ErrQueueFull = errors.New("has too many queued tasks")
+
+// ErrOutOfBounds is the cause of an error of a command or position which exceeds the warehouse dimensions
+var ErrOutOfBounds = errors.New("exceeds warehouse dimensions")
+
+// ErrBatteryDepleted is the cause of an error of a task aborted as the battery of the robot is too low to perform its next move
+var ErrBatteryDepleted = errors.New("has a depleted battery")
+
+// ErrNoChargingStation is the cause of an error of a `C` command performed by a robot which is not on a charging station
+var ErrNoChargingStation = errors.New("is not on a charging station")
+
+// Errors of tasks; the causes of errors of the repository and `CancelTask` (use `errors.Is`)
+var (
+	// ErrTaskNotFound is the cause of an error of a task which does not exist
+	ErrTaskNotFound = errors.New("not found")
+	// ErrTaskFinished is the cause of an error of a task which has already been executed
+	ErrTaskFinished = errors.New("has already been executed")
+	// ErrTaskCancelled is the cause of an error of a task which has already been cancelled
+	ErrTaskCancelled = errors.New("has already been cancelled")
+	// ErrTaskRunning is the cause of an error of a task which is being executed, hence can no longer be cancelled
+	ErrTaskRunning = errors.New("is being executed and cannot be cancelled")
+)
+
 // RobotState is current state of a singular robot on the warehouse roof
+// - Battery is only modelled by warehouses with a battery capacity (see `RobotWarehouse.SetBattery`), otherwise it is 0
 type RobotState struct {
 	X        uint
 	Y        uint
 	HasCrate bool
+	Battery  uint
 }
 
 // Task is used to identify whether robot has successfully completed a sequence of commands
@@ -35,56 +68,127 @@ type Task struct {
 	executed  bool
 	success   bool
 	cancelled bool
+	created   time.Time
+	createdBy string // name of the principal which queued the task
 }
 
 // Bot installed on a warehouse roof
 // * implements robot interface
 type Bot struct {
 	mu         sync.RWMutex
+	id         string
+	priority   int
+	warehouse  *RobotWarehouse
 	repository Repository
 	state      RobotState
-	tasks      chan string
+	tasks      chan string // buffered tasks awaiting execution, see `taskBuffer`
+	queue      []string    // IDs of queued tasks; the first task may be in progress
+	maxQueue   int         // maximum number of queued tasks; 0 is only limited by the task buffer
+	running    string      // ID of the task in progress (if any)
+	metrics    *Metrics
+
+	shuttingDown bool
+	events       *eventHub          // closed (with a `shutdown` event) once the robot is shut down
+	stopped      chan struct{}      // closed once the robot stopped listening
+	abort        context.CancelFunc // stops listening, aborting the task in progress
 
 	States chan RobotState
 	Errors chan error
 }
 
+// taskBuffer is the number of tasks a robot buffers awaiting execution (unless its maximum number of queued tasks is larger)
+// - tasks are rejected (caused by `ErrQueueFull`) once the buffer is full, so queueing a task never waits for the task in progress
+const taskBuffer = 1000
+
 // NewBot instantiates a bot on a specified location on the roof
 func NewBot(x uint, y uint, repository Repository) Bot {
 	return Bot{
 		repository: repository,
 		state:      RobotState{X: x, Y: y},
-		tasks:      make(chan string),
+		tasks:      make(chan string, taskBuffer),
+		metrics:    NewMetrics(),
+		events:     newEventHub(),
+		stopped:    make(chan struct{}),
 		States:     make(chan RobotState),
 		Errors:     make(chan error)}
 }
 
-// listen runs the robot to process incoming commands
-func (b *Bot) listen() {
+// listen runs the robot to process incoming commands until the context is done (see `Shutdown`)
+// - the task in progress is aborted once the context is done
+func (b *Bot) listen(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	b.mu.Lock()
+	b.abort = cancel
+	b.mu.Unlock()
+	defer close(b.stopped)
+	defer cancel()
+
 	log.Println("Running robot, listening to operations...")
 	for {
 		select {
+		case <-ctx.Done():
+			log.Printf("robot '%s' stopped listening to operations", b.id)
+			return
 		case taskID := <-b.tasks:
 			// Wrap up in func to increase readability as we cannot break out of for..select
 			func() {
+				defer b.dequeue(taskID)
+
+				// the task is started while holding the lock, so it cannot be cancelled concurrently
+				b.mu.Lock()
 				taskToProcess, err := b.repository.GetTask(taskID)
+				if err == nil && !taskToProcess.cancelled {
+					b.running = taskID
+				}
+				b.mu.Unlock()
+
 				if err != nil {
 					log.Printf("Task %s cannot be processed - not found", taskID)
+					b.events.publish(RobotEvent{Name: EventError, Err: err})
 					go func() { b.Errors <- err }()
 					return
 				}
 
+				// tasks without commands merely hook into the state and error channels, hence are not measured
+				measured := taskToProcess.command != ""
 				if taskToProcess.cancelled {
 					log.Printf("Task %s has been cancelled", taskID)
+					if measured {
+						b.metrics.taskFinished(b.id, taskToProcess, time.Time{}, nil)
+					}
 					return
 				}
 
+				started := time.Now()
+				if measured {
+					b.metrics.taskStarted(b.id, taskToProcess, started)
+				}
+
 				log.Printf(`Processing task "%s": "%s"`, taskID, taskToProcess.command)
+				initialState := b.CurrentState()
 				updatedState, err := b.getUpdatedState(taskToProcess.command)
+				if err == nil && b.warehouse != nil {
+					// robots sharing a warehouse move one command at a time so each move can be arbitrated
+					updatedState, err = b.warehouse.traverse(ctx, b, taskToProcess)
+				} else if err == nil {
+					sequence, _ := cmdlang.Compile(taskToProcess.command)
+					b.metrics.commandsExecuted(b.id, len(sequence))
+				}
 				taskToProcess.executed = true
 				if err != nil {
 					log.Printf("error: %s", err)
 					b.repository.UpdateTask(taskToProcess)
+					if measured {
+						b.metrics.taskFinished(b.id, taskToProcess, started, err)
+					}
+					// the robot may have moved (or charged) part of its route before the task failed
+					if reachedState := b.CurrentState(); reachedState != initialState {
+						b.events.publish(RobotEvent{Name: EventState, State: reachedState})
+					}
+					b.events.publish(RobotEvent{Name: EventError, Err: err})
+					if measured {
+						b.events.publish(RobotEvent{Name: EventTask, Task: taskToProcess, Err: err})
+					}
 					go func() { b.Errors <- err }() // independent consumer can consume errors
 					return
 				}
@@ -93,13 +197,27 @@ func (b *Bot) listen() {
 				err = b.UpdateCurrentState(updatedState)
 				if err != nil {
 					log.Printf("failed to update robot to new state: %v", updatedState)
+					if measured {
+						b.metrics.taskFinished(b.id, taskToProcess, started, err)
+					}
+					b.events.publish(RobotEvent{Name: EventError, Err: err})
+					if measured {
+						b.events.publish(RobotEvent{Name: EventTask, Task: taskToProcess, Err: err})
+					}
 					go func() { b.Errors <- err }() // independent consumer can consume errors
 					return
 				}
 
-				go func() { b.States <- updatedState }() // independent consumer can consume state changes
 				taskToProcess.success = true
 				b.repository.UpdateTask(taskToProcess)
+				if measured {
+					b.metrics.taskFinished(b.id, taskToProcess, started, nil)
+				}
+				b.events.publish(RobotEvent{Name: EventState, State: updatedState})
+				if measured {
+					b.events.publish(RobotEvent{Name: EventTask, Task: taskToProcess})
+				}
+				go func() { b.States <- updatedState }() // independent consumer can consume state changes
 				log.Printf("successfully updated robot to state %v", b.CurrentState())
 			}()
 		}
@@ -107,66 +225,190 @@ func (b *Bot) listen() {
 }
 
 // EnqueueTask queues a task on the `taskCommand` bot channel to be processed by `listen` function
+// - tasks are rejected once the robot is shutting down or its queue is full; the error (caused by `ErrShuttingDown` or `ErrQueueFull`) is sent on the `err` channel
 // * implements robot
-func (b Bot) EnqueueTask(commands string) (taskID string, position chan RobotState, err chan error) {
-	log.Printf("Queueing commands: \"%s\"", commands)
+func (b *Bot) EnqueueTask(commands string) (taskID string, position chan RobotState, err chan error) {
+	taskID, position, err, rejected := b.enqueue(commands, "")
+	if rejected != nil {
+		go func() { err <- rejected }() // independent consumer can consume errors
+	}
+	return taskID, position, err
+}
 
-	taskID = uuid.NewV4().String()
+// enqueue queues a task on behalf of a principal, which is recorded on the task
+// - rejected is caused by `ErrShuttingDown` if the robot is shutting down, or by `ErrQueueFull` if its queue is full
+// - tasks without commands (hooking into the state and error channels) are not limited by the queue size, only by the task buffer
+// - the task is buffered rather than handed over to `listen`, hence this returns immediately even while a task is in progress
+func (b *Bot) enqueue(commands string, principal string) (taskID string, position chan RobotState, err chan error, rejected error) {
 	position = b.States
 	err = b.Errors
 
-	b.repository.CreateTask(Task{taskID, commands, false, false, false})
-	b.tasks <- taskID
+	b.mu.Lock()
+	if b.shuttingDown {
+		b.mu.Unlock()
+		return "", position, err, fmt.Errorf("robot '%s' %w", b.id, ErrShuttingDown)
+	}
+	if b.maxQueue > 0 && commands != "" && len(b.queue) >= b.maxQueue {
+		b.mu.Unlock()
+		return "", position, err, fmt.Errorf("robot '%s' %w (at most %d)", b.id, ErrQueueFull, b.maxQueue)
+	}
+	// only `enqueue` sends tasks (while holding the lock), hence the send below cannot block unless the buffer is full
+	if len(b.tasks) == cap(b.tasks) {
+		b.mu.Unlock()
+		return "", position, err, fmt.Errorf("robot '%s' %w (at most %d)", b.id, ErrQueueFull, cap(b.tasks))
+	}
+	stopped := false
+	select {
+	case <-b.stopped:
+		stopped = true
+	default:
+	}
+
+	log.Printf("Queueing commands: \"%s\"", commands)
+	taskID = uuid.NewV4().String()
+	b.repository.CreateTask(Task{taskID, commands, false, false, false, time.Now(), principal})
+	if !stopped {
+		b.queue = append(b.queue, taskID)
+		b.tasks <- taskID
+	}
+	b.mu.Unlock()
 
-	return
+	if stopped {
+		// the robot stopped listening, hence would never pick up the task
+		b.CancelTask(taskID)
+	}
+	return taskID, position, err, nil
 }
 
-// getUpdatedState translates a sequence of space delimited movement commands to a final RobotState
-func (b Bot) getUpdatedState(commands string) (RobotState, error) {
-	genErr := func(command, commands string) error {
-		return fmt.Errorf(`command '%s' of "%s" exceeds warehouse dimensions`, command, commands)
+// Shutdown gracefully stops the robot; new tasks are rejected and queued tasks are cancelled, while the task in progress may finish until ctx is done
+// - once ctx is done, the task in progress is aborted (between two commands) and the error of the context is returned
+// - the robot stops listening once its tasks are drained
+// - subscribers receive the events of the drained tasks; the final shutdown event is published once the robot stopped listening
+func (b *Bot) Shutdown(ctx context.Context) error {
+	b.mu.Lock()
+	b.shuttingDown = true
+	queue := append([]string(nil), b.queue...)
+	b.mu.Unlock()
+
+	for _, taskID := range queue {
+		// the task in progress (or tasks finished in the meantime) cannot be cancelled
+		if err := b.CancelTask(taskID); err == nil {
+			log.Printf("Task %s cancelled due to shutdown", taskID)
+		}
+	}
+
+	// the queue is polled (like `http.Server.Shutdown` polls idle connections); cancelled tasks are dequeued as soon as they are picked up
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	var err error
+	for err == nil && len(b.queuedTasks()) > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	b.mu.Lock()
+	abort := b.abort
+	b.mu.Unlock()
+	if abort != nil {
+		abort()
+		<-b.stopped
+	}
+	b.events.close(RobotEvent{Name: EventShutdown, Err: fmt.Errorf("robot '%s' %w", b.id, ErrShuttingDown)})
+	return err
+}
+
+// dequeue removes a processed task from the queue of the bot
+func (b *Bot) dequeue(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running == taskID {
+		b.running = ""
+	}
+	for i, id := range b.queue {
+		if id == taskID {
+			b.queue = append(b.queue[:i:i], b.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// queuedTasks returns the IDs of tasks queued on the bot (in order of execution)
+func (b *Bot) queuedTasks() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.queue...)
+}
+
+// getUpdatedState translates a sequence of movement commands (see `cmdlang`) to a final RobotState
+func (b *Bot) getUpdatedState(commands string) (RobotState, error) {
+	sequence, err := cmdlang.Compile(commands)
+	if err != nil {
+		return RobotState{}, err
 	}
 
 	finalState := b.state
-	for _, command := range commands {
-		switch string(command) {
-		case "N":
-			if finalState.Y++; finalState.Y > 9 {
-				return RobotState{}, genErr(string(command), commands)
-			}
-		case "S":
-			if int(finalState.Y)-1 < 0 {
-				return RobotState{}, genErr(string(command), commands)
-			}
-			finalState.Y--
-		case "E":
-			if finalState.X++; finalState.X > 9 {
-				return RobotState{}, genErr(string(command), commands)
-			}
-		case "W":
-			if int(finalState.X)-1 < 0 {
-				return RobotState{}, genErr(string(command), commands)
-			}
-			finalState.X--
+	for _, command := range sequence {
+		var ok bool
+		if finalState, ok = move(finalState, command); !ok {
+			return RobotState{}, fmt.Errorf(`command '%s' of "%s" %w`, string(command), commands, ErrOutOfBounds)
 		}
 	}
 	return finalState, nil
 }
 
+// move translates a single movement command to the resulting RobotState
+// - ok is false if the command would move the robot beyond the warehouse dimensions
+// - the `C` (charge) command does not move the robot; charging is performed by the warehouse (see `traverse`)
+func move(state RobotState, command cmdlang.Command) (next RobotState, ok bool) {
+	next = state
+	switch string(command) {
+	case "N":
+		if next.Y++; next.Y > 9 {
+			return state, false
+		}
+	case "S":
+		if int(next.Y)-1 < 0 {
+			return state, false
+		}
+		next.Y--
+	case "E":
+		if next.X++; next.X > 9 {
+			return state, false
+		}
+	case "W":
+		if int(next.X)-1 < 0 {
+			return state, false
+		}
+		next.X--
+	}
+	return next, true
+}
+
 // CancelTask sets an existing task on the map to be cancelled
+// - only queued tasks can be cancelled; errors are caused by `ErrTaskNotFound`, `ErrTaskFinished`, `ErrTaskCancelled` or `ErrTaskRunning`
 // * implements robot
-func (b Bot) CancelTask(taskID string) error {
+func (b *Bot) CancelTask(taskID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	task, err := b.repository.GetTask(taskID)
 	if err != nil {
 		return err
 	}
-	if task.executed {
-		return fmt.Errorf("task %s has already been executed", taskID)
+	switch {
+	case task.executed:
+		return fmt.Errorf("task %s %w", taskID, ErrTaskFinished)
+	case task.cancelled:
+		return fmt.Errorf("task %s %w", taskID, ErrTaskCancelled)
+	case b.running == taskID:
+		return fmt.Errorf("task %s %w", taskID, ErrTaskRunning)
 	}
 
 	task.cancelled = true
-	b.repository.UpdateTask(task)
-	return nil
+	return b.repository.UpdateTask(task)
 }
 
 // UpdateCurrentState current state concurrent-safe way; additionally this method ensures robotstate lies within warehouse dimensions
@@ -174,10 +416,10 @@ func (b *Bot) UpdateCurrentState(rs RobotState) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	if rs.X < 0 || rs.X > 9 {
-		return fmt.Errorf("Robot state X position (%d, y) exceeds warehouse dimensions", rs.X)
+		return fmt.Errorf("Robot state X position (%d, y) %w", rs.X, ErrOutOfBounds)
 	}
 	if rs.Y < 0 || rs.Y > 9 {
-		return fmt.Errorf("Robot state Y position (x, %d) exceeds warehouse dimensions", rs.Y)
+		return fmt.Errorf("Robot state Y position (x, %d) %w", rs.Y, ErrOutOfBounds)
 	}
 	b.state = rs
 	return nil
@@ -185,7 +427,7 @@ func (b *Bot) UpdateCurrentState(rs RobotState) error {
 
 // CurrentState returns the latest state of the robot
 // * implements robot
-func (b Bot) CurrentState() RobotState {
+func (b *Bot) CurrentState() RobotState {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	log.Println(b.state)
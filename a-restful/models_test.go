@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"testing"
@@ -8,7 +9,7 @@ import (
 
 func TestBotImplementsRobot(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
-	_, ok := interface{}(bot).(Robot)
+	_, ok := interface{}(&bot).(Robot)
 	if !ok {
 		t.Errorf("bot must asatisfy the `Robot` interface")
 	}
@@ -46,6 +47,30 @@ func TestValidateCommandSequence(t *testing.T) {
 			t.Errorf("command sequence is valid")
 		}
 	})
+
+	t.Run("test valid repeated commands", func(t *testing.T) {
+		commands := "N9 3(E W) # comment"
+		err := validateCommandSequence(commands)
+		if err != nil {
+			t.Errorf("command sequence is valid; %v", err)
+		}
+	})
+
+	t.Run("test invalid comment only string", func(t *testing.T) {
+		commands := "# N E"
+		err := validateCommandSequence(commands)
+		if err == nil {
+			t.Errorf("comment only input is invalid")
+		}
+	})
+
+	t.Run("test invalid unclosed group", func(t *testing.T) {
+		commands := "3(N E"
+		err := validateCommandSequence(commands)
+		if err == nil {
+			t.Errorf("unclosed group is invalid")
+		}
+	})
 }
 
 func TestGetUpdatedState(t *testing.T) {
@@ -82,7 +107,7 @@ func TestGetUpdatedState(t *testing.T) {
 			t.Errorf("command `N` should be performed")
 		}
 
-		want := RobotState{0, 1, false}
+		want := RobotState{0, 1, false, 0}
 		if got != want {
 			t.Errorf("command `N` should set robot at (0,1)")
 		}
@@ -95,7 +120,7 @@ func TestGetUpdatedState(t *testing.T) {
 			t.Errorf("command `E` should be performed")
 		}
 
-		want := RobotState{1, 0, false}
+		want := RobotState{1, 0, false, 0}
 		if got != want {
 			t.Errorf("command `E` should set robot at (1,0)")
 		}
@@ -108,12 +133,33 @@ func TestGetUpdatedState(t *testing.T) {
 			t.Errorf("commands `N E S W` should be performed")
 		}
 
-		want := RobotState{0, 0, false}
+		want := RobotState{0, 0, false, 0}
 		if got != want {
 			t.Errorf("commands `N E S W` should set robot back at (0,0)")
 		}
 	})
 
+	t.Run("test `4(N E)` command seq success at (0,0) - moves robot to (4,4)", func(t *testing.T) {
+		commands := "4(N E)"
+		got, err := bot.getUpdatedState(commands)
+		if err != nil {
+			t.Errorf("commands `4(N E)` should be performed")
+		}
+
+		want := RobotState{4, 4, false, 0}
+		if got != want {
+			t.Errorf("commands `4(N E)` should move robot to (4,4)")
+		}
+	})
+
+	t.Run("test `N10` command seq failure at (0,0)", func(t *testing.T) {
+		commands := "N10"
+		_, err := bot.getUpdatedState(commands)
+		if err == nil {
+			t.Errorf("command `N10` should not be performed")
+		}
+	})
+
 	t.Run("test `N E N E N E N E` command seq success at (0,0) - moves robot to (4,4)", func(t *testing.T) {
 		commands := "N E N E N E N E"
 		got, err := bot.getUpdatedState(commands)
@@ -121,7 +167,7 @@ func TestGetUpdatedState(t *testing.T) {
 			t.Errorf("commands `N E N E N E N E` should be performed")
 		}
 
-		want := RobotState{4, 4, false}
+		want := RobotState{4, 4, false, 0}
 		if got != want {
 			t.Errorf("commands `N E N E N E N E` should move robot to (4,4)")
 		}
@@ -132,7 +178,7 @@ func TestUpdateCurrentState(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
 
 	t.Run("test (10,0) is invalid robot state", func(t *testing.T) {
-		rs := RobotState{10, 0, false}
+		rs := RobotState{10, 0, false, 0}
 		err := bot.UpdateCurrentState(rs)
 		if err == nil {
 			t.Errorf("incoming robot state (10,0) should not be set")
@@ -140,7 +186,7 @@ func TestUpdateCurrentState(t *testing.T) {
 	})
 
 	t.Run("test (0,10) is invalid robot state", func(t *testing.T) {
-		rs := RobotState{0, 10, false}
+		rs := RobotState{0, 10, false, 0}
 		err := bot.UpdateCurrentState(rs)
 		if err == nil {
 			t.Errorf("incoming robot state (0,10) should not be set")
@@ -148,7 +194,7 @@ func TestUpdateCurrentState(t *testing.T) {
 	})
 
 	t.Run("test (9,0) is valid robot state", func(t *testing.T) {
-		rs := RobotState{9, 0, false}
+		rs := RobotState{9, 0, false, 0}
 		err := bot.UpdateCurrentState(rs)
 		if err != nil {
 			t.Errorf("incoming robot state (9,0) should be set")
@@ -156,7 +202,7 @@ func TestUpdateCurrentState(t *testing.T) {
 	})
 
 	t.Run("test (0,9) is valid robot state", func(t *testing.T) {
-		rs := RobotState{0, 9, false}
+		rs := RobotState{0, 9, false, 0}
 		err := bot.UpdateCurrentState(rs)
 		if err != nil {
 			t.Errorf("incoming robot state (0,9) should be set")
@@ -164,7 +210,7 @@ func TestUpdateCurrentState(t *testing.T) {
 	})
 
 	t.Run("test (9,9) is valid robot state", func(t *testing.T) {
-		rs := RobotState{9, 9, false}
+		rs := RobotState{9, 9, false, 0}
 		err := bot.UpdateCurrentState(rs)
 		if err != nil {
 			t.Errorf("incoming robot state (9,9) should be set")
@@ -176,7 +222,7 @@ func TestCurrentState(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
 
 	t.Run("test (9,9) successfully updates robot state", func(t *testing.T) {
-		rs := RobotState{9, 9, false}
+		rs := RobotState{9, 9, false, 0}
 		bot.UpdateCurrentState(rs)
 
 		updatedState := bot.CurrentState()
@@ -190,28 +236,32 @@ func TestEnqueueTask(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
 
 	t.Run("test successfully generates taskID", func(t *testing.T) {
-		go func() { <-bot.tasks }()
 		taskID, _, _ := bot.EnqueueTask("N S E W")
 		if taskID == "" {
 			t.Error("robot should have a queued task")
 		}
+		<-bot.tasks
 	})
 
-	t.Run("test successfully queues taskID", func(t *testing.T) {
-		var wg sync.WaitGroup
-		wg.Add(1)
-
-		var got string
-		go func() { got = <-bot.tasks; wg.Done() }()
-
+	t.Run("test successfully queues taskID without waiting for the robot", func(t *testing.T) {
 		want, _, _ := bot.EnqueueTask("N S E W")
 
-		wg.Wait()
-
-		if want != got {
+		if got := <-bot.tasks; want != got {
 			t.Errorf("robot should have a queued task; got: \"%s\", want \"%s\"", got, want)
 		}
 	})
+
+	t.Run("test queue is full once the task buffer is full", func(t *testing.T) {
+		bot := NewBot(0, 0, NewInMemoryDB())
+		for i := 0; i < taskBuffer; i++ {
+			if _, _, _, err := bot.enqueue("N", ""); err != nil {
+				t.Fatalf("task %d should be queued; %v", i, err)
+			}
+		}
+		if _, _, _, err := bot.enqueue("N", ""); !errors.Is(err, ErrQueueFull) {
+			t.Errorf("task should be rejected; got: %v", err)
+		}
+	})
 }
 
 func TestCancelTask(t *testing.T) {
@@ -237,7 +287,7 @@ func TestCancelTask(t *testing.T) {
 		bot.EnqueueTask(commandSeq)
 
 		err := bot.CancelTask("cdc29b67-7212-4579-a593-74fb9a1f606f")
-		if err == nil {
+		if !errors.Is(err, ErrTaskNotFound) {
 			t.Error("task ID cdc29b67-7212-4579-a593-74fb9a1f606f should not be found")
 		}
 	})
@@ -254,8 +304,34 @@ func TestCancelTask(t *testing.T) {
 		bot.repository.UpdateTask(rTask)
 
 		err := bot.CancelTask(taskID)
-		if err == nil {
-			t.Errorf("should fail to cancel executed task %s", taskID)
+		if !errors.Is(err, ErrTaskFinished) {
+			t.Errorf("should fail to cancel executed task %s; got: %v", taskID, err)
+		}
+	})
+
+	t.Run("test failed to cancel running task", func(t *testing.T) {
+		bot := NewBot(0, 0, NewInMemoryDB())
+		go func() { <-bot.tasks }()
+
+		taskID, _, _ := bot.EnqueueTask("N E S W")
+		bot.running = taskID
+
+		err := bot.CancelTask(taskID)
+		if !errors.Is(err, ErrTaskRunning) {
+			t.Errorf("should fail to cancel running task %s; got: %v", taskID, err)
+		}
+	})
+
+	t.Run("test repeated cancel reports cancelled task", func(t *testing.T) {
+		bot := NewBot(0, 0, NewInMemoryDB())
+		go func() { <-bot.tasks }()
+
+		taskID, _, _ := bot.EnqueueTask("N E S W")
+		bot.CancelTask(taskID)
+
+		err := bot.CancelTask(taskID)
+		if !errors.Is(err, ErrTaskCancelled) {
+			t.Errorf("should report task %s as cancelled; got: %v", taskID, err)
 		}
 	})
 }
@@ -263,7 +339,7 @@ func TestCancelTask(t *testing.T) {
 // TestRobotMovementSubscriptions provides an insight of how consumers of the `position` channel can subscribe to robot state changes
 func TestRobotMovementSubscriptions(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
-	go bot.listen()
+	go bot.listen(context.Background())
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -274,7 +350,7 @@ func TestRobotMovementSubscriptions(t *testing.T) {
 
 	wg.Wait()
 
-	want := RobotState{4, 4, false}
+	want := RobotState{4, 4, false, 0}
 
 	if got != want {
 		t.Errorf("robot should have updated state; got: %v, want: %v", got, want)
@@ -284,7 +360,7 @@ func TestRobotMovementSubscriptions(t *testing.T) {
 // TestRobotErrorSubscriptions provides an insight of how consumers of the `err` channel can subscribe to invalid robot state changes
 func TestRobotErrorSubscriptions(t *testing.T) {
 	bot := NewBot(0, 0, NewInMemoryDB())
-	go bot.listen()
+	go bot.listen(context.Background())
 
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -301,3 +377,32 @@ func TestRobotErrorSubscriptions(t *testing.T) {
 		t.Errorf("robot movement should have thrown error; got: \"%s\", want: \"%s\"", got, want)
 	}
 }
+
+func TestEventHub(t *testing.T) {
+	t.Run("test subscriber falling behind is unsubscribed", func(t *testing.T) {
+		hub := newEventHub()
+		slow, _ := hub.subscribe()
+		fast, cancel := hub.subscribe()
+		defer cancel()
+
+		for i := 0; i <= eventBuffer; i++ {
+			hub.publish(RobotEvent{Name: EventState})
+			<-fast
+		}
+		received := 0
+		for range slow {
+			received++
+		}
+		if received != eventBuffer {
+			t.Errorf("slow subscriber should receive its buffered events before its channel is closed; got: %d, want: %d", received, eventBuffer)
+		}
+		if hub.closed() {
+			t.Error("hub should not be closed by unsubscribing a slow subscriber")
+		}
+
+		hub.publish(RobotEvent{Name: EventTask})
+		if event := <-fast; event.Name != EventTask {
+			t.Errorf("other subscribers should receive later events; got: %v", event.Name)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"warehouse/w1/robot/r1/state", "warehouse/w1/robot/r1/state", true},
+		{"warehouse/w1/robot/+/state", "warehouse/w1/robot/r1/state", true},
+		{"warehouse/w1/robot/+/state", "warehouse/w1/robot/r1/task", false},
+		{"warehouse/w1/robot/+", "warehouse/w1/robot/r1/state", false},
+		{"warehouse/#", "warehouse/w1/robot/r1/command/ack", true},
+		{"warehouse/w1/robot/r1/state/#", "warehouse/w1/robot/r1/state", true}, // `#` includes the parent level
+		{"#", "warehouse", true},
+		{"warehouse/w1", "warehouse/w1/robot", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("test %s matches %s", tt.filter, tt.topic), func(t *testing.T) {
+			if got := matchTopic(tt.filter, tt.topic); got != tt.want {
+				t.Errorf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryBroker(t *testing.T) {
+	broker := NewInMemoryBroker()
+
+	t.Run("test invalid filters", func(t *testing.T) {
+		for _, filter := range []string{"", "warehouse/#/state", "warehouse/w+", "warehouse/#robots"} {
+			if _, err := broker.Subscribe(filter, func(string, []byte) {}); err == nil {
+				t.Errorf("filter '%s' should be rejected", filter)
+			}
+		}
+	})
+
+	t.Run("test retained messages are delivered to later subscribers", func(t *testing.T) {
+		broker.Publish("warehouse/w1/robot/r1/state", []byte(`{"x":1,"y":0}`), true)
+		broker.Publish("warehouse/w1/robot/r1/task", []byte(`{}`), false)
+
+		var received []string
+		unsubscribe, err := broker.Subscribe("warehouse/w1/robot/r1/+", func(topic string, payload []byte) {
+			received = append(received, topic+" "+string(payload))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		broker.Publish("warehouse/w1/robot/r1/state", []byte(`{"x":2,"y":0}`), true)
+		unsubscribe()
+		broker.Publish("warehouse/w1/robot/r1/state", []byte(`{"x":3,"y":0}`), true)
+
+		want := []string{`warehouse/w1/robot/r1/state {"x":1,"y":0}`, `warehouse/w1/robot/r1/state {"x":2,"y":0}`}
+		if fmt.Sprint(received) != fmt.Sprint(want) {
+			t.Errorf("incorrect messages; got: %v, want: %v", received, want)
+		}
+	})
+
+	t.Run("test empty retained message clears the retained message", func(t *testing.T) {
+		broker.Publish("warehouse/w1/robot/r1/state", nil, true)
+		if payload, ok := broker.Retained("warehouse/w1/robot/r1/state"); ok {
+			t.Errorf("retained message should be cleared; got: %s", payload)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type base struct {
+	Name string `json:"name"`
+}
+
+type child struct {
+	base
+
+	Count    uint     `json:"count"`
+	Tags     []string `json:"tags,omitempty"`
+	Parent   *child   `json:"parent,omitempty"`
+	Kind     string   `json:"kind" enum:"a,b"`
+	internal int
+}
+
+func TestSchema(t *testing.T) {
+	g := NewGenerator()
+
+	t.Run("test named structs are referenced components", func(t *testing.T) {
+		s := g.SchemaOf(child{})
+		if s.Ref != "#/components/schemas/child" {
+			t.Fatalf("struct should be referenced; got: %+v", s)
+		}
+		if _, ok := g.Schemas["child"]; !ok {
+			t.Error("struct should be added to components")
+		}
+	})
+
+	t.Run("test fields are required unless omitted when empty", func(t *testing.T) {
+		s := g.Schemas["child"]
+		want := []string{"name", "count", "kind"}
+		if !reflect.DeepEqual(s.Required, want) {
+			t.Errorf("incorrect required properties; got: %v, want: %v", s.Required, want)
+		}
+		if _, ok := s.Properties["internal"]; ok {
+			t.Error("unexported fields should not be properties")
+		}
+		if s.AdditionalProperties == nil || *s.AdditionalProperties {
+			t.Error("objects should not allow additional properties")
+		}
+	})
+
+	t.Run("test unsigned integers have a minimum", func(t *testing.T) {
+		s := g.Schemas["child"].Properties["count"]
+		if s.Type != "integer" || s.Minimum == nil || *s.Minimum != 0 {
+			t.Errorf("unsigned integer should have minimum 0; got: %+v", s)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	g := NewGenerator()
+	schema := g.SchemaOf(child{})
+	components := Components{Schemas: g.Schemas}
+
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"valid", `{"name":"r1","count":1,"kind":"a"}`, ""},
+		{"valid nested", `{"name":"r1","count":1,"kind":"a","parent":{"name":"r0","count":0,"kind":"b"}}`, ""},
+		{"null optional reference", `{"name":"r1","count":1,"kind":"a","parent":null}`, ""},
+		{"missing required property", `{"name":"r1","kind":"a"}`, "$: missing required property 'count'"},
+		{"unknown property", `{"name":"r1","count":1,"kind":"a","extra":true}`, "$: unknown property 'extra'"},
+		{"wrong type", `{"name":1,"count":1,"kind":"a"}`, "$.name: must be a string, not a number"},
+		{"negative unsigned", `{"name":"r1","count":-1,"kind":"a"}`, "$.count: must be at least 0"},
+		{"fractional integer", `{"name":"r1","count":1.5,"kind":"a"}`, "$.count: must be an integer, not a number"},
+		{"enum", `{"name":"r1","count":1,"kind":"c"}`, "$.kind: must be one of 'a', 'b'"},
+		{"array items", `{"name":"r1","count":1,"kind":"a","tags":["x",2]}`, "$.tags[1]: must be a string, not a number"},
+		{"invalid json", `{"name":`, "$: invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run("test "+tt.name, func(t *testing.T) {
+			err := components.ValidateJSON(schema, []byte(tt.json))
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("value should be valid; got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("incorrect validation error; got: %v, want: %s", err, tt.err)
+			}
+		})
+	}
+}
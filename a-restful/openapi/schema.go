@@ -0,0 +1,127 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Generator derives schemas from Go types; named struct types become reusable components
+type Generator struct {
+	Schemas map[string]*Schema
+}
+
+// NewGenerator creates a generator without components
+func NewGenerator() *Generator {
+	return &Generator{Schemas: make(map[string]*Schema)}
+}
+
+// SchemaOf returns the schema of the (dynamic) type of a value; `nil` has no schema
+func (g *Generator) SchemaOf(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return g.Schema(reflect.TypeOf(v))
+}
+
+// Schema returns the schema of a type
+// - named struct types are added to the components and referenced
+func (g *Generator) Schema(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.Schema(t.Elem())
+		if s.Ref != "" {
+			// siblings of `$ref` are ignored, hence a nullable reference must be wrapped
+			return &Schema{AnyOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: format(t)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		min := 0.0
+		return &Schema{Type: "integer", Format: format(t), Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.Schemas[t.Name()]; !ok {
+			g.Schemas[t.Name()] = &Schema{} // placeholder, breaking cycles of recursive types
+			g.Schemas[t.Name()] = g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+	return &Schema{}
+}
+
+// object returns the schema of a struct; fields of embedded structs are promoted like `encoding/json` does
+func (g *Generator) object(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema), AdditionalProperties: &closed}
+	g.fields(t, s)
+	return s
+}
+
+func (g *Generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name, options := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, options = tag[:i], tag[i+1:]
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, s)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.Schema(field.Type)
+		if description := field.Tag.Get("description"); description != "" || field.Tag.Get("enum") != "" {
+			if property.Ref != "" {
+				property = &Schema{AnyOf: []*Schema{property}}
+			}
+			property.Description = description
+			if enum := field.Tag.Get("enum"); enum != "" {
+				property.Enum = strings.Split(enum, ",")
+			}
+		}
+		s.Properties[name] = property
+		if !strings.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// format returns the OpenAPI format of a sized integer type
+func format(t reflect.Type) string {
+	if t.Bits() == 64 {
+		return "int64"
+	}
+	return "int32"
+}
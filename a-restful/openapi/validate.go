@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ValidationError describes why a value does not match a schema
+// - Path locates the offending value, e.g. `$.robots[0].x`
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidateJSON decodes a JSON document and validates it against a schema, resolving references to the components
+func (c Components) ValidateJSON(schema *Schema, data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Path: "$", Message: fmt.Sprintf("invalid JSON: %s", err)}
+	}
+	return c.Validate(schema, v)
+}
+
+// Validate validates a decoded JSON value (as produced by `encoding/json`) against a schema
+func (c Components) Validate(schema *Schema, v interface{}) error {
+	return c.validate(schema, v, "$")
+}
+
+func (c Components) validate(s *Schema, v interface{}, path string) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		ref, ok := c.Schemas[name]
+		if !ok {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("unknown schema '%s'", s.Ref)}
+		}
+		return c.validate(ref, v, path)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && s.AnyOf == nil) {
+			return nil
+		}
+		return &ValidationError{Path: path, Message: "must not be null"}
+	}
+
+	if len(s.AnyOf) > 0 {
+		var errs []string
+		for _, option := range s.AnyOf {
+			err := c.validate(option, v, path)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		if len(errs) == 1 {
+			return &ValidationError{Path: path, Message: strings.TrimPrefix(errs[0], path+": ")}
+		}
+		return &ValidationError{Path: path, Message: fmt.Sprintf("must match any of the schemas (%s)", strings.Join(errs, "; "))}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(path, "an object", v)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property '%s'", name)}
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("unknown property '%s'", name)}
+				}
+				continue
+			}
+			if err := c.validate(property, object[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := v.([]interface{})
+		if !ok {
+			return mismatch(path, "an array", v)
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				if err := c.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch(path, "a string", v)
+		}
+		if len(s.Enum) > 0 {
+			for _, value := range s.Enum {
+				if str == value {
+					return nil
+				}
+			}
+			return &ValidationError{Path: path, Message: fmt.Sprintf("must be one of '%s'", strings.Join(s.Enum, "', '"))}
+		}
+	case "integer", "number":
+		n, ok := v.(float64)
+		if !ok {
+			return mismatch(path, "a number", v)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return mismatch(path, "an integer", v)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("must be at least %v", *s.Minimum)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch(path, "a boolean", v)
+		}
+	}
+	return nil
+}
+
+// mismatch describes a value of the wrong JSON type
+func mismatch(path, expected string, v interface{}) error {
+	actual := "null"
+	switch v.(type) {
+	case map[string]interface{}:
+		actual = "an object"
+	case []interface{}:
+		actual = "an array"
+	case string:
+		actual = "a string"
+	case float64:
+		actual = "a number"
+	case bool:
+		actual = "a boolean"
+	}
+	return &ValidationError{Path: path, Message: fmt.Sprintf("must be %s, not %s", expected, actual)}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+)
+
+// PlanFailure identifies the first command of a planned task which would fail
+// - Index is the position of the command within the compiled (primitive) command sequence, or within the replanned route once the route is replanned
+// - X and Y are the position of the robot when the command fails
+type PlanFailure struct {
+	Index           int
+	Command         cmdlang.Command
+	X               uint
+	Y               uint
+	BlockingRobotID string
+	Err             error
+}
+
+// Plan is the outcome of simulating a task of a robot without executing it
+// - Path contains every state visited by the robot, starting with its current state
+// - Duration is the estimated time to execute the task (up to the failing command, if any), including the time spent waiting for other robots
+type Plan struct {
+	RobotID  string
+	Path     []RobotState
+	Final    RobotState
+	Duration time.Duration
+	Failure  *PlanFailure
+}
+
+// Plan simulates a sequence of commands from the current state of the bot without mutating the warehouse
+// - other robots are expected to move along the paths of their queued tasks (one cell per command) at the same time
+// - the collision policy of the warehouse applies to cells other robots are expected to occupy: the robot fails, waits (for at most the wait timeout) or routes around them
+// - robots swapping cells are reported as a collision unless the route is replanned, as waiting robots would deadlock
+// - batteries are drained and charged like `traverse` does, hence a task which would deplete the battery or charge off a charging station fails
+// - only invalid command sequences result in an error; a task which would fail is described by `Plan.Failure`
+func (w *RobotWarehouse) Plan(b *Bot, commands string) (Plan, error) {
+	sequence, err := cmdlang.Compile(commands)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	w.mu.Lock()
+	duration := w.commandDuration
+	policy, waitTimeout := w.policy, w.waitTimeout
+	battery := w.battery
+	stations := make(map[cell]bool, len(w.stations))
+	for c := range w.stations {
+		stations[c] = true
+	}
+	others := make(map[*Bot]cmdlang.Sequence)
+	for _, o := range w.bots {
+		if o != b {
+			others[o] = w.routes[o]
+		}
+	}
+	w.mu.Unlock()
+
+	projections := make(map[*Bot][]cell, len(others))
+	for o, route := range others {
+		projections[o] = projection(o, route, battery, stations)
+	}
+
+	state := b.CurrentState()
+	plan := Plan{RobotID: b.id, Path: []RobotState{state}}
+	// each step takes the duration of a command: a command performed, or a command duration spent waiting
+	steps, waited := 0, 0
+	var timedOut time.Duration // wait timeout of a robot giving up waiting for a cell
+	pending := sequence
+	for index, replans := 0, 0; len(pending) > 0; {
+		command := pending[0]
+		if command == cmdlang.Charge {
+			next, err := battery.charge(b, state, stations[cell{state.X, state.Y}])
+			if err != nil {
+				plan.Failure = &PlanFailure{Index: index, Command: command, X: state.X, Y: state.Y, Err: err}
+				break
+			}
+			state = next
+			plan.Path = append(plan.Path, state)
+			steps++
+			index++
+			pending = pending[1:]
+			continue
+		}
+
+		next, ok := move(state, command)
+		if !ok {
+			plan.Failure = &PlanFailure{
+				Index: index, Command: command, X: state.X, Y: state.Y,
+				Err: fmt.Errorf(`command '%s' of "%s" %w`, string(command), commands, ErrOutOfBounds),
+			}
+			break
+		}
+		next, err := battery.drain(b, state, next)
+		if err != nil {
+			plan.Failure = &PlanFailure{Index: index, Command: command, X: state.X, Y: state.Y, Err: err}
+			break
+		}
+
+		from, to := cell{state.X, state.Y}, cell{next.X, next.Y}
+		if blocking, swap := blocker(projections, steps, from, to); blocking != nil {
+			cells := projections[blocking]
+			switch {
+			case policy == CollisionWait && !swap && steps+1 < len(cells)-1 && (waitTimeout <= 0 || time.Duration(waited+1)*duration <= waitTimeout):
+				// the blocking robot moves on later, releasing the cell
+				steps++
+				waited++
+				continue
+			case policy == CollisionReplan && replans < 100:
+				leg, rest := nextLeg(pending)
+				goal := destination(state, leg)
+				detour, ok := shortestRoute(from, cell{goal.X, goal.Y}, func(c cell) bool {
+					for _, cells := range projections {
+						if at(cells, steps) == c || at(cells, steps+1) == c {
+							return true
+						}
+					}
+					return false
+				})
+				if ok {
+					pending = append(detour, rest...)
+					replans++
+					continue
+				}
+			}
+
+			collision := &CollisionError{RobotID: b.id, BlockingRobotID: blocking.id, X: to.x, Y: to.y}
+			if policy == CollisionWait && !swap && waitTimeout > 0 {
+				// the blocking robot does not move on in time, hence the robot gives up once the wait timeout elapsed
+				steps -= waited
+				timedOut = waitTimeout
+				collision.Waited = waitTimeout
+			}
+			plan.Failure = &PlanFailure{Index: index, Command: command, X: state.X, Y: state.Y, BlockingRobotID: blocking.id, Err: collision}
+			break
+		}
+
+		state = next
+		plan.Path = append(plan.Path, state)
+		steps++
+		waited = 0
+		index++
+		pending = pending[1:]
+	}
+
+	plan.Final = state
+	plan.Duration = time.Duration(steps)*duration + timedOut
+	return plan, nil
+}
+
+// blocker returns a robot expected to occupy the cell `to` after `step` commands, or to swap cells with the robot moving from `from`
+func blocker(projections map[*Bot][]cell, step int, from cell, to cell) (blocking *Bot, swap bool) {
+	for o, cells := range projections {
+		if at(cells, step+1) == to {
+			return o, false
+		}
+		if at(cells, step) == to && at(cells, step+1) == from {
+			return o, true
+		}
+	}
+	return nil, false
+}
+
+// projection returns the cells a robot is expected to occupy after each command it has yet to perform
+// - `route` contains the remaining commands of the task in progress (if any), followed by the commands of queued tasks
+// - the robot stays in its cell while charging, and stops once its battery is depleted (or it charges off a charging station)
+func projection(b *Bot, route cmdlang.Sequence, battery BatteryModel, stations map[cell]bool) []cell {
+	inProgress := len(route) > 0
+	route = append(cmdlang.Sequence(nil), route...) // copy, as the route is shared with the robot in progress
+
+	queued := b.queuedTasks()
+	if inProgress && len(queued) > 0 {
+		queued = queued[1:] // the first queued task is in progress
+	}
+	for _, taskID := range queued {
+		task, err := b.repository.GetTask(taskID)
+		if err != nil || task.cancelled {
+			continue
+		}
+		sequence, err := cmdlang.Compile(task.command)
+		if err != nil {
+			continue
+		}
+		route = append(route, sequence...)
+	}
+
+	state := b.CurrentState()
+	cells := []cell{{state.X, state.Y}}
+	for _, command := range route {
+		var next RobotState
+		var err error
+		if command == cmdlang.Charge {
+			next, err = battery.charge(b, state, stations[cell{state.X, state.Y}])
+		} else if moved, ok := move(state, command); ok {
+			next, err = battery.drain(b, state, moved)
+		} else {
+			err = ErrOutOfBounds
+		}
+		if err != nil {
+			break // the task would be aborted, hence the robot stops
+		}
+		state = next
+		cells = append(cells, cell{state.X, state.Y})
+	}
+	return cells
+}
+
+// at returns the cell of a projection after `step` commands; robots remain at their final cell once idle
+func at(cells []cell, step int) cell {
+	if step >= len(cells) {
+		return cells[len(cells)-1]
+	}
+	return cells[step]
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	t.Run("test successful plan visits every cell", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetCommandDuration(time.Second)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		plan, err := warehouse.Plan(r1, "N2 E")
+		if err != nil {
+			t.Fatalf("command sequence should be planned; %v", err)
+		}
+		if plan.Failure != nil {
+			t.Errorf("plan should succeed; got: %v", plan.Failure.Err)
+		}
+
+		want := []RobotState{{0, 0, false, 0}, {0, 1, false, 0}, {0, 2, false, 0}, {1, 2, false, 0}}
+		if len(plan.Path) != len(want) {
+			t.Fatalf("incorrect path; got: %v, want: %v", plan.Path, want)
+		}
+		for i := range want {
+			if plan.Path[i] != want[i] {
+				t.Errorf("incorrect path; got: %v, want: %v", plan.Path, want)
+			}
+		}
+		if plan.Final != (RobotState{1, 2, false, 0}) || plan.Duration != 3*time.Second {
+			t.Errorf("plan should finish at (1,2) after 3s; got: %v after %s", plan.Final, plan.Duration)
+		}
+		if state := r1.CurrentState(); state != (RobotState{0, 0, false, 0}) {
+			t.Errorf("planning should not move the robot; got: %v", state)
+		}
+	})
+
+	t.Run("test plan fails at warehouse dimensions", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "N S S")
+		if plan.Failure == nil || plan.Failure.Index != 2 || plan.Failure.Command != 'S' {
+			t.Fatalf("plan should fail at the third command; got: %+v", plan.Failure)
+		}
+		if plan.Final != (RobotState{0, 0, false, 0}) || len(plan.Path) != 3 {
+			t.Errorf("plan should stop at (0,0) after 2 commands; got: %v", plan.Path)
+		}
+	})
+
+	t.Run("test plan fails when blocked by idle robot", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 0, 2, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "N N N")
+		if plan.Failure == nil || plan.Failure.Index != 1 || plan.Failure.BlockingRobotID != "r2" {
+			t.Fatalf("plan should be blocked by `r2` at the second command; got: %+v", plan.Failure)
+		}
+		if want := `robot 'r1' blocked at (0, 2) by robot 'r2'`; plan.Failure.Err.Error() != want {
+			t.Errorf("incorrect failure; got: %s, want: %s", plan.Failure.Err, want)
+		}
+	})
+
+	t.Run("test plan accounts for queued paths of other robots", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 1, 0, NewInMemoryDB())
+		r2, _ := warehouse.AddRobot("r2", 1, 1, 0, NewInMemoryDB())
+		go func() { <-r2.tasks }() // queue without processing
+		r2.EnqueueTask("N")
+
+		// `r2` moves away from (1,1) as `r1` moves into it
+		plan, _ := warehouse.Plan(r1, "E")
+		if plan.Failure != nil {
+			t.Errorf("plan should succeed as `r2` moves north; got: %v", plan.Failure.Err)
+		}
+
+		// `r2` ends up at (1,2)
+		plan, _ = warehouse.Plan(r1, "E N")
+		if plan.Failure == nil || plan.Failure.Index != 1 || plan.Failure.BlockingRobotID != "r2" {
+			t.Errorf("plan should be blocked by `r2` at (1,2); got: %+v", plan.Failure)
+		}
+	})
+
+	t.Run("test invalid command sequence is not planned", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		if _, err := warehouse.Plan(r1, "N A"); err == nil {
+			t.Error("command sequence `N A` is invalid")
+		}
+	})
+
+	t.Run("test plan drains and charges the battery", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 3, MoveCost: 1})
+		warehouse.AddChargingStation(0, 2)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "N N C E2")
+		if plan.Failure != nil {
+			t.Fatalf("plan should succeed; got: %v", plan.Failure.Err)
+		}
+		if plan.Final != (RobotState{2, 2, false, 1}) || len(plan.Path) != 6 {
+			t.Errorf("plan should charge at (0,2) and finish at (2,2) with battery 1; got: %v", plan.Path)
+		}
+	})
+
+	t.Run("test plan fails once the battery is depleted", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 3, MoveCost: 1})
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "E5")
+		if plan.Failure == nil || plan.Failure.Index != 3 || !errors.Is(plan.Failure.Err, ErrBatteryDepleted) {
+			t.Fatalf("plan should fail at the fourth command; got: %+v", plan.Failure)
+		}
+		if plan.Final != (RobotState{3, 0, false, 0}) {
+			t.Errorf("plan should stop at (3,0); got: %v", plan.Final)
+		}
+	})
+
+	t.Run("test plan fails to charge off a charging station", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 3, MoveCost: 1})
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "N C")
+		if plan.Failure == nil || plan.Failure.Index != 1 || !errors.Is(plan.Failure.Err, ErrNoChargingStation) {
+			t.Errorf("plan should fail at the `C` command; got: %+v", plan.Failure)
+		}
+	})
+
+	t.Run("test plan waits for queued paths of other robots", func(t *testing.T) {
+		newWarehouse := func(waitTimeout time.Duration) (*RobotWarehouse, *Bot) {
+			warehouse := NewRobotWarehouse(CollisionWait, waitTimeout)
+			warehouse.SetCommandDuration(time.Second)
+			r1, _ := warehouse.AddRobot("r1", 0, 1, 0, NewInMemoryDB())
+			r2, _ := warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+			go func() { <-r2.tasks }() // queue without processing
+			r2.EnqueueTask("N E")
+			return warehouse, r1
+		}
+
+		// `r2` passes (1,1) while `r1` waits for a command
+		warehouse, r1 := newWarehouse(0)
+		plan, _ := warehouse.Plan(r1, "E")
+		if plan.Failure != nil {
+			t.Fatalf("plan should succeed once `r2` moved on; got: %v", plan.Failure.Err)
+		}
+		if plan.Final != (RobotState{1, 1, false, 0}) || plan.Duration != 2*time.Second {
+			t.Errorf("plan should finish at (1,1) after 2s; got: %v after %s", plan.Final, plan.Duration)
+		}
+
+		warehouse, r1 = newWarehouse(500 * time.Millisecond)
+		plan, _ = warehouse.Plan(r1, "E")
+		if plan.Failure == nil || plan.Failure.BlockingRobotID != "r2" {
+			t.Fatalf("plan should give up waiting for `r2`; got: %+v", plan.Failure)
+		}
+		if want := `robot 'r1' blocked at (1, 1) by robot 'r2' after waiting 500ms`; plan.Failure.Err.Error() != want || plan.Duration != 500*time.Millisecond {
+			t.Errorf("incorrect failure; got: %s after %s, want: %s after 500ms", plan.Failure.Err, plan.Duration, want)
+		}
+	})
+
+	t.Run("test plan waits for idle robot until the wait timeout", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionWait, 3*time.Second)
+		warehouse.SetCommandDuration(time.Second)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 0, 2, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "N N")
+		if plan.Failure == nil || plan.Failure.Index != 1 || plan.Failure.BlockingRobotID != "r2" {
+			t.Fatalf("plan should be blocked by `r2` at the second command; got: %+v", plan.Failure)
+		}
+		if plan.Duration != 4*time.Second {
+			t.Errorf("plan should include the wait timeout; got: %s, want: 4s", plan.Duration)
+		}
+	})
+
+	t.Run("test plan routes around other robots", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionReplan, 0)
+		warehouse.SetCommandDuration(time.Second)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+
+		plan, _ := warehouse.Plan(r1, "E E")
+		if plan.Failure != nil {
+			t.Fatalf("plan should route around `r2`; got: %v", plan.Failure.Err)
+		}
+		if plan.Final != (RobotState{2, 0, false, 0}) || len(plan.Path) != 5 || plan.Duration != 4*time.Second {
+			t.Errorf("plan should reach (2,0) via a detour of 4 commands; got: %v after %s", plan.Path, plan.Duration)
+		}
+	})
+}
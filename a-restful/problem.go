@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+)
+
+// problemContentType is the media type of RFC 7807 problem details responses
+const problemContentType = "application/problem+json"
+
+// ErrorCode is a machine-readable code identifying the kind of an error; clients should branch on the code rather than the detail
+type ErrorCode string
+
+const (
+	// CodeInvalidRequest is a request body which is malformed or does not match the OpenAPI spec
+	CodeInvalidRequest ErrorCode = "invalid-request"
+	// CodeInvalidCommandSequence is a command sequence which is not valid in the command language
+	CodeInvalidCommandSequence ErrorCode = "invalid-command-sequence"
+	// CodeOutOfBounds is a command or position which exceeds the warehouse dimensions
+	CodeOutOfBounds ErrorCode = "out-of-bounds"
+	// CodeTaskNotFound is a task which does not exist
+	CodeTaskNotFound ErrorCode = "task-not-found"
+	// CodeTaskRunning is a task which is being executed, hence can no longer be cancelled
+	CodeTaskRunning ErrorCode = "task-running"
+	// CodeTaskFinished is a task which has already been executed, hence can no longer be cancelled
+	CodeTaskFinished ErrorCode = "task-finished"
+	// CodeRobotNotFound is a robot which does not operate within the warehouse
+	CodeRobotNotFound ErrorCode = "robot-not-found"
+	// CodeRobotConflict is a robot which cannot be added, as its ID or position is taken
+	CodeRobotConflict ErrorCode = "robot-conflict"
+	// CodeCollision is a task aborted as another robot blocked the path of the robot
+	CodeCollision ErrorCode = "collision"
+	// CodeDeadlock is a task aborted to resolve a deadlock among robots
+	CodeDeadlock ErrorCode = "deadlock"
+	// CodeBatteryDepleted is a task aborted as the battery of the robot is too low to perform its next move
+	CodeBatteryDepleted ErrorCode = "battery-depleted"
+	// CodeNoChargingStation is a task whose `C` (charge) command is performed off a charging station
+	CodeNoChargingStation ErrorCode = "no-charging-station"
+	// CodeTaskFailed is a task which failed for any other reason
+	CodeTaskFailed ErrorCode = "task-failed"
+	// CodeUnauthenticated is a request without valid credentials
+	CodeUnauthenticated ErrorCode = "unauthenticated"
+	// CodeForbidden is a request of a principal lacking the role required by the endpoint
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeQueueFull is a task rejected as the robot has queued the maximum number of tasks
+	CodeQueueFull ErrorCode = "queue-full"
+	// CodeShuttingDown is a task (or robot) rejected or aborted as the server is shutting down
+	CodeShuttingDown ErrorCode = "shutting-down"
+	// CodeIdempotencyKeyInUse is a retried request whose first request (of the same idempotency key) is in progress
+	CodeIdempotencyKeyInUse ErrorCode = "idempotency-key-in-use"
+	// CodeIdempotencyKeyMismatch is an idempotency key reused for a different request
+	CodeIdempotencyKeyMismatch ErrorCode = "idempotency-key-mismatch"
+	// CodeIdempotencyUnavailable is an idempotency key store which cannot be accessed
+	CodeIdempotencyUnavailable ErrorCode = "idempotency-unavailable"
+	// CodeAuditUnavailable is an audit log which cannot be read
+	CodeAuditUnavailable ErrorCode = "audit-unavailable"
+	// CodeStreamingUnsupported is an event stream which the connection does not support
+	CodeStreamingUnsupported ErrorCode = "streaming-unsupported"
+	// CodeSpecViolation is a response which does not match the OpenAPI spec (only reported when validating responses)
+	CodeSpecViolation ErrorCode = "spec-violation"
+)
+
+// errorTitles are the (human-readable) summaries of the error codes
+var errorTitles = map[ErrorCode]string{
+	CodeInvalidRequest:         "Invalid request body",
+	CodeInvalidCommandSequence: "Invalid command sequence",
+	CodeOutOfBounds:            "Out of warehouse bounds",
+	CodeTaskNotFound:           "Task not found",
+	CodeTaskRunning:            "Task is running",
+	CodeTaskFinished:           "Task has finished",
+	CodeRobotNotFound:          "Robot not found",
+	CodeRobotConflict:          "Robot conflict",
+	CodeCollision:              "Robot collision",
+	CodeDeadlock:               "Robot deadlock",
+	CodeBatteryDepleted:        "Battery depleted",
+	CodeNoChargingStation:      "No charging station",
+	CodeTaskFailed:             "Task failed",
+	CodeUnauthenticated:        "Unauthenticated",
+	CodeForbidden:              "Forbidden",
+	CodeQueueFull:              "Task queue full",
+	CodeShuttingDown:           "Shutting down",
+	CodeIdempotencyKeyInUse:    "Idempotency key in use",
+	CodeIdempotencyKeyMismatch: "Idempotency key mismatch",
+	CodeIdempotencyUnavailable: "Idempotency key store unavailable",
+	CodeAuditUnavailable:       "Audit log unavailable",
+	CodeStreamingUnsupported:   "Streaming unsupported",
+	CodeSpecViolation:          "Response does not match the spec",
+}
+
+// Problem is an RFC 7807 (https://tools.ietf.org/html/rfc7807) problem details body; the error envelope of every endpoint
+// - Code is a machine-readable error code; the type is the URN of the code
+// - Status is omitted for errors which are not responses, e.g. `roboterror` events of the state subscription
+type Problem struct {
+	Type     string    `json:"type"`
+	Code     ErrorCode `json:"code" enum:"invalid-request,invalid-command-sequence,out-of-bounds,task-not-found,task-running,task-finished,robot-not-found,robot-conflict,collision,deadlock,battery-depleted,no-charging-station,task-failed,unauthenticated,forbidden,queue-full,shutting-down,idempotency-key-in-use,idempotency-key-mismatch,idempotency-unavailable,audit-unavailable,streaming-unsupported,spec-violation"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status,omitempty"`
+	Detail   string    `json:"detail"`
+	Instance string    `json:"instance,omitempty"`
+}
+
+// CommandProblem is a problem details response body describing an invalid command sequence
+// - extends the problem details with the offending token, its position and the reason it is invalid
+type CommandProblem struct {
+	Problem
+
+	Reason cmdlang.Reason `json:"reason" enum:"invalid-command,multi-letter-command,unexpected-token,unexpected-end,unclosed-group,empty-group,missing-command,invalid-repeat-count,too-many-commands,empty-sequence"`
+	Token  string         `json:"token"`
+	Offset int            `json:"offset"`
+	Line   int            `json:"line"`
+	Column int            `json:"column"`
+}
+
+// newProblem creates the problem details of an error
+func newProblem(code ErrorCode, status int, err error) Problem {
+	return Problem{
+		Type:   "urn:robot:problem:" + string(code),
+		Code:   code,
+		Title:  errorTitles[code],
+		Status: status,
+		Detail: err.Error(),
+	}
+}
+
+// taskProblem creates the problem details of an error of a task performed by a robot
+func taskProblem(err error) Problem {
+	var collision *CollisionError
+	var deadlock *DeadlockError
+	var cmdErr *cmdlang.Error
+	switch {
+	case errors.As(err, &deadlock):
+		return newProblem(CodeDeadlock, 0, err)
+	case errors.As(err, &collision):
+		return newProblem(CodeCollision, 0, err)
+	case errors.As(err, &cmdErr):
+		return newProblem(CodeInvalidCommandSequence, 0, err)
+	case errors.Is(err, ErrOutOfBounds):
+		return newProblem(CodeOutOfBounds, 0, err)
+	case errors.Is(err, ErrBatteryDepleted):
+		return newProblem(CodeBatteryDepleted, 0, err)
+	case errors.Is(err, ErrNoChargingStation):
+		return newProblem(CodeNoChargingStation, 0, err)
+	case errors.Is(err, ErrShuttingDown):
+		return newProblem(CodeShuttingDown, 0, err)
+	}
+	return newProblem(CodeTaskFailed, 0, err)
+}
+
+// writeError writes an error as a problem details response
+func writeError(w http.ResponseWriter, r *http.Request, status int, code ErrorCode, err error) {
+	writeProblem(w, r, newProblem(code, status, err))
+}
+
+// writeCommandProblem writes a command sequence validation error as a `400` problem details response
+func writeCommandProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := CommandProblem{Problem: newProblem(CodeInvalidCommandSequence, http.StatusBadRequest, err)}
+	problem.Instance = r.URL.Path
+
+	var cmdErr *cmdlang.Error
+	if errors.As(err, &cmdErr) {
+		problem.Reason = cmdErr.Reason
+		problem.Token = cmdErr.Token
+		problem.Offset = cmdErr.Pos.Offset
+		problem.Line = cmdErr.Pos.Line
+		problem.Column = cmdErr.Pos.Column
+	}
+
+	writeBody(w, problemContentType, problem.Status, problem)
+}
+
+// writeProblem writes a problem details response; the instance defaults to the request path
+func writeProblem(w http.ResponseWriter, r *http.Request, problem Problem) {
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+	writeBody(w, problemContentType, problem.Status, problem)
+}
@@ -0,0 +1,4 @@
+// Package robotpb contains the protobuf messages and gRPC service of the robot API (see robot.proto)
+package robotpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative robot.proto
@@ -0,0 +1,336 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: robot.proto
+
+// Package robot.v1 is the gRPC API of the robots of a warehouse; it mirrors the RESTful API
+
+package robotpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RobotService_EnqueueTask_FullMethodName   = "/robot.v1.RobotService/EnqueueTask"
+	RobotService_CancelTask_FullMethodName    = "/robot.v1.RobotService/CancelTask"
+	RobotService_GetTask_FullMethodName       = "/robot.v1.RobotService/GetTask"
+	RobotService_ListRobots_FullMethodName    = "/robot.v1.RobotService/ListRobots"
+	RobotService_GetRobotState_FullMethodName = "/robot.v1.RobotService/GetRobotState"
+	RobotService_WatchRobot_FullMethodName    = "/robot.v1.RobotService/WatchRobot"
+)
+
+// RobotServiceClient is the client API for RobotService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RobotServiceClient interface {
+	// EnqueueTask queues a task moving a robot by a sequence of commands (operator role)
+	EnqueueTask(ctx context.Context, in *EnqueueTaskRequest, opts ...grpc.CallOption) (*EnqueueTaskResponse, error)
+	// CancelTask cancels a queued task (operator role)
+	CancelTask(ctx context.Context, in *CancelTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// GetTask gets the execution status of a task
+	GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	// ListRobots lists the robots of the warehouse
+	ListRobots(ctx context.Context, in *ListRobotsRequest, opts ...grpc.CallOption) (*ListRobotsResponse, error)
+	// GetRobotState gets the current state of a robot
+	GetRobotState(ctx context.Context, in *GetRobotStateRequest, opts ...grpc.CallOption) (*Robot, error)
+	// WatchRobot streams the events of a robot; the stream ends with a shutdown event once the server is shutting down
+	WatchRobot(ctx context.Context, in *WatchRobotRequest, opts ...grpc.CallOption) (RobotService_WatchRobotClient, error)
+}
+
+type robotServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRobotServiceClient(cc grpc.ClientConnInterface) RobotServiceClient {
+	return &robotServiceClient{cc}
+}
+
+func (c *robotServiceClient) EnqueueTask(ctx context.Context, in *EnqueueTaskRequest, opts ...grpc.CallOption) (*EnqueueTaskResponse, error) {
+	out := new(EnqueueTaskResponse)
+	err := c.cc.Invoke(ctx, RobotService_EnqueueTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *robotServiceClient) CancelTask(ctx context.Context, in *CancelTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, RobotService_CancelTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *robotServiceClient) GetTask(ctx context.Context, in *GetTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := c.cc.Invoke(ctx, RobotService_GetTask_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *robotServiceClient) ListRobots(ctx context.Context, in *ListRobotsRequest, opts ...grpc.CallOption) (*ListRobotsResponse, error) {
+	out := new(ListRobotsResponse)
+	err := c.cc.Invoke(ctx, RobotService_ListRobots_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *robotServiceClient) GetRobotState(ctx context.Context, in *GetRobotStateRequest, opts ...grpc.CallOption) (*Robot, error) {
+	out := new(Robot)
+	err := c.cc.Invoke(ctx, RobotService_GetRobotState_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *robotServiceClient) WatchRobot(ctx context.Context, in *WatchRobotRequest, opts ...grpc.CallOption) (RobotService_WatchRobotClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RobotService_ServiceDesc.Streams[0], RobotService_WatchRobot_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &robotServiceWatchRobotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type RobotService_WatchRobotClient interface {
+	Recv() (*RobotEvent, error)
+	grpc.ClientStream
+}
+
+type robotServiceWatchRobotClient struct {
+	grpc.ClientStream
+}
+
+func (x *robotServiceWatchRobotClient) Recv() (*RobotEvent, error) {
+	m := new(RobotEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RobotServiceServer is the server API for RobotService service.
+// All implementations must embed UnimplementedRobotServiceServer
+// for forward compatibility
+type RobotServiceServer interface {
+	// EnqueueTask queues a task moving a robot by a sequence of commands (operator role)
+	EnqueueTask(context.Context, *EnqueueTaskRequest) (*EnqueueTaskResponse, error)
+	// CancelTask cancels a queued task (operator role)
+	CancelTask(context.Context, *CancelTaskRequest) (*Task, error)
+	// GetTask gets the execution status of a task
+	GetTask(context.Context, *GetTaskRequest) (*Task, error)
+	// ListRobots lists the robots of the warehouse
+	ListRobots(context.Context, *ListRobotsRequest) (*ListRobotsResponse, error)
+	// GetRobotState gets the current state of a robot
+	GetRobotState(context.Context, *GetRobotStateRequest) (*Robot, error)
+	// WatchRobot streams the events of a robot; the stream ends with a shutdown event once the server is shutting down
+	WatchRobot(*WatchRobotRequest, RobotService_WatchRobotServer) error
+	mustEmbedUnimplementedRobotServiceServer()
+}
+
+// UnimplementedRobotServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRobotServiceServer struct {
+}
+
+func (UnimplementedRobotServiceServer) EnqueueTask(context.Context, *EnqueueTaskRequest) (*EnqueueTaskResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnqueueTask not implemented")
+}
+func (UnimplementedRobotServiceServer) CancelTask(context.Context, *CancelTaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelTask not implemented")
+}
+func (UnimplementedRobotServiceServer) GetTask(context.Context, *GetTaskRequest) (*Task, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTask not implemented")
+}
+func (UnimplementedRobotServiceServer) ListRobots(context.Context, *ListRobotsRequest) (*ListRobotsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRobots not implemented")
+}
+func (UnimplementedRobotServiceServer) GetRobotState(context.Context, *GetRobotStateRequest) (*Robot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRobotState not implemented")
+}
+func (UnimplementedRobotServiceServer) WatchRobot(*WatchRobotRequest, RobotService_WatchRobotServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRobot not implemented")
+}
+func (UnimplementedRobotServiceServer) mustEmbedUnimplementedRobotServiceServer() {}
+
+// UnsafeRobotServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RobotServiceServer will
+// result in compilation errors.
+type UnsafeRobotServiceServer interface {
+	mustEmbedUnimplementedRobotServiceServer()
+}
+
+func RegisterRobotServiceServer(s grpc.ServiceRegistrar, srv RobotServiceServer) {
+	s.RegisterService(&RobotService_ServiceDesc, srv)
+}
+
+func _RobotService_EnqueueTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnqueueTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RobotServiceServer).EnqueueTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RobotService_EnqueueTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RobotServiceServer).EnqueueTask(ctx, req.(*EnqueueTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RobotService_CancelTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RobotServiceServer).CancelTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RobotService_CancelTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RobotServiceServer).CancelTask(ctx, req.(*CancelTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RobotService_GetTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RobotServiceServer).GetTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RobotService_GetTask_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RobotServiceServer).GetTask(ctx, req.(*GetTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RobotService_ListRobots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRobotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RobotServiceServer).ListRobots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RobotService_ListRobots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RobotServiceServer).ListRobots(ctx, req.(*ListRobotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RobotService_GetRobotState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRobotStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RobotServiceServer).GetRobotState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RobotService_GetRobotState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RobotServiceServer).GetRobotState(ctx, req.(*GetRobotStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RobotService_WatchRobot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRobotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RobotServiceServer).WatchRobot(m, &robotServiceWatchRobotServer{stream})
+}
+
+type RobotService_WatchRobotServer interface {
+	Send(*RobotEvent) error
+	grpc.ServerStream
+}
+
+type robotServiceWatchRobotServer struct {
+	grpc.ServerStream
+}
+
+func (x *robotServiceWatchRobotServer) Send(m *RobotEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// RobotService_ServiceDesc is the grpc.ServiceDesc for RobotService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RobotService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "robot.v1.RobotService",
+	HandlerType: (*RobotServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "EnqueueTask",
+			Handler:    _RobotService_EnqueueTask_Handler,
+		},
+		{
+			MethodName: "CancelTask",
+			Handler:    _RobotService_CancelTask_Handler,
+		},
+		{
+			MethodName: "GetTask",
+			Handler:    _RobotService_GetTask_Handler,
+		},
+		{
+			MethodName: "ListRobots",
+			Handler:    _RobotService_ListRobots_Handler,
+		},
+		{
+			MethodName: "GetRobotState",
+			Handler:    _RobotService_GetRobotState_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRobot",
+			Handler:       _RobotService_WatchRobot_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "robot.proto",
+}
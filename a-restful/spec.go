@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zees-dev/robot-challenge/a-restful/openapi"
+)
+
+// maxRequestBody is the maximum size (in bytes) of a request body validated against the spec
+const maxRequestBody = 1 << 20
+
+// content maps the media types of a body to (zero values of) the Go types describing it
+// - e.g. `content{"application/json": TaskIDResponse{}}`; strings describe plain text
+type content map[string]interface{}
+
+// anyOf describes a body which may be any of several Go types
+type anyOf []interface{}
+
+// endpoint describes an API route; the OpenAPI spec is generated from the endpoints registered with the router
+// - endpoints require the viewer role unless a role is set; public endpoints do not require authentication
+// - streaming endpoints (e.g. SSE) are exempt from response validation
+type endpoint struct {
+	method      string
+	path        string
+	tag         string
+	summary     string
+	description string
+	query       []param
+	headers     []param
+	request     interface{}
+	responses   map[int]content
+	role        Role
+	public      bool
+	streaming   bool
+}
+
+// param is an optional query or header parameter of an endpoint
+type param struct {
+	name        string
+	description string
+}
+
+// requiredRole returns the role a principal requires to access the endpoint
+func (e endpoint) requiredRole() Role {
+	if e.role < RoleViewer {
+		return RoleViewer
+	}
+	return e.role
+}
+
+// apiSpec is the OpenAPI spec generated from the registered endpoints
+// - requests are validated against the spec before reaching the handlers
+// - if `validateResponses` is set, responses are validated too; a response which does not match the spec is replaced by a `500`
+type apiSpec struct {
+	doc               openapi.Document
+	generator         *openapi.Generator
+	endpoints         map[string]endpoint // "METHOD /path/{template}" -> endpoint
+	validateResponses bool
+}
+
+var pathParam = regexp.MustCompile(`{([^}:]+)}`)
+
+// newAPISpec creates an empty spec
+func newAPISpec(validateResponses bool) *apiSpec {
+	generator := openapi.NewGenerator()
+	return &apiSpec{
+		doc: openapi.Document{
+			OpenAPI: "3.0.3",
+			Info: openapi.Info{
+				Title:       "Robot API",
+				Description: "This is the Open API spec for the Restful robot server; generated from the route definitions of the server.",
+				Version:     "1.0.0",
+				License:     &openapi.License{Name: "Unlicense", URL: "https://unlicense.org/"},
+			},
+			Tags: []openapi.Tag{
+				{Name: "Health", Description: "Robot server health"},
+				{Name: "State", Description: "Robot states"},
+				{Name: "Task", Description: "Robot tasks"},
+				{Name: "Robots", Description: "Robots operating within the warehouse"},
+				{Name: "Layout", Description: "Layout of the warehouse, e.g. its charging stations"},
+				{Name: "Audit", Description: "Audit trail of operator actions"},
+			},
+			Paths: make(map[string]*openapi.PathItem),
+			Components: openapi.Components{
+				Schemas: generator.Schemas,
+				SecuritySchemes: map[string]*openapi.SecurityScheme{
+					"apiKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Static API key"},
+					"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "HS256 signed JWT carrying `sub` and `role` claims"},
+				},
+			},
+		},
+		generator:         generator,
+		endpoints:         make(map[string]endpoint),
+		validateResponses: validateResponses,
+	}
+}
+
+// handle registers the handler of an endpoint with the router and adds the endpoint to the spec
+func (s *apiSpec) handle(router *mux.Router, e endpoint, handler http.HandlerFunc) {
+	router.HandleFunc(e.path, handler).Methods(e.method)
+
+	// the validation middleware rejects malformed request bodies with a problem details response
+	if e.request != nil {
+		if e.responses[http.StatusBadRequest] == nil {
+			e.responses[http.StatusBadRequest] = content{}
+		}
+		bad := e.responses[http.StatusBadRequest]
+		switch body := bad[problemContentType].(type) {
+		case nil:
+			bad[problemContentType] = Problem{}
+		case anyOf:
+			bad[problemContentType] = append(body, Problem{})
+		default:
+			bad[problemContentType] = anyOf{body, Problem{}}
+		}
+	}
+
+	// authentication is only enforced if the server is configured with credentials
+	if !e.public {
+		e.responses[http.StatusUnauthorized] = content{problemContentType: Problem{}}
+		e.responses[http.StatusForbidden] = content{problemContentType: Problem{}}
+	}
+
+	op := &openapi.Operation{
+		Tags:        []string{e.tag},
+		Summary:     e.summary,
+		Description: e.description,
+		Responses:   make(map[string]*openapi.Response),
+	}
+	if !e.public {
+		op.Security = []openapi.SecurityRequirement{{"apiKey": {}}, {"bearer": {}}}
+		requires := fmt.Sprintf("Requires the `%s` role.", e.requiredRole())
+		op.Description = strings.TrimSpace(op.Description + " " + requires)
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(e.path, -1) {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	for _, p := range e.query {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: p.name, In: "query", Description: p.description, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	for _, p := range e.headers {
+		op.Parameters = append(op.Parameters, openapi.Parameter{
+			Name: p.name, In: "header", Description: p.description, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	if e.request != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]openapi.MediaType{"application/json": {Schema: s.schema(e.request)}},
+		}
+	}
+	for status, body := range e.responses {
+		res := &openapi.Response{Description: http.StatusText(status)}
+		for mediaType, v := range body {
+			if res.Content == nil {
+				res.Content = make(map[string]openapi.MediaType)
+			}
+			res.Content[mediaType] = openapi.MediaType{Schema: s.schema(v)}
+		}
+		op.Responses[strconv.Itoa(status)] = res
+	}
+
+	item, ok := s.doc.Paths[e.path]
+	if !ok {
+		item = &openapi.PathItem{}
+		s.doc.Paths[e.path] = item
+	}
+	(*item)[strings.ToLower(e.method)] = op
+	s.endpoints[e.method+" "+e.path] = e
+}
+
+// schema returns the schema of (the Go type of) a body
+func (s *apiSpec) schema(v interface{}) *openapi.Schema {
+	if options, ok := v.(anyOf); ok {
+		schema := &openapi.Schema{}
+		for _, option := range options {
+			schema.AnyOf = append(schema.AnyOf, s.generator.SchemaOf(option))
+		}
+		return schema
+	}
+	return s.generator.SchemaOf(v)
+}
+
+// operation returns the spec of the endpoint handling a request
+func (s *apiSpec) operation(r *http.Request) (endpoint, *openapi.Operation, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return endpoint{}, nil, false
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return endpoint{}, nil, false
+	}
+	e, ok := s.endpoints[r.Method+" "+path]
+	if !ok {
+		return endpoint{}, nil, false
+	}
+	return e, (*s.doc.Paths[path])[strings.ToLower(r.Method)], true
+}
+
+// serveSpec serves the OpenAPI spec as JSON
+func (s *apiSpec) serveSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.doc)
+}
+
+// middleware validates requests (and responses, if enabled) of the endpoints of the spec
+func (s *apiSpec) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e, op, ok := s.operation(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if op.RequestBody != nil {
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+			if err == nil {
+				err = s.doc.Components.ValidateJSON(op.RequestBody.Content["application/json"].Schema, body)
+			}
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidRequest, fmt.Errorf("request body does not match the spec: %v", err))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		if !s.validateResponses || e.streaming {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if err := s.validateResponse(op, rec); err != nil {
+			log.Printf("%s %s: response does not match the spec: %v", r.Method, r.URL.Path, err)
+			writeError(w, r, http.StatusInternalServerError, CodeSpecViolation, fmt.Errorf("%d response does not match the spec: %v", rec.status, err))
+			return
+		}
+
+		for key, values := range rec.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// validateResponse checks the status, content type and (JSON) body of a response against the operation
+func (s *apiSpec) validateResponse(op *openapi.Operation, rec *responseRecorder) error {
+	res, ok := op.Responses[strconv.Itoa(rec.status)]
+	if !ok {
+		return fmt.Errorf("undocumented status %d", rec.status)
+	}
+	if len(res.Content) == 0 {
+		if rec.body.Len() > 0 {
+			return fmt.Errorf("unexpected body")
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	body, ok := res.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("undocumented content type '%s'", rec.header.Get("Content-Type"))
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	return s.doc.Components.ValidateJSON(body.Schema, rec.body.Bytes())
+}
+
+// responseRecorder buffers a response, so it can be validated before it is sent
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
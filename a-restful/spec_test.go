@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPISpecEndpoint(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Tags    []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+		Paths map[string]map[string]struct {
+			Tags []string `json:"tags"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("incorrect open api version; got: %s", doc.OpenAPI)
+	}
+
+	for path, methods := range map[string][]string{
+		"/health":                   {"get"},
+		"/api/v1/state":             {"get", "put"},
+		"/api/v1/state/subscribe":   {"get"},
+		"/api/v1/task/{id}":         {"get", "delete"},
+		"/api/v1/robots":            {"get", "post"},
+		"/api/v1/robots/{id}":       {"delete"},
+		"/api/v1/layout":            {"get", "put"},
+		"/api/v1/robots/{id}/state": {"get", "put"},
+		"/api/v1/robots/{id}/plan":  {"post"},
+	} {
+		for _, method := range methods {
+			if _, ok := doc.Paths[path][method]; !ok {
+				t.Errorf("spec must describe %s %s", strings.ToUpper(method), path)
+			}
+		}
+	}
+	declared := map[string]bool{}
+	for _, tag := range doc.Tags {
+		declared[tag.Name] = true
+	}
+	for path, operations := range doc.Paths {
+		for method, operation := range operations {
+			for _, tag := range operation.Tags {
+				if !declared[tag] {
+					t.Errorf("tag '%s' of %s %s must be declared", tag, strings.ToUpper(method), path)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"UpdateBot", "TaskResponse", "PlanResponse", "CommandProblem"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("spec must contain schema '%s'", name)
+		}
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	handler := getWarehouseHTTPHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		detail string
+	}{
+		{"wrong type", "PUT", "/api/v1/state", `{"commands":5}`, "request body does not match the spec: $.commands: must be a string, not a number"},
+		{"missing property", "PUT", "/api/v1/robots/r1/state", `{}`, "request body does not match the spec: $: missing required property 'commands'"},
+		{"unknown property", "POST", "/api/v1/robots", `{"id":"r2","z":1}`, "request body does not match the spec: $: unknown property 'z'"},
+		{"negative position", "POST", "/api/v1/robots", `{"id":"r2","x":-1}`, "request body does not match the spec: $.x: must be at least 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("test request with %s is rejected", tt.name), func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			}
+			if got := rr.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("incorrect content type; got: %s, want: %s", got, problemContentType)
+			}
+
+			var problem Problem
+			json.Unmarshal(rr.Body.Bytes(), &problem)
+			if problem.Detail != tt.detail {
+				t.Errorf("incorrect problem detail; got: %s, want: %s", problem.Detail, tt.detail)
+			}
+			if problem.Code != CodeInvalidRequest || problem.Type != "urn:robot:problem:invalid-request" {
+				t.Errorf("incorrect problem code; got: %s (%s), want: %s", problem.Code, problem.Type, CodeInvalidRequest)
+			}
+		})
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	router := mux.NewRouter()
+	spec := newAPISpec(true)
+	router.Use(spec.middleware)
+
+	spec.handle(router, endpoint{
+		method: "GET", path: "/drift/{field}", tag: "Health",
+		responses: map[int]content{200: {"application/json": HealthResponse{}}},
+	}, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"%s":"healthy"}`, mux.Vars(r)["field"])
+	})
+
+	t.Run("test response matching the spec is sent", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/drift/status", nil)
+		router.ServeHTTP(rr, req)
+
+		if got, want := rr.Body.String(), `{"status":"healthy"}`; rr.Code != http.StatusOK || got != want {
+			t.Errorf("incorrect response; got: %d %s, want: 200 %s", rr.Code, got, want)
+		}
+	})
+
+	t.Run("test response drifting from the spec is replaced", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/drift/state", nil)
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusInternalServerError {
+			t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
+		}
+
+		var problem Problem
+		json.Unmarshal(rr.Body.Bytes(), &problem)
+		if want := "200 response does not match the spec: $: missing required property 'status'"; problem.Detail != want {
+			t.Errorf("incorrect problem detail; got: %s, want: %s", problem.Detail, want)
+		}
+		if problem.Code != CodeSpecViolation {
+			t.Errorf("incorrect problem code; got: %s, want: %s", problem.Code, CodeSpecViolation)
+		}
+	})
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"sync"
+	"time"
 )
 
 // Repository contains signature which a storage/persistent layer must implement
 // * This enables support for a pluggable persistent layer
+// * errors of missing tasks must be caused by `ErrTaskNotFound`
 type Repository interface {
 	GetTask(id string) (Task, error)
 	CreateTask(ct Task) error
 	UpdateTask(ut Task) error
 }
 
+// Flusher is implemented by storage layers which buffer writes; buffered writes are flushed on shutdown
+type Flusher interface {
+	Flush() error
+}
+
 // InMemoryDB is a struct which stores robot tasks in-memory
 type InMemoryDB struct {
 	mu    sync.RWMutex // RW mutex to allow multiple readers but single writer
@@ -33,7 +44,7 @@ func (db *InMemoryDB) GetTask(id string) (Task, error) {
 			return t, nil
 		}
 	}
-	return Task{}, fmt.Errorf("Task with ID '%s' not found", id)
+	return Task{}, fmt.Errorf("Task with ID '%s' %w", id, ErrTaskNotFound)
 }
 
 // CreateTask creates task in in-memory DB by ID in a concurrent-safe way
@@ -59,5 +70,172 @@ func (db *InMemoryDB) UpdateTask(ut Task) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("Task with ID '%s' not found", ut.id)
+	return fmt.Errorf("Task with ID '%s' %w", ut.id, ErrTaskNotFound)
+}
+
+// AuditLog is an append-only trail of operator actions
+// * This enables support for a pluggable persistent layer
+type AuditLog interface {
+	Append(entry AuditEntry) (AuditEntry, error)
+	Entries(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// InMemoryAuditLog stores audit entries in-memory, optionally appending them to a writer (e.g. a file) as JSON Lines
+type InMemoryAuditLog struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+	w       io.Writer
+}
+
+// NewInMemoryAuditLog instantiates an empty audit log; entries are additionally written to `w` unless nil
+func NewInMemoryAuditLog(w io.Writer) *InMemoryAuditLog {
+	return &InMemoryAuditLog{w: w}
+}
+
+// OpenAuditLogFile instantiates an audit log appending to a JSON Lines file; entries of the file (of previous runs) are loaded
+// - the caller is responsible for closing the file
+func OpenAuditLogFile(path string) (*InMemoryAuditLog, *os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := NewInMemoryAuditLog(f)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		l.entries = append(l.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return l, f, nil
+}
+
+// Append adds an entry to the audit log in a concurrent-safe way, assigning the next sequence number
+func (l *InMemoryAuditLog) Append(entry AuditEntry) (AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.Sequence = int64(len(l.entries)) + 1
+	if l.w != nil {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return AuditEntry{}, err
+		}
+		if _, err := l.w.Write(append(data, '\n')); err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	l.entries = append(l.entries, entry)
+	return entry, nil
+}
+
+// Flush commits the entries written to the writer (e.g. a file) to stable storage, if the writer supports it
+// * implements flusher
+func (l *InMemoryAuditLog) Flush() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := l.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Entries returns the entries matching the filter (oldest first) in a concurrent-safe way
+func (l *InMemoryAuditLog) Entries(filter AuditFilter) ([]AuditEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	entries := []AuditEntry{}
+	for _, entry := range l.entries {
+		if filter.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+// IdempotencyStore remembers the responses of requests by idempotency key, so retried requests are not performed twice
+// * This enables support for a pluggable persistent layer
+// - a key is reserved while its request is in progress, and completed with the response of the request
+type IdempotencyStore interface {
+	Reserve(key string, fingerprint string) (record IdempotencyRecord, reserved bool, err error)
+	Complete(key string, response IdempotentResponse) error
+	Release(key string) error
+}
+
+// IdempotencyRecord is the request (fingerprint) and response of an idempotency key; the response is nil while the request is in progress
+type IdempotencyRecord struct {
+	Key         string
+	Fingerprint string
+	Created     time.Time
+	Response    *IdempotentResponse
+}
+
+// IdempotentResponse is a response replayed to retries of a request
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// InMemoryIdempotencyStore stores idempotency keys in-memory for a window of time
+type InMemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	window  time.Duration
+	records map[string]IdempotencyRecord
+	now     func() time.Time
+}
+
+// NewInMemoryIdempotencyStore instantiates an empty store; keys expire after the window
+func NewInMemoryIdempotencyStore(window time.Duration) *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{window: window, records: make(map[string]IdempotencyRecord), now: time.Now}
+}
+
+// Reserve claims a key for a request in a concurrent-safe way; if the key is already claimed (within the window), its record is returned instead
+func (s *InMemoryIdempotencyStore) Reserve(key string, fingerprint string) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, record := range s.records {
+		if now.Sub(record.Created) >= s.window {
+			delete(s.records, k)
+		}
+	}
+
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	record := IdempotencyRecord{Key: key, Fingerprint: fingerprint, Created: now}
+	s.records[key] = record
+	return record, true, nil
+}
+
+// Complete stores the response of the request of a reserved key in a concurrent-safe way
+func (s *InMemoryIdempotencyStore) Complete(key string, response IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return fmt.Errorf("Idempotency key '%s' not found", key)
+	}
+	record.Response = &response
+	s.records[key] = record
+	return nil
+}
+
+// Release removes a reserved key in a concurrent-safe way, so the request can be retried
+func (s *InMemoryIdempotencyStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
 }
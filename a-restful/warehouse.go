@@ -0,0 +1,765 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+)
+
+// CollisionPolicy determines how a robot behaves when the next cell on its path is occupied by another robot
+type CollisionPolicy int
+
+const (
+	// CollisionFail aborts the task as soon as the next cell is occupied
+	CollisionFail CollisionPolicy = iota
+	// CollisionWait waits for the occupied cell to be released (up to the warehouse wait timeout)
+	CollisionWait
+	// CollisionReplan routes around occupied cells to reach the destination of the task
+	CollisionReplan
+)
+
+var collisionPolicies = map[string]CollisionPolicy{
+	"fail":   CollisionFail,
+	"wait":   CollisionWait,
+	"replan": CollisionReplan,
+}
+
+// ParseCollisionPolicy converts a policy name (`fail`, `wait` or `replan`) to a CollisionPolicy
+func ParseCollisionPolicy(name string) (CollisionPolicy, error) {
+	policy, ok := collisionPolicies[name]
+	if !ok {
+		return CollisionFail, fmt.Errorf("invalid collision policy '%s'; policy can only be one of 'fail', 'wait' or 'replan'", name)
+	}
+	return policy, nil
+}
+
+func (p CollisionPolicy) String() string {
+	for name, policy := range collisionPolicies {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("CollisionPolicy(%d)", int(p))
+}
+
+// CollisionError is returned (via the robot error channel) when a task is aborted because another robot blocks its path
+type CollisionError struct {
+	RobotID         string
+	BlockingRobotID string
+	X               uint
+	Y               uint
+	Waited          time.Duration
+}
+
+func (e *CollisionError) Error() string {
+	if e.Waited > 0 {
+		return fmt.Sprintf("robot '%s' blocked at (%d, %d) by robot '%s' after waiting %s", e.RobotID, e.X, e.Y, e.BlockingRobotID, e.Waited)
+	}
+	return fmt.Sprintf("robot '%s' blocked at (%d, %d) by robot '%s'", e.RobotID, e.X, e.Y, e.BlockingRobotID)
+}
+
+// DeadlockRule determines which robot of a deadlock is selected to resolve it
+type DeadlockRule int
+
+const (
+	// DeadlockLowestPriority selects the robot with the lowest priority (ties are broken by the youngest task)
+	DeadlockLowestPriority DeadlockRule = iota
+	// DeadlockYoungestTask selects the robot executing the most recently queued task
+	DeadlockYoungestTask
+)
+
+var deadlockRules = map[string]DeadlockRule{
+	"priority": DeadlockLowestPriority,
+	"youngest": DeadlockYoungestTask,
+}
+
+// ParseDeadlockRule converts a rule name (`priority` or `youngest`) to a DeadlockRule
+func ParseDeadlockRule(name string) (DeadlockRule, error) {
+	rule, ok := deadlockRules[name]
+	if !ok {
+		return DeadlockLowestPriority, fmt.Errorf("invalid deadlock rule '%s'; rule can only be one of 'priority' or 'youngest'", name)
+	}
+	return rule, nil
+}
+
+// DeadlockResolution determines how the robot selected by the DeadlockRule breaks the deadlock
+type DeadlockResolution int
+
+const (
+	// DeadlockAbort aborts the task of the selected robot
+	DeadlockAbort DeadlockResolution = iota
+	// DeadlockReplan routes the selected robot around occupied cells; the task is aborted if no route exists
+	DeadlockReplan
+)
+
+var deadlockResolutions = map[string]DeadlockResolution{
+	"abort":  DeadlockAbort,
+	"replan": DeadlockReplan,
+}
+
+// ParseDeadlockResolution converts a resolution name (`abort` or `replan`) to a DeadlockResolution
+func ParseDeadlockResolution(name string) (DeadlockResolution, error) {
+	resolution, ok := deadlockResolutions[name]
+	if !ok {
+		return DeadlockAbort, fmt.Errorf("invalid deadlock resolution '%s'; resolution can only be one of 'abort' or 'replan'", name)
+	}
+	return resolution, nil
+}
+
+func (r DeadlockResolution) String() string {
+	for name, resolution := range deadlockResolutions {
+		if resolution == r {
+			return name
+		}
+	}
+	return fmt.Sprintf("DeadlockResolution(%d)", int(r))
+}
+
+// MarshalText encodes the resolution by name within json payloads
+func (r DeadlockResolution) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// DeadlockEvent is published (via the warehouse deadlock channel) whenever robots waiting on each other are detected
+type DeadlockEvent struct {
+	RobotIDs      []string           `json:"robots"`
+	TaskIDs       []string           `json:"tasks"`
+	VictimRobotID string             `json:"victimRobot"`
+	VictimTaskID  string             `json:"victimTask"`
+	Resolution    DeadlockResolution `json:"resolution"`
+}
+
+// DeadlockError is returned (via the robot error channel) when a task is aborted to resolve a deadlock
+type DeadlockError struct {
+	RobotID  string
+	TaskID   string
+	RobotIDs []string
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("robot '%s' aborted task '%s' to resolve deadlock between robots '%s'", e.RobotID, e.TaskID, strings.Join(e.RobotIDs, "', '"))
+}
+
+// BatteryModel configures the batteries of the robots of a warehouse
+// - robots are added fully charged; each move drains MoveCost
+// - a zero Capacity disables the battery model
+type BatteryModel struct {
+	Capacity uint
+	MoveCost uint
+}
+
+// enabled reports whether batteries are modelled
+func (m BatteryModel) enabled() bool {
+	return m.Capacity > 0
+}
+
+// cost is the charge drained by moving a robot by one cell
+func (m BatteryModel) cost() uint {
+	return m.MoveCost
+}
+
+// drain returns the state `next` of a robot moving from `state`, with the cost of the move deducted from its battery
+// - the error (caused by `ErrBatteryDepleted`) is returned if the battery of the robot cannot afford the move
+func (m BatteryModel) drain(b *Bot, state RobotState, next RobotState) (RobotState, error) {
+	if !m.enabled() {
+		return next, nil
+	}
+	if state.Battery < m.cost() {
+		return state, fmt.Errorf("robot '%s' at (%d, %d) %w (battery %d, a move requires %d)", b.id, state.X, state.Y, ErrBatteryDepleted, state.Battery, m.cost())
+	}
+	next.Battery = state.Battery - m.cost()
+	return next, nil
+}
+
+// charge returns the state of a robot performing the `C` command, charged to capacity
+// - the error (caused by `ErrNoChargingStation`) is returned unless the robot is on a charging station
+func (m BatteryModel) charge(b *Bot, state RobotState, station bool) (RobotState, error) {
+	if !station {
+		return state, fmt.Errorf("robot '%s' at (%d, %d) %w", b.id, state.X, state.Y, ErrNoChargingStation)
+	}
+	state.Battery = m.Capacity
+	return state, nil
+}
+
+// cell is a location on the warehouse grid
+type cell struct {
+	x uint
+	y uint
+}
+
+// waiter is an edge of the wait-for graph; a robot executing `task` waits for `cell` which is held by `blockedBy`
+type waiter struct {
+	task      Task
+	cell      cell
+	blockedBy *Bot
+	abort     chan error
+}
+
+// RobotWarehouse is a warehouse containing multiple robots
+// * implements warehouse
+// - robot movement is arbitrated via a cell reservation table; only one robot may occupy a cell at a time
+// - robots waiting for each other (deadlocks) are detected via a wait-for graph
+type RobotWarehouse struct {
+	mu                 sync.Mutex
+	policy             CollisionPolicy
+	waitTimeout        time.Duration
+	deadlockRule       DeadlockRule
+	deadlockResolution DeadlockResolution
+	commandDuration    time.Duration
+	maxQueuedTasks     int
+	battery            BatteryModel
+	stations           map[cell]bool // charging stations
+	bots               []*Bot
+	cells              map[cell]*Bot             // cell reservation table
+	routes             map[*Bot]cmdlang.Sequence // remaining commands of tasks in progress
+	waits              map[*Bot]*waiter          // wait-for graph
+	released           chan struct{}             // closed (and replaced) whenever a cell is released to wake up waiting robots
+	metrics            *Metrics                  // shared by the robots of the warehouse
+	added              []func(*Bot)              // called for each robot added to the warehouse, see `OnAddRobot`
+	shuttingDown       bool
+
+	Deadlocks chan DeadlockEvent
+}
+
+// NewRobotWarehouse instantiates an empty warehouse
+// - waitTimeout only applies to the `CollisionWait` policy; a zero timeout waits indefinitely
+// - deadlocks are resolved by aborting the task of the lowest priority robot, see `SetDeadlockPolicy`
+func NewRobotWarehouse(policy CollisionPolicy, waitTimeout time.Duration) *RobotWarehouse {
+	return &RobotWarehouse{
+		policy:      policy,
+		waitTimeout: waitTimeout,
+		cells:       make(map[cell]*Bot),
+		stations:    make(map[cell]bool),
+		routes:      make(map[*Bot]cmdlang.Sequence),
+		waits:       make(map[*Bot]*waiter),
+		released:    make(chan struct{}),
+		metrics:     NewMetrics(),
+		Deadlocks:   make(chan DeadlockEvent),
+	}
+}
+
+// SetDeadlockPolicy configures which robot is selected to resolve a deadlock, and how it resolves it
+func (w *RobotWarehouse) SetDeadlockPolicy(rule DeadlockRule, resolution DeadlockResolution) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.deadlockRule = rule
+	w.deadlockResolution = resolution
+}
+
+// SetCommandDuration configures the time a robot takes to perform each movement command
+func (w *RobotWarehouse) SetCommandDuration(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.commandDuration = d
+}
+
+// SetMaxQueuedTasks limits the number of tasks each robot added to the warehouse may queue; 0 is only limited by the task buffer (see `taskBuffer`)
+func (w *RobotWarehouse) SetMaxQueuedTasks(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.maxQueuedTasks = n
+}
+
+// SetBattery configures the battery model of the robots added to the warehouse from now on
+func (w *RobotWarehouse) SetBattery(model BatteryModel) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.battery = model
+}
+
+// Battery returns the battery model of the warehouse
+func (w *RobotWarehouse) Battery() BatteryModel {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.battery
+}
+
+// AddChargingStation installs a charging station at the specified location; robots on it may charge using the `C` command
+func (w *RobotWarehouse) AddChargingStation(x uint, y uint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if x > 9 || y > 9 {
+		return fmt.Errorf("Charging station position (%d, %d) %w", x, y, ErrOutOfBounds)
+	}
+	w.stations[cell{x, y}] = true
+	return nil
+}
+
+// SetChargingStations replaces the charging stations of the warehouse; no station is changed if a location is invalid
+func (w *RobotWarehouse) SetChargingStations(stations []RobotState) error {
+	replaced := make(map[cell]bool, len(stations))
+	for _, s := range stations {
+		if s.X > 9 || s.Y > 9 {
+			return fmt.Errorf("Charging station position (%d, %d) %w", s.X, s.Y, ErrOutOfBounds)
+		}
+		replaced[cell{s.X, s.Y}] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stations = replaced
+	return nil
+}
+
+// ChargingStations returns the locations of the charging stations of the warehouse
+func (w *RobotWarehouse) ChargingStations() []RobotState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stations := make([]RobotState, 0, len(w.stations))
+	for c := range w.stations {
+		stations = append(stations, RobotState{X: c.x, Y: c.y})
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].Y < stations[j].Y || (stations[i].Y == stations[j].Y && stations[i].X < stations[j].X)
+	})
+	return stations
+}
+
+// AddRobot instantiates a bot identified by `id` at the specified location of the warehouse
+// - the priority of the robot is used to select which robot gives way when resolving deadlocks
+// - the caller is responsible for running the bot (`listen`)
+func (w *RobotWarehouse) AddRobot(id string, x uint, y uint, priority int, repository Repository) (*Bot, error) {
+	bot, added, err := w.addRobot(id, x, y, priority, repository)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range added {
+		f(bot)
+	}
+	return bot, nil
+}
+
+// addRobot adds a robot in a concurrent-safe way, returning the callbacks of added robots
+func (w *RobotWarehouse) addRobot(id string, x uint, y uint, priority int, repository Repository) (*Bot, []func(*Bot), error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.shuttingDown {
+		return nil, nil, fmt.Errorf("Warehouse %w", ErrShuttingDown)
+	}
+	if x > 9 || y > 9 {
+		return nil, nil, fmt.Errorf("Robot position (%d, %d) %w", x, y, ErrOutOfBounds)
+	}
+	for _, b := range w.bots {
+		if b.id == id {
+			return nil, nil, fmt.Errorf("Robot with ID '%s' already exists", id)
+		}
+	}
+	if holder, ok := w.cells[cell{x, y}]; ok {
+		return nil, nil, fmt.Errorf("Robot position (%d, %d) is occupied by robot '%s'", x, y, holder.id)
+	}
+
+	bot := NewBot(x, y, repository)
+	bot.id = id
+	bot.priority = priority
+	bot.warehouse = w
+	bot.metrics = w.metrics
+	bot.maxQueue = w.maxQueuedTasks
+	if bot.maxQueue > taskBuffer {
+		bot.tasks = make(chan string, bot.maxQueue)
+	}
+	bot.state.Battery = w.battery.Capacity
+	w.bots = append(w.bots, &bot)
+	w.cells[cell{x, y}] = &bot
+	return &bot, append(([]func(*Bot))(nil), w.added...), nil
+}
+
+// OnAddRobot calls f for each robot of the warehouse, and for each robot added to the warehouse from now on
+// - f is called by the goroutine adding the robot, once the robot is added
+func (w *RobotWarehouse) OnAddRobot(f func(b *Bot)) {
+	w.mu.Lock()
+	bots := append([]*Bot(nil), w.bots...)
+	w.added = append(w.added, f)
+	w.mu.Unlock()
+
+	for _, b := range bots {
+		f(b)
+	}
+}
+
+// Robot gets a robot of the warehouse by ID
+func (w *RobotWarehouse) Robot(id string) (*Bot, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.bots {
+		if b.id == id {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("Robot with ID '%s' not found", id)
+}
+
+// Robots returns all robots operating in the warehouse
+// * implements warehouse
+func (w *RobotWarehouse) Robots() []Robot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	robots := make([]Robot, len(w.bots))
+	for i, b := range w.bots {
+		robots[i] = b
+	}
+	return robots
+}
+
+// RemoveRobot gracefully stops a robot (see `Bot.Shutdown`) and removes it from the warehouse, releasing the cell it occupies
+// - once ctx is done, the task in progress is aborted and the error of the context is returned; the robot is removed nonetheless
+func (w *RobotWarehouse) RemoveRobot(ctx context.Context, id string) error {
+	b, err := w.Robot(id)
+	if err != nil {
+		return err
+	}
+	err = b.Shutdown(ctx)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, bot := range w.bots {
+		if bot == b {
+			w.bots = append(w.bots[:i:i], w.bots[i+1:]...)
+			break
+		}
+	}
+	for c, holder := range w.cells {
+		if holder == b {
+			delete(w.cells, c)
+		}
+	}
+	delete(w.routes, b)
+	delete(w.waits, b)
+	// robots waiting for the released cell may proceed
+	close(w.released)
+	w.released = make(chan struct{})
+	return err
+}
+
+// Shutdown gracefully stops all robots of the warehouse concurrently (see `Bot.Shutdown`); robots can no longer be added
+func (w *RobotWarehouse) Shutdown(ctx context.Context) error {
+	w.mu.Lock()
+	w.shuttingDown = true
+	bots := append([]*Bot(nil), w.bots...)
+	w.mu.Unlock()
+
+	errs := make(chan error, len(bots))
+	for _, b := range bots {
+		go func(b *Bot) { errs <- b.Shutdown(ctx) }(b)
+	}
+	var err error
+	for range bots {
+		if e := <-errs; e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// owner returns the robot of the warehouse which has queued a task
+// - tasks are shared by the robots of the warehouse (via the repository), hence a task must be cancelled by the robot executing it
+func (w *RobotWarehouse) owner(taskID string) (*Bot, bool) {
+	w.mu.Lock()
+	bots := append([]*Bot(nil), w.bots...)
+	w.mu.Unlock()
+
+	for _, b := range bots {
+		for _, id := range b.queuedTasks() {
+			if id == taskID {
+				return b, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// traverse moves the bot through the commands of a task one cell at a time, reserving each cell before it is entered
+// - the returned state is the final position of the bot, which is where the bot stopped if an error occurred
+// - the task is aborted (caused by `ErrShuttingDown`) once the context is done
+// - if batteries are modelled, each move drains the battery; the task is aborted (caused by `ErrBatteryDepleted`) before a move the battery cannot afford
+// - `C` commands charge the battery to capacity, taking the duration of a command; they fail (caused by `ErrNoChargingStation`) off a charging station
+func (w *RobotWarehouse) traverse(ctx context.Context, b *Bot, task Task) (RobotState, error) {
+	sequence, err := cmdlang.Compile(task.command)
+	if err != nil {
+		return b.state, err
+	}
+
+	// the remaining commands are published so other robots' tasks can be planned around this robot
+	defer func() {
+		w.mu.Lock()
+		delete(w.routes, b)
+		w.mu.Unlock()
+	}()
+
+	state := b.state
+	pending := sequence
+	for replans := 0; len(pending) > 0; {
+		w.mu.Lock()
+		w.routes[b] = pending
+		duration := w.commandDuration
+		battery := w.battery
+		station := w.stations[cell{state.X, state.Y}]
+		w.mu.Unlock()
+
+		if pending[0] == cmdlang.Charge {
+			next, err := battery.charge(b, state, station)
+			if err != nil {
+				return state, err
+			}
+			select {
+			case <-time.After(duration):
+			case <-ctx.Done():
+				return state, aborted(b, task, state)
+			}
+			if err := b.UpdateCurrentState(next); err != nil {
+				return state, err
+			}
+			b.metrics.commandsExecuted(b.id, 1)
+			state = next
+			pending = pending[1:]
+			continue
+		}
+
+		next, ok := move(state, pending[0])
+		if !ok {
+			return state, fmt.Errorf(`command '%s' of "%s" %w`, string(pending[0]), task.command, ErrOutOfBounds)
+		}
+		if next, err = battery.drain(b, state, next); err != nil {
+			return state, err
+		}
+
+		if err := w.reserve(ctx, b, task, next); err != nil {
+			var deadlock *DeadlockError
+			replan := w.policy == CollisionReplan || (errors.As(err, &deadlock) && w.deadlockResolution == DeadlockReplan)
+			if !replan || replans >= 100 {
+				return state, err
+			}
+			// the route is replanned up to the next charge, as the robot must charge at the same station
+			leg, rest := nextLeg(pending)
+			detour, ok := w.route(b, state, destination(state, leg))
+			if !ok {
+				return state, err
+			}
+			log.Printf("robot '%s' replanned route: %s", b.id, detour)
+			pending = append(detour, rest...)
+			replans++
+			continue
+		}
+
+		select {
+		case <-time.After(duration):
+		case <-ctx.Done():
+			w.release(next)
+			return state, aborted(b, task, state)
+		}
+		if err := b.UpdateCurrentState(next); err != nil {
+			w.release(next)
+			return state, err
+		}
+		w.release(state)
+		b.metrics.commandsExecuted(b.id, 1)
+		state = next
+		pending = pending[1:]
+	}
+	return state, nil
+}
+
+// reserve claims the cell at `rs` for the bot, applying the collision policy of the warehouse if the cell is occupied
+func (w *RobotWarehouse) reserve(ctx context.Context, b *Bot, task Task, rs RobotState) error {
+	c := cell{rs.X, rs.Y}
+	started := time.Now()
+
+	var timeout <-chan time.Time
+	if w.waitTimeout > 0 {
+		timer := time.NewTimer(w.waitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// the bot is removed from the wait-for graph once it stops waiting
+	defer func() {
+		w.mu.Lock()
+		delete(w.waits, b)
+		w.mu.Unlock()
+	}()
+
+	wt := &waiter{task: task, cell: c, abort: make(chan error, 1)}
+	for {
+		w.mu.Lock()
+		holder, occupied := w.cells[c]
+		if !occupied || holder == b {
+			w.cells[c] = b
+			w.mu.Unlock()
+			return nil
+		}
+		released := w.released
+
+		if w.policy != CollisionWait {
+			w.mu.Unlock()
+			return &CollisionError{RobotID: b.id, BlockingRobotID: holder.id, X: c.x, Y: c.y}
+		}
+
+		// the bot may have been selected to resolve a deadlock while it was woken up by a released cell
+		select {
+		case err := <-wt.abort:
+			w.mu.Unlock()
+			return err
+		default:
+		}
+
+		wt.blockedBy = holder
+		w.waits[b] = wt
+		if cycle := w.deadlock(b); cycle != nil {
+			w.resolve(cycle)
+		}
+		w.mu.Unlock()
+
+		select {
+		case <-released:
+		case err := <-wt.abort:
+			return err
+		case <-timeout:
+			return &CollisionError{RobotID: b.id, BlockingRobotID: holder.id, X: c.x, Y: c.y, Waited: time.Since(started)}
+		case <-ctx.Done():
+			return aborted(b, task, b.CurrentState())
+		}
+	}
+}
+
+// aborted is the error of a task aborted at `rs` as the robot is shutting down
+func aborted(b *Bot, task Task, rs RobotState) error {
+	return fmt.Errorf("task %s aborted at (%d, %d); robot '%s' %w", task.id, rs.X, rs.Y, b.id, ErrShuttingDown)
+}
+
+// deadlock follows the wait-for graph from the bot and returns the robots forming a cycle back to the bot (if any)
+// - the caller must hold the warehouse lock
+func (w *RobotWarehouse) deadlock(b *Bot) []*Bot {
+	cycle := []*Bot{b}
+	for current := b; ; {
+		wt, waiting := w.waits[current]
+		// ignore stale edges; the blocking robot may have moved on before the waiting robot woke up
+		if !waiting || w.cells[wt.cell] != wt.blockedBy {
+			return nil
+		}
+		if wt.blockedBy == b {
+			return cycle
+		}
+		for _, visited := range cycle {
+			if visited == wt.blockedBy {
+				return nil
+			}
+		}
+		current = wt.blockedBy
+		cycle = append(cycle, current)
+	}
+}
+
+// resolve selects a robot of the deadlock as per the deadlock rule, then aborts its wait to break the cycle
+// - the caller must hold the warehouse lock
+func (w *RobotWarehouse) resolve(cycle []*Bot) {
+	victim := cycle[0]
+	for _, b := range cycle[1:] {
+		if w.yields(b, victim) {
+			victim = b
+		}
+	}
+
+	event := DeadlockEvent{VictimRobotID: victim.id, VictimTaskID: w.waits[victim].task.id, Resolution: w.deadlockResolution}
+	for _, b := range cycle {
+		event.RobotIDs = append(event.RobotIDs, b.id)
+		event.TaskIDs = append(event.TaskIDs, w.waits[b].task.id)
+	}
+	log.Printf("deadlock detected between robots %v; robot '%s' gives way", event.RobotIDs, victim.id)
+
+	w.waits[victim].abort <- &DeadlockError{RobotID: victim.id, TaskID: event.VictimTaskID, RobotIDs: event.RobotIDs}
+	delete(w.waits, victim)
+	for _, b := range w.bots {
+		b.events.publish(RobotEvent{Name: EventDeadlock, Deadlock: event})
+	}
+	go func() { w.Deadlocks <- event }() // independent consumer can consume deadlocks
+}
+
+// yields determines whether robot `a` should give way to robot `b` when resolving a deadlock
+// - the caller must hold the warehouse lock
+func (w *RobotWarehouse) yields(a *Bot, b *Bot) bool {
+	younger := w.waits[a].task.created.After(w.waits[b].task.created)
+	if w.deadlockRule == DeadlockLowestPriority && a.priority != b.priority {
+		return a.priority < b.priority
+	}
+	return younger
+}
+
+// release frees the cell at `rs` and wakes up any robots waiting for a cell
+func (w *RobotWarehouse) release(rs RobotState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.cells, cell{rs.X, rs.Y})
+	close(w.released)
+	w.released = make(chan struct{})
+}
+
+// route finds the shortest sequence of movement commands from `from` to `to` which avoids cells occupied by other robots
+func (w *RobotWarehouse) route(b *Bot, from RobotState, to RobotState) (cmdlang.Sequence, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return shortestRoute(cell{from.X, from.Y}, cell{to.X, to.Y}, func(c cell) bool {
+		holder, occupied := w.cells[c]
+		return occupied && holder != b
+	})
+}
+
+// shortestRoute finds the shortest sequence of movement commands from `start` to `goal` which avoids occupied cells
+func shortestRoute(start cell, goal cell, occupied func(cell) bool) (cmdlang.Sequence, bool) {
+	if occupied(goal) {
+		return nil, false
+	}
+
+	// breadth first search over the grid; `via` records the command used to reach each visited cell
+	type step struct {
+		prev    cell
+		command cmdlang.Command
+	}
+	via := map[cell]step{start: {}}
+	queue := []cell{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == goal {
+			var path cmdlang.Sequence
+			for c := goal; c != start; c = via[c].prev {
+				path = append(cmdlang.Sequence{via[c].command}, path...)
+			}
+			return path, true
+		}
+		for _, command := range cmdlang.Sequence("NESW") {
+			rs, ok := move(RobotState{X: current.x, Y: current.y}, command)
+			next := cell{rs.X, rs.Y}
+			if _, visited := via[next]; !ok || visited || occupied(next) {
+				continue
+			}
+			via[next] = step{current, command}
+			queue = append(queue, next)
+		}
+	}
+	return nil, false
+}
+
+// nextLeg splits commands at the next `C` command; the leg is the movement commands up to it, rest the remaining commands
+func nextLeg(commands cmdlang.Sequence) (leg cmdlang.Sequence, rest cmdlang.Sequence) {
+	for i, command := range commands {
+		if command == cmdlang.Charge {
+			return commands[:i], commands[i:]
+		}
+	}
+	return commands, nil
+}
+
+// destination returns the state reached by performing all of the commands (ignoring other robots)
+func destination(state RobotState, commands cmdlang.Sequence) RobotState {
+	for _, command := range commands {
+		state, _ = move(state, command)
+	}
+	return state
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarehouseImplementsWarehouse(t *testing.T) {
+	_, ok := interface{}(NewRobotWarehouse(CollisionFail, 0)).(Warehouse)
+	if !ok {
+		t.Errorf("robot warehouse must satisfy the `Warehouse` interface")
+	}
+}
+
+func TestParseCollisionPolicy(t *testing.T) {
+	t.Run("test valid policy names", func(t *testing.T) {
+		for name, want := range map[string]CollisionPolicy{"fail": CollisionFail, "wait": CollisionWait, "replan": CollisionReplan} {
+			got, err := ParseCollisionPolicy(name)
+			if err != nil || got != want {
+				t.Errorf("policy `%s` should be parsed; got: %v, want: %v", name, got, want)
+			}
+		}
+	})
+
+	t.Run("test invalid policy name", func(t *testing.T) {
+		if _, err := ParseCollisionPolicy("crash"); err == nil {
+			t.Error("policy `crash` is invalid")
+		}
+	})
+}
+
+func TestAddRobot(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	if _, err := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB()); err != nil {
+		t.Fatalf("robot `r1` should be added at (0,0); %v", err)
+	}
+
+	t.Run("test fails to add robot with existing ID", func(t *testing.T) {
+		if _, err := warehouse.AddRobot("r1", 5, 5, 0, NewInMemoryDB()); err == nil {
+			t.Error("robot `r1` already exists")
+		}
+	})
+
+	t.Run("test fails to add robot on occupied cell", func(t *testing.T) {
+		if _, err := warehouse.AddRobot("r2", 0, 0, 0, NewInMemoryDB()); err == nil {
+			t.Error("robot `r2` should not be added at (0,0) occupied by `r1`")
+		}
+	})
+
+	t.Run("test fails to add robot outside of warehouse", func(t *testing.T) {
+		if _, err := warehouse.AddRobot("r2", 10, 0, 0, NewInMemoryDB()); err == nil {
+			t.Error("robot `r2` should not be added at (10,0)")
+		}
+	})
+
+	t.Run("test robots are listed", func(t *testing.T) {
+		if _, err := warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB()); err != nil {
+			t.Fatalf("robot `r2` should be added at (1,0); %v", err)
+		}
+		if got := len(warehouse.Robots()); got != 2 {
+			t.Errorf("warehouse should contain 2 robots; got: %d", got)
+		}
+	})
+}
+
+func TestTraverseCollisionFail(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	warehouse.AddRobot("r2", 1, 1, 0, NewInMemoryDB())
+
+	got, err := warehouse.traverse(context.Background(), r1, Task{command: "N E"})
+
+	var collision *CollisionError
+	if !errors.As(err, &collision) {
+		t.Fatalf("robot `r1` should collide with `r2`; got: %v", err)
+	}
+	if collision.RobotID != "r1" || collision.BlockingRobotID != "r2" || collision.X != 1 || collision.Y != 1 {
+		t.Errorf("collision should identify robot `r2` blocking `r1` at (1,1); got: %v", collision)
+	}
+	if want := (RobotState{0, 1, false, 0}); got != want || r1.CurrentState() != want {
+		t.Errorf("robot `r1` should stop before the collision; got: %v, want: %v", got, want)
+	}
+}
+
+func TestTraverseCollisionWait(t *testing.T) {
+	t.Run("test robot waits for occupied cell to be released", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionWait, time.Second)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		r2, _ := warehouse.AddRobot("r2", 0, 1, 0, NewInMemoryDB())
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			warehouse.traverse(context.Background(), r2, Task{command: "E"})
+		}()
+
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "N"})
+		if err != nil {
+			t.Fatalf("robot `r1` should move once `r2` releases (0,1); %v", err)
+		}
+		if want := (RobotState{0, 1, false, 0}); got != want {
+			t.Errorf("robot `r1` should move to (0,1); got: %v", got)
+		}
+	})
+
+	t.Run("test robot gives up after wait timeout", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionWait, 20*time.Millisecond)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 0, 1, 0, NewInMemoryDB())
+
+		_, err := warehouse.traverse(context.Background(), r1, Task{command: "N"})
+
+		var collision *CollisionError
+		if !errors.As(err, &collision) || collision.BlockingRobotID != "r2" {
+			t.Fatalf("robot `r1` should be blocked by `r2`; got: %v", err)
+		}
+		if collision.Waited < 20*time.Millisecond {
+			t.Errorf("robot `r1` should wait at least 20ms; waited: %s", collision.Waited)
+		}
+	})
+}
+
+func TestTraverseCollisionReplan(t *testing.T) {
+	t.Run("test robot routes around blocking robot", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionReplan, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "E E"})
+		if err != nil {
+			t.Fatalf("robot `r1` should route around `r2`; %v", err)
+		}
+		if want := (RobotState{2, 0, false, 0}); got != want {
+			t.Errorf("robot `r1` should reach (2,0); got: %v", got)
+		}
+	})
+
+	t.Run("test robot fails when destination is occupied", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionReplan, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+
+		_, err := warehouse.traverse(context.Background(), r1, Task{command: "E"})
+
+		var collision *CollisionError
+		if !errors.As(err, &collision) || collision.BlockingRobotID != "r2" {
+			t.Errorf("robot `r1` should be blocked by `r2`; got: %v", err)
+		}
+	})
+}
+
+// TestRobotCollisionSubscriptions provides an insight of how consumers of the `err` channel are notified of the blocking robot
+func TestRobotCollisionSubscriptions(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	warehouse.AddRobot("r2", 2, 0, 0, NewInMemoryDB())
+	go r1.listen(context.Background())
+
+	_, _, errCh := r1.EnqueueTask("E E")
+	got := <-errCh
+
+	want := `robot 'r1' blocked at (2, 0) by robot 'r2'`
+	if got.Error() != want {
+		t.Errorf("robot movement should have thrown error; got: \"%s\", want: \"%s\"", got, want)
+	}
+	if state := r1.CurrentState(); state != (RobotState{1, 0, false, 0}) {
+		t.Errorf("robot `r1` should stop at (1,0); got: %v", state)
+	}
+}
+
+func TestRobotPartialRouteEvents(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	warehouse.SetBattery(BatteryModel{Capacity: 3, MoveCost: 1})
+	r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go r1.listen(context.Background())
+	events, cancel := r1.events.subscribe()
+	defer cancel()
+
+	r1.EnqueueTask("E5")
+	var names []string
+	for event := range events {
+		names = append(names, event.Name)
+		if event.Name == EventState && event.State != (RobotState{3, 0, false, 0}) {
+			t.Errorf("state event should report the cell the robot stopped at; got: %v, want: (3,0)", event.State)
+		}
+		if event.Name == EventTask {
+			break
+		}
+	}
+	if got, want := strings.Join(names, " "), "robotstate roboterror task"; got != want {
+		t.Errorf("subscribers should learn the state reached by the failed task; got: %v, want: %v", got, want)
+	}
+}
+
+func TestParseDeadlockPolicy(t *testing.T) {
+	t.Run("test valid rule and resolution names", func(t *testing.T) {
+		if rule, err := ParseDeadlockRule("youngest"); err != nil || rule != DeadlockYoungestTask {
+			t.Errorf("rule `youngest` should be parsed; got: %v", rule)
+		}
+		if resolution, err := ParseDeadlockResolution("replan"); err != nil || resolution != DeadlockReplan {
+			t.Errorf("resolution `replan` should be parsed; got: %v", resolution)
+		}
+	})
+
+	t.Run("test invalid rule and resolution names", func(t *testing.T) {
+		if _, err := ParseDeadlockRule("oldest"); err == nil {
+			t.Error("rule `oldest` is invalid")
+		}
+		if _, err := ParseDeadlockResolution("retry"); err == nil {
+			t.Error("resolution `retry` is invalid")
+		}
+	})
+}
+
+// traverseAsync runs the task of the robot in a separate goroutine; the returned channel receives the task error
+func traverseAsync(warehouse *RobotWarehouse, b *Bot, task Task) chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := warehouse.traverse(context.Background(), b, task)
+		done <- err
+	}()
+	return done
+}
+
+func TestTraverseDeadlock(t *testing.T) {
+	t.Run("test lowest priority robot aborts its task", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionWait, 200*time.Millisecond)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 1, NewInMemoryDB())
+		r2, _ := warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+
+		r1Done := traverseAsync(warehouse, r1, Task{id: "t1", command: "E", created: time.Now()})
+		r2Done := traverseAsync(warehouse, r2, Task{id: "t2", command: "W N", created: time.Now()})
+
+		event := <-warehouse.Deadlocks
+		if event.VictimRobotID != "r2" || event.VictimTaskID != "t2" || len(event.RobotIDs) != 2 || len(event.TaskIDs) != 2 {
+			t.Errorf("deadlock event should select `r2` executing `t2`; got: %+v", event)
+		}
+
+		var deadlock *DeadlockError
+		if err := <-r2Done; !errors.As(err, &deadlock) || deadlock.TaskID != "t2" {
+			t.Errorf("robot `r2` should abort task `t2`; got: %v", err)
+		}
+
+		// `r2` remains in place, hence `r1` eventually times out
+		var collision *CollisionError
+		if err := <-r1Done; !errors.As(err, &collision) {
+			t.Errorf("robot `r1` should remain blocked by `r2`; got: %v", err)
+		}
+	})
+
+	t.Run("test youngest task robot replans its route", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionWait, time.Second)
+		warehouse.SetDeadlockPolicy(DeadlockYoungestTask, DeadlockReplan)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		r2, _ := warehouse.AddRobot("r2", 1, 0, 1, NewInMemoryDB())
+
+		r1Done := traverseAsync(warehouse, r1, Task{id: "t1", command: "E", created: time.Now()})
+		r2Done := traverseAsync(warehouse, r2, Task{id: "t2", command: "W N", created: time.Now().Add(time.Second)})
+
+		event := <-warehouse.Deadlocks
+		if event.VictimRobotID != "r2" || event.Resolution != DeadlockReplan {
+			t.Errorf("deadlock event should select `r2` to replan; got: %+v", event)
+		}
+
+		if err := <-r2Done; err != nil {
+			t.Errorf("robot `r2` should route around `r1`; %v", err)
+		}
+		if err := <-r1Done; err != nil {
+			t.Errorf("robot `r1` should move once `r2` moves away; %v", err)
+		}
+
+		if got, want := r1.CurrentState(), (RobotState{1, 0, false, 0}); got != want {
+			t.Errorf("robot `r1` should move to (1,0); got: %v", got)
+		}
+		if got, want := r2.CurrentState(), (RobotState{0, 1, false, 0}); got != want {
+			t.Errorf("robot `r2` should move to (0,1); got: %v", got)
+		}
+	})
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("test task in progress finishes and queued tasks are cancelled", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetCommandDuration(20 * time.Millisecond)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		go r1.listen(context.Background())
+
+		running, _, _ := r1.EnqueueTask("N N N")
+		queued := make(chan string)
+		go func() {
+			taskID, _, _ := r1.EnqueueTask("E")
+			queued <- taskID
+		}()
+		for len(r1.queuedTasks()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		events, _ := r1.events.subscribe()
+
+		if err := warehouse.Shutdown(context.Background()); err != nil {
+			t.Fatalf("robots should be shut down; got: %v", err)
+		}
+		var names []string
+		completed := false
+		for event := range events {
+			names = append(names, event.Name)
+			completed = completed || (event.Name == EventTask && event.Task.id == running)
+		}
+		if !completed || names[len(names)-1] != EventShutdown {
+			t.Errorf("subscribers should receive the completion of the task in progress before the shutdown event; got: %v", names)
+		}
+		if task, _ := r1.repository.GetTask(running); !task.success {
+			t.Errorf("task in progress should be finished; got: %+v", task)
+		}
+		if task, _ := r1.repository.GetTask(<-queued); !task.cancelled || task.executed {
+			t.Errorf("queued task should be cancelled; got: %+v", task)
+		}
+		if state := r1.CurrentState(); state != (RobotState{X: 0, Y: 3}) {
+			t.Errorf("robot should have moved by the task in progress; got: %v", state)
+		}
+		select {
+		case <-r1.stopped:
+		default:
+			t.Error("robot should have stopped listening")
+		}
+	})
+
+	t.Run("test task in progress is aborted after the timeout", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetCommandDuration(time.Second)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		go r1.listen(context.Background())
+
+		taskID, _, errs := r1.EnqueueTask("N N N")
+		// tasks are queued without waiting for the robot, so the shutdown must not cancel the task before it starts
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			r1.mu.Lock()
+			running := r1.running
+			r1.mu.Unlock()
+			if running == taskID {
+				break
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := warehouse.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("shutdown should time out; got: %v", err)
+		}
+		if err := <-errs; !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("task should be aborted; got: %v", err)
+		}
+		if task, _ := r1.repository.GetTask(taskID); task.success {
+			t.Errorf("aborted task should not succeed; got: %+v", task)
+		}
+	})
+
+	t.Run("test new tasks and robots are rejected", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		go r1.listen(context.Background())
+		warehouse.Shutdown(context.Background())
+
+		if _, _, errs := r1.EnqueueTask("N"); !errors.Is(<-errs, ErrShuttingDown) {
+			t.Error("task should be rejected")
+		}
+		if _, err := warehouse.AddRobot("r2", 5, 5, 0, NewInMemoryDB()); !errors.Is(err, ErrShuttingDown) {
+			t.Errorf("robot should be rejected; got: %v", err)
+		}
+	})
+}
+
+func TestTraverseBattery(t *testing.T) {
+	newWarehouse := func() *RobotWarehouse {
+		warehouse := NewRobotWarehouse(CollisionFail, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 5, MoveCost: 1})
+		warehouse.AddChargingStation(0, 2)
+		return warehouse
+	}
+
+	t.Run("test robots are added fully charged", func(t *testing.T) {
+		r1, _ := newWarehouse().AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		if got := r1.CurrentState().Battery; got != 5 {
+			t.Errorf("robot `r1` should be fully charged; got: %d, want: %d", got, 5)
+		}
+	})
+
+	t.Run("test moves drain the battery", func(t *testing.T) {
+		warehouse := newWarehouse()
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "N E"})
+		if err != nil {
+			t.Fatalf("robot `r1` should move to (1,1); %v", err)
+		}
+		if want := (RobotState{1, 1, false, 3}); got != want || r1.CurrentState() != want {
+			t.Errorf("each move should drain 1; got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("test task is aborted once the battery is depleted", func(t *testing.T) {
+		warehouse := newWarehouse()
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "E7"})
+		if !errors.Is(err, ErrBatteryDepleted) {
+			t.Fatalf("task should be aborted by a depleted battery; got: %v", err)
+		}
+		if want := (RobotState{5, 0, false, 0}); got != want || r1.CurrentState() != want {
+			t.Errorf("robot `r1` should stop once depleted; got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("test robot charges at charging station", func(t *testing.T) {
+		warehouse := newWarehouse()
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "N N C E5"})
+		if err != nil {
+			t.Fatalf("robot `r1` should charge at (0,2); %v", err)
+		}
+		if want := (RobotState{5, 2, false, 0}); got != want {
+			t.Errorf("robot `r1` should move on charged; got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("test charging fails off charging station", func(t *testing.T) {
+		warehouse := newWarehouse()
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "N C"})
+		if !errors.Is(err, ErrNoChargingStation) {
+			t.Fatalf("robot `r1` is not on a charging station; got: %v", err)
+		}
+		if want := (RobotState{0, 1, false, 4}); got != want {
+			t.Errorf("robot `r1` should stop at (0,1); got: %v, want: %v", got, want)
+		}
+	})
+
+	t.Run("test replanned route keeps the charge at the charging station", func(t *testing.T) {
+		warehouse := NewRobotWarehouse(CollisionReplan, 0)
+		warehouse.SetBattery(BatteryModel{Capacity: 10, MoveCost: 1})
+		warehouse.AddChargingStation(2, 0)
+		r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+		warehouse.AddRobot("r2", 1, 0, 0, NewInMemoryDB())
+
+		got, err := warehouse.traverse(context.Background(), r1, Task{command: "E E C N"})
+		if err != nil {
+			t.Fatalf("robot `r1` should route around `r2`; %v", err)
+		}
+		if want := (RobotState{2, 1, false, 9}); got != want {
+			t.Errorf("robot `r1` should charge at (2,0) before moving north; got: %v, want: %v", got, want)
+		}
+	})
+}
+
+func TestChargingStations(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	if err := warehouse.AddChargingStation(10, 0); !errors.Is(err, ErrOutOfBounds) {
+		t.Errorf("charging station at (10,0) should be rejected; got: %v", err)
+	}
+	warehouse.AddChargingStation(3, 1)
+	warehouse.AddChargingStation(0, 0)
+	if got := warehouse.ChargingStations(); len(got) != 2 || got[0] != (RobotState{X: 0, Y: 0}) || got[1] != (RobotState{X: 3, Y: 1}) {
+		t.Errorf("incorrect charging stations; got: %v", got)
+	}
+
+	t.Run("test charging stations are replaced", func(t *testing.T) {
+		if err := warehouse.SetChargingStations([]RobotState{{X: 5, Y: 5}, {X: 10, Y: 0}}); !errors.Is(err, ErrOutOfBounds) {
+			t.Errorf("charging station at (10,0) should be rejected; got: %v", err)
+		}
+		if got := warehouse.ChargingStations(); len(got) != 2 {
+			t.Errorf("charging stations should not be changed by an invalid layout; got: %v", got)
+		}
+		if err := warehouse.SetChargingStations([]RobotState{{X: 5, Y: 5}}); err != nil {
+			t.Fatalf("charging stations should be replaced; got: %v", err)
+		}
+		if got := warehouse.ChargingStations(); len(got) != 1 || got[0] != (RobotState{X: 5, Y: 5}) {
+			t.Errorf("incorrect charging stations; got: %v", got)
+		}
+	})
+}
+
+func TestRemoveRobot(t *testing.T) {
+	warehouse := NewRobotWarehouse(CollisionFail, 0)
+	warehouse.SetCommandDuration(20 * time.Millisecond)
+	r1, _ := warehouse.AddRobot("r1", 0, 0, 0, NewInMemoryDB())
+	go r1.listen(context.Background())
+
+	t.Run("test fails to remove unknown robot", func(t *testing.T) {
+		if err := warehouse.RemoveRobot(context.Background(), "r9"); err == nil {
+			t.Error("robot `r9` does not exist")
+		}
+	})
+
+	t.Run("test task in progress finishes and the cell is released", func(t *testing.T) {
+		running, _, _ := r1.EnqueueTask("N")
+		for started := false; !started; time.Sleep(time.Millisecond) {
+			r1.mu.Lock()
+			started = r1.running == running
+			r1.mu.Unlock()
+		}
+		if err := warehouse.RemoveRobot(context.Background(), "r1"); err != nil {
+			t.Fatalf("robot `r1` should be removed; got: %v", err)
+		}
+		if task, _ := r1.repository.GetTask(running); !task.success {
+			t.Errorf("task in progress should be finished; got: %+v", task)
+		}
+		if _, err := warehouse.Robot("r1"); err == nil {
+			t.Error("robot `r1` should no longer be in the warehouse")
+		}
+		if _, err := warehouse.AddRobot("r2", 0, 1, 0, NewInMemoryDB()); err != nil {
+			t.Errorf("robot `r2` should be added at (0,1) released by `r1`; %v", err)
+		}
+	})
+}
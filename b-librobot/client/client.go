@@ -0,0 +1,229 @@
+// Package client is a client of the RESTful API of the robot server (a-restful).
+//
+// The client implements the librobot interfaces over HTTP, so code written against the simulator runs unchanged against a
+// remote server: the Client is a librobot.Warehouse and its robots are librobot.Robots. The outcome of tasks is reported
+// on the channels returned by EnqueueTask, which are fed by the server-sent events of the robot.
+//
+//	c, err := client.New("http://localhost:8000", client.WithAPIKey("operator-key"))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer c.Close()
+//
+//	_, position, errs := c.Robot("r1").EnqueueTask("N E N E")
+//	select {
+//	case state := <-position:
+//		fmt.Printf("robot moved to (%d, %d)\n", state.X, state.Y)
+//	case err := <-errs:
+//		fmt.Println(err)
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// DefaultTimeout is the timeout of requests (other than event streams) unless the client is configured with an HTTP client.
+const DefaultTimeout = 10 * time.Second
+
+// Client is a client of a robot server; it provides access to the robots of the warehouse of the server.
+type Client struct {
+	baseURL       *url.URL
+	http          *http.Client
+	stream        *http.Client // without timeout, as event streams are long-lived
+	authorization func(*http.Request)
+
+	mu     sync.Mutex
+	robots map[string]*Robot
+	closed bool
+}
+
+// Option configures optional behaviour of the Client.
+type Option func(*Client)
+
+// WithHTTPClient performs requests with an HTTP client, e.g. to configure TLS or timeouts.
+// Event streams are opened with the same client; its timeout must therefore not bound the duration of responses.
+func WithHTTPClient(c *http.Client) Option {
+	return func(client *Client) {
+		client.http = c
+		client.stream = c
+	}
+}
+
+// WithAPIKey authenticates requests with a static API key (`X-API-Key` header).
+func WithAPIKey(key string) Option {
+	return func(client *Client) {
+		client.authorization = func(r *http.Request) { r.Header.Set("X-API-Key", key) }
+	}
+}
+
+// WithBearerToken authenticates requests with a bearer token (JWT).
+func WithBearerToken(token string) Option {
+	return func(client *Client) {
+		client.authorization = func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+}
+
+// New creates a client of the robot server at a URL, e.g. `http://localhost:8000`.
+// No request is performed until the robots are used.
+func New(serverURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid server URL '%s': scheme must be http or https", serverURL)
+	}
+
+	c := &Client{
+		baseURL:       u,
+		http:          &http.Client{Timeout: DefaultTimeout},
+		stream:        &http.Client{},
+		authorization: func(*http.Request) {},
+		robots:        make(map[string]*Robot),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// Robot returns the robot of the warehouse identified by ID; the empty ID is the robot served at `/api/v1/state`.
+// The robot is not looked up, so requests of a robot which does not exist fail with the `robot-not-found` problem.
+func (c *Client) Robot(id string) *Robot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	robot, ok := c.robots[id]
+	if !ok {
+		robot = &Robot{client: c, id: id, pending: make(map[string]pendingTask), watchers: make(map[chan librobot.RobotState]struct{})}
+		c.robots[id] = robot
+	}
+	return robot
+}
+
+// Robots returns the robots of the warehouse; nil if the robots cannot be listed (see ListRobots).
+// * implements librobot.Warehouse
+func (c *Client) Robots() []librobot.Robot {
+	robots, err := c.ListRobots(context.Background())
+	if err != nil {
+		return nil
+	}
+	res := make([]librobot.Robot, len(robots))
+	for i, robot := range robots {
+		res[i] = robot
+	}
+	return res
+}
+
+// ListRobots lists the robots of the warehouse.
+func (c *Client) ListRobots(ctx context.Context) ([]*Robot, error) {
+	var body struct {
+		Robots []struct {
+			ID string `json:"id"`
+		} `json:"robots"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/robots", nil, &body); err != nil {
+		return nil, err
+	}
+	robots := make([]*Robot, len(body.Robots))
+	for i, r := range body.Robots {
+		robots[i] = c.Robot(r.ID)
+	}
+	return robots, nil
+}
+
+// Task gets the execution status of a task of any robot of the warehouse.
+func (c *Client) Task(ctx context.Context, taskID string) (Task, error) {
+	var body struct {
+		Task Task `json:"task"`
+	}
+	err := c.do(ctx, http.MethodGet, "/api/v1/task/"+url.PathEscape(taskID), nil, &body)
+	return body.Task, err
+}
+
+// Close closes the event streams of the robots; the pending tasks of the robots fail with ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	robots := make([]*Robot, 0, len(c.robots))
+	for _, robot := range c.robots {
+		robots = append(robots, robot)
+	}
+	c.mu.Unlock()
+
+	for _, robot := range robots {
+		robot.closeStream()
+	}
+	return nil
+}
+
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// request creates an authenticated request of a path of the server.
+func (c *Client) request(ctx context.Context, method string, path string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL.String()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorization(req)
+	return req, nil
+}
+
+// do performs a request, decoding the JSON response body into res; problem details responses are returned as *Error.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, res interface{}) error {
+	req, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return fmt.Errorf("%s %s: invalid response body: %w", method, path, err)
+	}
+	return nil
+}
+
+// responseError returns the error of an unsuccessful response; responses without problem details result in an `unexpected-response` problem.
+func responseError(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	problem := Problem{Code: CodeUnexpectedResponse, Title: resp.Status, Status: resp.StatusCode, Detail: strings.TrimSpace(string(data))}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		json.Unmarshal(data, &problem)
+	}
+	return &Error{Problem: problem}
+}
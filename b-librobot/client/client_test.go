@@ -0,0 +1,503 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+var (
+	_ librobot.Warehouse = (*Client)(nil)
+	_ librobot.Robot     = (*Robot)(nil)
+)
+
+// fakeServer emulates the endpoints of a single robot (`r1`) of the server; tasks are executed once released
+type fakeServer struct {
+	t       *testing.T
+	mu      sync.Mutex
+	x, y    uint
+	battery uint            // drained by each move; not reported if 0
+	queued  func(id string) // called once a task is queued, before the request is responded to
+	next    int
+	events  chan string // an empty event closes the event stream, like the server closes the streams falling behind
+	tasks   chan task
+	missed  map[string]bool // tasks executed while the event stream was closed, by whether they succeeded
+}
+
+type task struct {
+	id       string
+	commands string
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *httptest.Server) {
+	f := &fakeServer{t: t, events: make(chan string, 16), tasks: make(chan task, 16), missed: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/robots", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"robots":[{"id":"r1"}]}`)
+	})
+	mux.HandleFunc("/api/v1/robots/r1/state", f.state)
+	mux.HandleFunc("/api/v1/robots/r1/state/subscribe", f.subscribe)
+	mux.HandleFunc("/api/v1/robots/r2/state", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"about:blank","code":"robot-not-found","title":"Not Found","status":404,"detail":"robot 'r2' not found"}`)
+	})
+	mux.HandleFunc("/api/v1/task/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/task/")
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if success, ok := f.missed[id]; ok {
+			fmt.Fprintf(w, `{"task":{"id":"%s","command":"N","executed":true,"cancelled":false,"success":%t}}`, id, success)
+			return
+		}
+		fmt.Fprintf(w, `{"task":{"id":"%s","command":"N","executed":false,"cancelled":true,"success":false}}`, id)
+	})
+	return f, httptest.NewServer(mux)
+}
+
+func (f *fakeServer) state(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Method == http.MethodGet {
+		fmt.Fprint(w, f.stateJSON())
+		return
+	}
+	if r.Header.Get("X-API-Key") != "key" {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"type":"about:blank","code":"unauthenticated","title":"Unauthorized","status":401,"detail":"missing credentials"}`)
+		return
+	}
+	var body struct {
+		Commands string `json:"commands"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if _, err := cmdlang.Validate(body.Commands); err != nil {
+		e := err.(*cmdlang.Error)
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"type":"about:blank","code":"invalid-command-sequence","title":"Bad Request","status":400,"detail":%q,"reason":%q,"token":%q,"offset":%d,"line":%d,"column":%d}`,
+			e.Error(), e.Reason, e.Token, e.Pos.Offset, e.Pos.Line, e.Pos.Column)
+		return
+	}
+	f.next++
+	id := fmt.Sprintf("task-%d", f.next)
+	f.tasks <- task{id, body.Commands}
+	if f.queued != nil {
+		f.queued(id)
+	}
+	fmt.Fprintf(w, `{"taskID":"%s","position":0}`, id)
+}
+
+// execute executes the next queued task: tasks of `N` commands move the robot north, other tasks fail
+func (f *fakeServer) execute() {
+	t := <-f.tasks
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if strings.Trim(t.commands, "N ") != "" {
+		f.events <- fmt.Sprintf("event: roboterror\ndata: {\"error\":\"out of bounds\"}\n\nevent: task\ndata: {\"task\":{\"id\":\"%s\"},\"problem\":{\"code\":\"out-of-bounds\",\"title\":\"Out of Bounds\",\"detail\":\"robot would move out of the warehouse\"}}\n\n", t.id)
+		return
+	}
+	moves := uint(strings.Count(t.commands, "N"))
+	f.y += moves
+	if f.battery > 0 {
+		f.battery -= moves
+	}
+	f.events <- fmt.Sprintf("event: robotstate\ndata: %s\n\nevent: task\ndata: {\"task\":{\"id\":\"%s\",\"success\":true}}\n\n", f.stateJSON(), t.id)
+}
+
+// fallBehind closes the event stream, and executes the next queued task while it is closed; its events are missed
+func (f *fakeServer) fallBehind() {
+	f.events <- ""
+	t := <-f.tasks
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.missed[t.id] = strings.Trim(t.commands, "N ") == ""
+	if f.missed[t.id] {
+		f.y += uint(strings.Count(t.commands, "N"))
+	}
+}
+
+// stateJSON returns the state of the robot as sent by the server; f.mu must be held
+func (f *fakeServer) stateJSON() string {
+	if f.battery == 0 {
+		return fmt.Sprintf(`{"x":%d,"y":%d}`, f.x, f.y)
+	}
+	return fmt.Sprintf(`{"x":%d,"y":%d,"battery":%d}`, f.x, f.y, f.battery)
+}
+
+func (f *fakeServer) subscribe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case event := <-f.events:
+			if event == "" {
+				return
+			}
+			fmt.Fprint(w, event)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("test invalid scheme", func(t *testing.T) {
+		if _, err := New("ftp://localhost"); err == nil {
+			t.Errorf("unexpected success; got: %v, want: error", err)
+		}
+	})
+
+	t.Run("test valid URL", func(t *testing.T) {
+		if _, err := New("http://localhost:8000/"); err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+	})
+}
+
+func TestRobots(t *testing.T) {
+	_, server := newFakeServer(t)
+	defer server.Close()
+	c, _ := New(server.URL)
+	defer c.Close()
+
+	robots := c.Robots()
+	if len(robots) != 1 || robots[0].(*Robot).ID() != "r1" {
+		t.Errorf("unexpected robots; got: %v, want: [r1]", robots)
+	}
+	if robots[0] != c.Robot("r1") {
+		t.Errorf("robots are not cached")
+	}
+}
+
+func TestEnqueueTask(t *testing.T) {
+	t.Run("test position of successful task", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		taskID, position, errs := c.Robot("r1").EnqueueTask("N N")
+		if taskID != "task-1" {
+			t.Errorf("unexpected task ID; got: %v, want: %v", taskID, "task-1")
+		}
+		f.execute()
+
+		select {
+		case state := <-position:
+			if want := (librobot.RobotState{X: 0, Y: 2}); state != want {
+				t.Errorf("unexpected position; got: %v, want: %v", state, want)
+			}
+		case err := <-errs:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+	})
+
+	t.Run("test battery of successful task", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		f.battery = 10
+		_, position, errs := c.Robot("r1").EnqueueTask("N N")
+		f.execute()
+
+		select {
+		case state := <-position:
+			if want := (librobot.RobotState{X: 0, Y: 2, Battery: 8}); state != want {
+				t.Errorf("unexpected position; got: %v, want: %v", state, want)
+			}
+		case err := <-errs:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+	})
+
+	t.Run("test problem of failed task", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		robot := c.Robot("r1")
+		_, position1, errs1 := robot.EnqueueTask("S")
+		_, position2, errs2 := robot.EnqueueTask("N")
+		f.execute()
+		f.execute()
+
+		select {
+		case err := <-errs1:
+			if Code(err) != CodeOutOfBounds {
+				t.Errorf("unexpected error code; got: %v, want: %v", Code(err), CodeOutOfBounds)
+			}
+		case state := <-position1:
+			t.Errorf("unexpected position; got: %v, want: error", state)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+		select {
+		case state := <-position2:
+			if want := (librobot.RobotState{X: 0, Y: 1}); state != want {
+				t.Errorf("unexpected position; got: %v, want: %v", state, want)
+			}
+		case err := <-errs2:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+	})
+
+	t.Run("test rejected task", func(t *testing.T) {
+		_, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL)
+		defer c.Close()
+
+		taskID, _, errs := c.Robot("r1").EnqueueTask("N")
+		if taskID != "" {
+			t.Errorf("unexpected task ID; got: %v, want: empty", taskID)
+		}
+		if err := <-errs; Code(err) != CodeUnauthenticated {
+			t.Errorf("unexpected error code; got: %v, want: %v", Code(err), CodeUnauthenticated)
+		}
+	})
+
+	t.Run("test invalid command sequence is located", func(t *testing.T) {
+		_, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		_, _, errs := c.Robot("r1").EnqueueTask("N E NE S")
+		err := <-errs
+		var e *Error
+		if !errors.As(err, &e) || e.Problem.Reason != cmdlang.MultiLetterCommand || e.Problem.Token != "NE" || e.Problem.Column != 5 {
+			t.Fatalf("unexpected problem; got: %+v, want: multi-letter-command 'NE' at column 5", e)
+		}
+		var cmdErr *cmdlang.Error
+		if !errors.As(err, &cmdErr) {
+			t.Fatalf("error should unwrap to the command language error; got: %v", err)
+		}
+		if got, want := cmdErr.Underline("N E NE S"), "N E NE S\n    ^^"; got != want {
+			t.Errorf("unexpected underline; got: %q, want: %q", got, want)
+		}
+	})
+
+	t.Run("test event stream falling behind is resynced", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+		robot := c.Robot("r1")
+
+		_, position, errs := robot.EnqueueTask("N")
+		_, _, failed := robot.EnqueueTask("E")
+		f.fallBehind()
+		select {
+		case state := <-position:
+			if state != (librobot.RobotState{X: 0, Y: 1}) {
+				t.Errorf("unexpected state; got: %v, want: (0, 1)", state)
+			}
+		case err := <-errs:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the polled outcome of the task")
+		}
+
+		f.execute()
+		select {
+		case err := <-failed:
+			if Code(err) != CodeOutOfBounds {
+				t.Errorf("unexpected error code; got: %v, want: %v", Code(err), CodeOutOfBounds)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task from the re-opened stream")
+		}
+	})
+
+	t.Run("test pending task of closed client", func(t *testing.T) {
+		_, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+
+		_, _, errs := c.Robot("r1").EnqueueTask("N")
+		c.Close()
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), ErrClosed.Error()) {
+				t.Errorf("unexpected error; got: %v, want: %v", err, ErrClosed)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+
+		if _, _, errs := c.Robot("r1").EnqueueTask("N"); <-errs == nil {
+			t.Errorf("unexpected success of task of closed client")
+		}
+	})
+
+	t.Run("test events dispatched while task is queued", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		states, err := c.Robot("r1").Watch(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+		f.queued = func(string) {
+			f.events <- "event: robotstate\ndata: {\"x\":2,\"y\":0}\n\n"
+			select {
+			case <-states:
+			case <-time.After(time.Second):
+				t.Errorf("timed out waiting for the state while the task is queued")
+			}
+		}
+		if _, _, errs := c.Robot("r1").EnqueueTask("N"); len(errs) > 0 {
+			t.Errorf("unexpected error; got: %v, want: nil", <-errs)
+		}
+	})
+
+	t.Run("test outcome received before task is queued", func(t *testing.T) {
+		f, server := newFakeServer(t)
+		defer server.Close()
+		c, _ := New(server.URL, WithAPIKey("key"))
+		defer c.Close()
+
+		robot := c.Robot("r1")
+		f.queued = func(id string) {
+			f.events <- fmt.Sprintf("event: robotstate\ndata: {\"x\":0,\"y\":1}\n\nevent: task\ndata: {\"task\":{\"id\":\"%s\",\"success\":true}}\n\n", id)
+			for unclaimed := false; !unclaimed; time.Sleep(time.Millisecond) {
+				robot.mu.Lock()
+				_, unclaimed = robot.unclaimed[id]
+				robot.mu.Unlock()
+			}
+		}
+		_, position, errs := robot.EnqueueTask("N")
+
+		select {
+		case state := <-position:
+			if want := (librobot.RobotState{X: 0, Y: 1}); state != want {
+				t.Errorf("unexpected position; got: %v, want: %v", state, want)
+			}
+		case err := <-errs:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the outcome of the task")
+		}
+		robot.mu.Lock()
+		defer robot.mu.Unlock()
+		if len(robot.unclaimed) > 0 || robot.enqueuing > 0 {
+			t.Errorf("unclaimed outcomes are kept; got: %v, want: none", robot.unclaimed)
+		}
+	})
+}
+
+func TestCancelTask(t *testing.T) {
+	_, server := newFakeServer(t)
+	defer server.Close()
+	c, _ := New(server.URL, WithAPIKey("key"))
+	defer c.Close()
+
+	robot := c.Robot("r1")
+	taskID, _, _ := robot.EnqueueTask("N")
+	if err := robot.CancelTask(taskID); err != nil {
+		t.Errorf("unexpected error; got: %v, want: nil", err)
+	}
+	robot.mu.Lock()
+	defer robot.mu.Unlock()
+	if _, ok := robot.pending[taskID]; ok {
+		t.Errorf("cancelled task is pending")
+	}
+}
+
+func TestCurrentState(t *testing.T) {
+	f, server := newFakeServer(t)
+	defer server.Close()
+	c, _ := New(server.URL)
+	defer c.Close()
+
+	f.x, f.y = 3, 4
+	if state, want := c.Robot("r1").CurrentState(), (librobot.RobotState{X: 3, Y: 4}); state != want {
+		t.Errorf("unexpected state; got: %v, want: %v", state, want)
+	}
+	f.battery = 7
+	if state, want := c.Robot("r1").CurrentState(), (librobot.RobotState{X: 3, Y: 4, Battery: 7}); state != want {
+		t.Errorf("unexpected state; got: %v, want: %v", state, want)
+	}
+
+	_, err := c.Robot("r2").State(context.Background())
+	if Code(err) != CodeRobotNotFound {
+		t.Errorf("unexpected error code; got: %v, want: %v", Code(err), CodeRobotNotFound)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	f, server := newFakeServer(t)
+	defer server.Close()
+	c, _ := New(server.URL, WithAPIKey("key"))
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	states, err := c.Robot("r1").Watch(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+
+	t.Run("test state changed by task", func(t *testing.T) {
+		c.Robot("r1").EnqueueTask("N")
+		f.execute()
+		select {
+		case state := <-states:
+			if want := (librobot.RobotState{X: 0, Y: 1}); state != want {
+				t.Errorf("unexpected state; got: %v, want: %v", state, want)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the state")
+		}
+	})
+
+	t.Run("test channel closed once context is done", func(t *testing.T) {
+		cancel()
+		select {
+		case _, ok := <-states:
+			if ok {
+				t.Errorf("unexpected state after context is done")
+			}
+		case <-time.After(time.Second):
+			t.Errorf("timed out waiting for the channel to close")
+		}
+	})
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := "event: a\ndata: 1\ndata: 2\n\n: comment\ndata: 3\n\nevent: empty\n\n"
+	var got []string
+	err := readEvents(strings.NewReader(stream), func(event string, data []byte) {
+		got = append(got, event+"="+string(data))
+	})
+	if err != nil {
+		t.Errorf("unexpected error; got: %v, want: nil", err)
+	}
+	if want := []string{"a=1\n2", "message=3"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("unexpected events; got: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+)
+
+// Problem codes reported by the server (see the problem details of the RESTful API); the codes below are commonly handled.
+const (
+	CodeInvalidCommandSequence = "invalid-command-sequence"
+	CodeOutOfBounds            = "out-of-bounds"
+	CodeTaskNotFound           = "task-not-found"
+	CodeTaskRunning            = "task-running"
+	CodeTaskFinished           = "task-finished"
+	CodeRobotNotFound          = "robot-not-found"
+	CodeCollision              = "collision"
+	CodeDeadlock               = "deadlock"
+	CodeBatteryDepleted        = "battery-depleted"
+	CodeNoChargingStation      = "no-charging-station"
+	CodeTaskFailed             = "task-failed"
+	CodeQueueFull              = "queue-full"
+	CodeShuttingDown           = "shutting-down"
+	CodeUnauthenticated        = "unauthenticated"
+	CodeForbidden              = "forbidden"
+
+	// CodeUnexpectedResponse is the code of responses which are not problem details, e.g. of a proxy in front of the server.
+	CodeUnexpectedResponse = "unexpected-response"
+)
+
+// ErrClosed is the error of tasks pending when the client is closed.
+var ErrClosed = errors.New("client is closed")
+
+// Problem is an RFC 7807 problem details body; the error envelope of the server.
+// Status is zero for errors which are not responses, e.g. errors of tasks reported by events.
+// Problems of invalid command sequences (`invalid-command-sequence`) additionally locate the offending token.
+type Problem struct {
+	Type     string `json:"type"`
+	Code     string `json:"code"`
+	Title    string `json:"title"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+
+	Reason cmdlang.Reason `json:"reason,omitempty"` // Reason the command sequence is invalid, e.g. `multi-letter-command`.
+	Token  string         `json:"token,omitempty"`  // Offending token; empty at the end of the command sequence.
+	Offset int            `json:"offset,omitempty"` // Byte offset of the token.
+	Line   int            `json:"line,omitempty"`   // Line of the token, starting at 1.
+	Column int            `json:"column,omitempty"` // Column of the token (in characters), starting at 1.
+}
+
+// Error is an error reported by the server: a problem details response or the problem of a failed task.
+type Error struct {
+	Problem Problem
+	TaskID  string // task which failed, if the error is the error of a task
+}
+
+func (e *Error) Error() string {
+	if e.TaskID != "" {
+		return fmt.Sprintf("task %s failed: %s (%s)", e.TaskID, e.Problem.Detail, e.Problem.Code)
+	}
+	if e.Problem.Status != 0 {
+		return fmt.Sprintf("%d %s: %s (%s)", e.Problem.Status, e.Problem.Title, e.Problem.Detail, e.Problem.Code)
+	}
+	return fmt.Sprintf("%s: %s (%s)", e.Problem.Title, e.Problem.Detail, e.Problem.Code)
+}
+
+// Code returns the problem code of the error, e.g. `out-of-bounds`.
+func (e *Error) Code() string {
+	return e.Problem.Code
+}
+
+// Unwrap returns the command language error located by the problem of an invalid command sequence, so its offending
+// token can be underlined (see cmdlang.Error.Underline); nil for other problems.
+func (e *Error) Unwrap() error {
+	if e.Problem.Reason == "" {
+		return nil
+	}
+	pos := cmdlang.Position{Offset: e.Problem.Offset, Line: e.Problem.Line, Column: e.Problem.Column}
+	return &cmdlang.Error{
+		Reason:  e.Problem.Reason,
+		Token:   e.Problem.Token,
+		Pos:     pos,
+		Message: strings.TrimPrefix(e.Problem.Detail, pos.String()+": "),
+	}
+}
+
+// Code returns the problem code of an error reported by the server; the empty string for other errors (e.g. network errors).
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Problem.Code
+	}
+	return ""
+}
+
+// Task is the execution status of a task.
+type Task struct {
+	ID        string `json:"id"`
+	Command   string `json:"command"`
+	Executed  bool   `json:"executed"`
+	Cancelled bool   `json:"cancelled"`
+	Success   bool   `json:"success"`
+	CreatedBy string `json:"createdBy,omitempty"`
+}
@@ -0,0 +1,520 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// Robot is a robot of the warehouse of a server.
+// The outcome of the tasks queued by EnqueueTask is received from the event stream of the robot, which is opened by the
+// first task and kept open until the client is closed (or the server shuts down; the next task then re-opens the stream).
+// The server closes the streams of clients falling behind its events; the stream is then re-opened right away, and the
+// status of the pending tasks is polled, as their outcome may have been missed.
+// * implements librobot.Robot
+type Robot struct {
+	client *Client
+	id     string
+
+	mu        sync.Mutex
+	cancel    context.CancelFunc                    // closes the event stream; nil while the robot is not subscribed to
+	opening   chan struct{}                         // closed once the event stream being opened is open (or failed)
+	done      chan struct{}                         // closed once the event stream is closed
+	state     librobot.RobotState                   // last state received from the event stream
+	pending   map[string]pendingTask                // tasks queued by the client which have not been executed yet
+	watchers  map[chan librobot.RobotState]struct{} // channels of Watch
+	enqueuing int                                   // requests queueing a task which have not been responded to yet
+	unclaimed map[string]outcome                    // outcomes of unknown tasks received while enqueuing; may be of those tasks
+	resyncs   int                                   // number of times the event stream has been re-opened after falling behind
+}
+
+// pendingTask are the channels the outcome of a task is sent on.
+type pendingTask struct {
+	position chan librobot.RobotState
+	err      chan error
+}
+
+// outcome is the outcome of an executed task; err is nil if the task succeeded.
+type outcome struct {
+	state librobot.RobotState
+	err   error
+}
+
+// send sends the outcome on the channels of the task.
+func (o outcome) send(task pendingTask) {
+	if o.err != nil {
+		task.err <- o.err
+		return
+	}
+	task.position <- o.state
+}
+
+// ID returns the identifier of the robot within the warehouse.
+func (r *Robot) ID() string {
+	return r.id
+}
+
+// path returns the path of the state (suffix empty) or a sub-resource of the state of the robot.
+func (r *Robot) path(suffix string) string {
+	if r.id == "" {
+		return "/api/v1/state" + suffix
+	}
+	return "/api/v1/robots/" + url.PathEscape(r.id) + "/state" + suffix
+}
+
+// EnqueueTask queues a task moving the robot by a sequence of commands.
+// Once the task is executed, either the state of the robot is sent on the position channel, or the error of the task
+// (an *Error, e.g. of the `out-of-bounds` problem) is sent on the err channel. Tasks rejected by the server (and tasks
+// pending when the event stream closes) report their error on the err channel, too. Both channels are buffered.
+// * implements librobot.Robot
+func (r *Robot) EnqueueTask(commands string) (taskID string, position chan librobot.RobotState, err chan error) {
+	return r.EnqueueTaskContext(context.Background(), commands)
+}
+
+// EnqueueTaskContext queues a task like EnqueueTask; the context bounds the request queueing the task, not the task itself.
+func (r *Robot) EnqueueTaskContext(ctx context.Context, commands string) (taskID string, position chan librobot.RobotState, err chan error) {
+	position = make(chan librobot.RobotState, 1)
+	err = make(chan error, 1)
+
+	done, subscribeErr := r.subscribe()
+	if subscribeErr != nil {
+		err <- fmt.Errorf("failed to subscribe to robot '%s': %w", r.id, subscribeErr)
+		return "", position, err
+	}
+
+	// r.mu is not held while the task is queued, so the outcome of a task executed before it is pending is kept as
+	// unclaimed until the request is responded to
+	r.mu.Lock()
+	r.enqueuing++
+	resyncs := r.resyncs
+	r.mu.Unlock()
+	var body struct {
+		TaskID string `json:"taskID"`
+	}
+	doErr := r.client.do(ctx, http.MethodPut, r.path(""), map[string]string{"commands": commands}, &body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task := pendingTask{position, err}
+	executed, ok := r.unclaimed[body.TaskID]
+	if r.enqueuing--; r.enqueuing == 0 {
+		r.unclaimed = nil
+	}
+	switch {
+	case doErr != nil:
+		err <- doErr
+		return "", position, err
+	case ok:
+		delete(r.unclaimed, body.TaskID)
+		executed.send(task)
+	case isDone(done):
+		err <- fmt.Errorf("task %s of robot '%s': %w", body.TaskID, r.id, r.streamError())
+	default:
+		r.pending[body.TaskID] = task
+		if r.resyncs != resyncs {
+			// the task may have been executed while the event stream was re-opened
+			go r.poll(body.TaskID)
+		}
+	}
+	return body.TaskID, position, err
+}
+
+// CancelTask cancels a queued task; cancelling a cancelled task succeeds.
+// Tasks being executed (`task-running`) or executed (`task-finished`) cannot be cancelled.
+// * implements librobot.Robot
+func (r *Robot) CancelTask(taskID string) error {
+	var body struct {
+		Task Task `json:"task"`
+	}
+	if err := r.client.do(context.Background(), http.MethodDelete, "/api/v1/task/"+url.PathEscape(taskID), nil, &body); err != nil {
+		return err
+	}
+
+	// cancelled tasks are not executed, so no outcome will be received
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, taskID)
+	return nil
+}
+
+// CurrentState gets the current state of the robot; the last state received from the event stream if the request fails.
+// * implements librobot.Robot
+func (r *Robot) CurrentState() librobot.RobotState {
+	state, err := r.State(context.Background())
+	if err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.state
+	}
+	return state
+}
+
+// State gets the current state of the robot.
+func (r *Robot) State(ctx context.Context) (librobot.RobotState, error) {
+	var body stateBody
+	if err := r.client.do(ctx, http.MethodGet, r.path(""), nil, &body); err != nil {
+		return librobot.RobotState{}, err
+	}
+	return body.robotState(), nil
+}
+
+// stateBody is the state of a robot in state responses and `robotstate` events.
+// The battery is omitted by the server if the warehouse does not model batteries.
+type stateBody struct {
+	X       uint `json:"x"`
+	Y       uint `json:"y"`
+	Battery uint `json:"battery"`
+}
+
+// robotState converts the body to the state of a robot.
+func (s stateBody) robotState() librobot.RobotState {
+	return librobot.RobotState{X: s.X, Y: s.Y, Battery: s.Battery}
+}
+
+// Watch sends the state of the robot whenever it changes, including changes by the tasks of other clients, until the
+// context is done or the event stream closes (e.g. as the server shuts down); the channel is closed then.
+// The oldest state is dropped if the channel is full, so slow receivers get the latest state.
+func (r *Robot) Watch(ctx context.Context) (<-chan librobot.RobotState, error) {
+	done, err := r.subscribe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to robot '%s': %w", r.id, err)
+	}
+
+	// the channel is closed right away if the stream has been closed since
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	states := make(chan librobot.RobotState, 16)
+	r.watchers[states] = struct{}{}
+	go func(done chan struct{}) {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.watchers[states]; ok {
+			delete(r.watchers, states)
+			close(states)
+		}
+	}(done)
+	return states, nil
+}
+
+// subscribe opens the event stream of the robot, unless it is open, returning the channel closed once the stream is closed.
+// The server responds once the robot is subscribed to, so the events of tasks queued afterwards are received.
+// r.mu must not be held; it is released while the stream is opened, and concurrent calls wait for the same stream.
+func (r *Robot) subscribe() (chan struct{}, error) {
+	r.mu.Lock()
+	for r.opening != nil {
+		opening := r.opening
+		r.mu.Unlock()
+		<-opening
+		r.mu.Lock()
+	}
+	if r.cancel != nil {
+		defer r.mu.Unlock()
+		return r.done, nil
+	}
+	opening := make(chan struct{})
+	r.opening = opening
+	r.mu.Unlock()
+
+	body, cancel, err := r.open()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opening = nil
+	close(opening)
+	if err == nil && r.client.isClosed() {
+		body.Close()
+		cancel()
+		err = ErrClosed
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.listen(body, cancel, r.done)
+	return r.done, nil
+}
+
+// open requests the event stream of the robot; the stream is closed by cancel.
+func (r *Robot) open() (io.ReadCloser, context.CancelFunc, error) {
+	if r.client.isClosed() {
+		return nil, nil, ErrClosed
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := r.client.request(ctx, http.MethodGet, r.path("/subscribe"), nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the timeout only applies until the response header is received
+	timer := time.AfterFunc(DefaultTimeout, cancel)
+	resp, err := r.client.stream.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	if !timer.Stop() {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("timed out after %s waiting for the event stream", DefaultTimeout)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		cancel()
+		return nil, nil, responseError(resp)
+	}
+	return resp.Body, cancel, nil
+}
+
+// listen dispatches the events of the stream until it is closed; tasks pending once the stream is closed fail.
+// A stream closed by the server other than by a `shutdown` event fell behind the events of the robot, so it is re-opened.
+func (r *Robot) listen(body io.ReadCloser, cancel context.CancelFunc, done chan struct{}) {
+	defer close(done)
+
+	var err error
+	for {
+		shutdown := false
+		err = readEvents(body, func(event string, data []byte) {
+			shutdown = shutdown || event == "shutdown"
+			r.dispatch(event, data)
+		})
+		body.Close()
+		cancel()
+		if err != nil || shutdown || r.client.isClosed() {
+			break
+		}
+		if body, cancel, err = r.resync(); err != nil {
+			err = fmt.Errorf("failed to re-open the event stream: %w", err)
+			break
+		}
+	}
+	if r.client.isClosed() || err == nil {
+		err = r.streamError()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cancel = nil
+	for taskID, task := range r.pending {
+		task.err <- fmt.Errorf("task %s of robot '%s': %w", taskID, r.id, err)
+	}
+	r.pending = make(map[string]pendingTask)
+	for states := range r.watchers {
+		close(states)
+	}
+	r.watchers = make(map[chan librobot.RobotState]struct{})
+}
+
+// resync re-opens the event stream after the server closed it as the client fell behind, and polls the status of the
+// pending tasks; the stream is opened first, so the outcome of tasks executed afterwards is received from the stream.
+// Tasks queued concurrently wait for the stream to be opened (see subscribe), or are polled once queued (see EnqueueTask).
+func (r *Robot) resync() (io.ReadCloser, context.CancelFunc, error) {
+	r.mu.Lock()
+	for r.opening != nil {
+		opening := r.opening
+		r.mu.Unlock()
+		<-opening
+		r.mu.Lock()
+	}
+	opening := make(chan struct{})
+	r.opening = opening
+	r.resyncs++
+	r.mu.Unlock()
+
+	body, cancel, err := r.open()
+
+	r.mu.Lock()
+	r.opening = nil
+	close(opening)
+	if err == nil && r.client.isClosed() {
+		body.Close()
+		cancel()
+		err = ErrClosed
+	}
+	if err != nil {
+		r.mu.Unlock()
+		return nil, nil, err
+	}
+	r.cancel = cancel
+	taskIDs := make([]string, 0, len(r.pending))
+	for taskID := range r.pending {
+		taskIDs = append(taskIDs, taskID)
+	}
+	r.mu.Unlock()
+
+	if state, err := r.State(context.Background()); err == nil {
+		r.mu.Lock()
+		r.setState(state)
+		r.mu.Unlock()
+	}
+	for _, taskID := range taskIDs {
+		r.poll(taskID)
+	}
+	return body, cancel, nil
+}
+
+// poll gets the status of a pending task, sending its outcome if it has been executed.
+// The outcome of a polled task lacks the details of the event stream: the state sent is the current state of the robot,
+// and failed tasks report the `task-failed` problem.
+func (r *Robot) poll(taskID string) {
+	task, err := r.client.Task(context.Background(), taskID)
+	if err != nil || !task.Executed {
+		return
+	}
+	result := outcome{}
+	if task.Success {
+		result.state = r.CurrentState()
+	} else {
+		result.err = &Error{TaskID: taskID, Problem: Problem{
+			Type:   "urn:robot:problem:" + CodeTaskFailed,
+			Code:   CodeTaskFailed,
+			Title:  "Task failed",
+			Detail: "the problem of the task was missed as the event stream fell behind",
+		}}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pending, ok := r.pending[taskID]; ok {
+		delete(r.pending, taskID)
+		result.send(pending)
+	}
+}
+
+// streamError returns the error of tasks pending once the event stream is closed (other than by a read error).
+func (r *Robot) streamError() error {
+	if r.client.isClosed() {
+		return ErrClosed
+	}
+	return errors.New("event stream closed by the server")
+}
+
+// isDone reports whether a channel is closed.
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeStream closes the event stream of the robot, waiting for the pending tasks to fail.
+func (r *Robot) closeStream() {
+	r.mu.Lock()
+	cancel, done := r.cancel, r.done
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// dispatch sends the outcome of executed tasks to the channels of the pending tasks.
+// The `robotstate` event of a task precedes its `task` event, so the state sent is the state the task moved the robot to.
+func (r *Robot) dispatch(event string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch event {
+	case "robotstate":
+		var state stateBody
+		if json.Unmarshal(data, &state) == nil {
+			r.setState(state.robotState())
+		}
+	case "task":
+		var executed struct {
+			Task    Task     `json:"task"`
+			Problem *Problem `json:"problem"`
+		}
+		if json.Unmarshal(data, &executed) != nil {
+			return
+		}
+		result := outcome{state: r.state}
+		if executed.Problem != nil {
+			result.err = &Error{Problem: *executed.Problem, TaskID: executed.Task.ID}
+		}
+		task, ok := r.pending[executed.Task.ID]
+		if !ok {
+			// tasks queued by other clients, or by requests of this client which have not been responded to yet
+			if r.enqueuing > 0 {
+				if r.unclaimed == nil {
+					r.unclaimed = make(map[string]outcome)
+				}
+				r.unclaimed[executed.Task.ID] = result
+			}
+			return
+		}
+		delete(r.pending, executed.Task.ID)
+		result.send(task)
+	case "shutdown":
+		var problem Problem
+		json.Unmarshal(data, &problem)
+		for taskID, task := range r.pending {
+			task.err <- &Error{Problem: problem, TaskID: taskID}
+		}
+		r.pending = make(map[string]pendingTask)
+	}
+}
+
+// setState records the last state of the robot and sends it to the watchers; r.mu must be held.
+func (r *Robot) setState(state librobot.RobotState) {
+	r.state = state
+	for states := range r.watchers {
+		select {
+		case <-states:
+		default:
+		}
+		states <- r.state
+	}
+}
+
+// readEvents reads server-sent events until the end of the stream, calling dispatch with the name and data of each event.
+// See https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation
+func readEvents(r io.Reader, dispatch func(event string, data []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	event, data := "", []string(nil)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data != nil {
+				if event == "" {
+					event = "message"
+				}
+				dispatch(event, []byte(strings.Join(data, "\n")))
+			}
+			event, data = "", nil
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,204 @@
+// Package cmdlang implements the robot command language; a command sequence is lexed, parsed and compiled
+// to the primitive stream of `N`, `S`, `E`, `W` and `C` commands executed by a robot.
+//
+// Commands must be delimited by whitespace (spaces, tabs or newlines). On top of primitive commands, the language supports:
+//   - repeat counts after a command, e.g. `N9` moves nine cells north
+//   - repeated groups, e.g. `3(N E)` moves diagonally three times
+//   - comments from `#` to the end of the line
+//
+// The package is shared by the robot server (a-restful), the session simulator and timelines of this library, and the CLI,
+// so they all agree on what a valid task is.
+// Invalid command sequences result in an `*Error` carrying the offending token, its position and the reason.
+package cmdlang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenKind identifies the type of a lexed token
+type TokenKind int
+
+const (
+	// EOF marks the end of the command sequence
+	EOF TokenKind = iota
+	// Direction is a primitive command; one of `N`, `S`, `E` or `W` (movement), or `C` (charge)
+	Direction
+	// Number is a repeat count
+	Number
+	// LParen opens a group of commands
+	LParen
+	// RParen closes a group of commands
+	RParen
+)
+
+// Position is the location of a token within the command sequence
+// - Offset is the byte offset; Line and Column (in characters) are 1-based
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line > 1 {
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("column %d", p.Column)
+}
+
+// Token is a lexeme of the command language
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Start Position
+}
+
+// Reason classifies why a command sequence is invalid
+type Reason string
+
+const (
+	// InvalidCommand is a character or word which is not a command
+	InvalidCommand Reason = "invalid-command"
+	// MultiLetterCommand is a word of several commands which are not delimited by whitespace, e.g. `NE`
+	MultiLetterCommand Reason = "multi-letter-command"
+	// UnexpectedToken is a token which is not allowed at its position, e.g. a stray `)`
+	UnexpectedToken Reason = "unexpected-token"
+	// UnexpectedEnd is a command sequence which ends prematurely
+	UnexpectedEnd Reason = "unexpected-end"
+	// UnclosedGroup is a group without a closing `)`
+	UnclosedGroup Reason = "unclosed-group"
+	// EmptyGroup is a group without commands, e.g. `()`
+	EmptyGroup Reason = "empty-group"
+	// MissingCommand is a repeat count which is not followed by a command or group
+	MissingCommand Reason = "missing-command"
+	// InvalidRepeatCount is a repeat count which is out of range or repeated, e.g. `N0` or `3N3`
+	InvalidRepeatCount Reason = "invalid-repeat-count"
+	// TooManyCommands is a command sequence which expands to more than `MaxCommands` commands
+	TooManyCommands Reason = "too-many-commands"
+	// EmptySequence is a command sequence without any commands
+	EmptySequence Reason = "empty-sequence"
+)
+
+// Error is a lexing or parsing error of a command sequence
+// - Token is the offending text (empty at the end of the sequence), located at Pos
+type Error struct {
+	Reason  Reason
+	Token   string
+	Pos     Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Message)
+}
+
+// Underline renders the line of the command sequence containing the error, underlining the offending token with carets
+//
+//	N E NE S
+//	    ^^
+func (e *Error) Underline(src string) string {
+	lines := strings.Split(src, "\n")
+	if e.Pos.Line < 1 || e.Pos.Line > len(lines) {
+		return ""
+	}
+	line := strings.ReplaceAll(lines[e.Pos.Line-1], "\t", " ")
+
+	width := utf8.RuneCountInString(e.Token)
+	if width == 0 {
+		width = 1
+	}
+	return fmt.Sprintf("%s\n%s%s", line, strings.Repeat(" ", e.Pos.Column-1), strings.Repeat("^", width))
+}
+
+// lexer splits a command sequence into tokens, skipping whitespace and comments
+type lexer struct {
+	src []rune
+	pos Position
+	i   int
+}
+
+// Lex converts a command sequence to tokens; the final token is always `EOF`
+func Lex(src string) ([]Token, error) {
+	l := lexer{src: []rune(src), pos: Position{Line: 1, Column: 1}}
+	var tokens []Token
+	for {
+		token, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+		if token.Kind == EOF {
+			return tokens, nil
+		}
+	}
+}
+
+// advance consumes the current character
+func (l *lexer) advance() {
+	r := l.src[l.i]
+	l.i++
+	l.pos.Offset += len(string(r))
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+		return
+	}
+	l.pos.Column++
+}
+
+// next lexes the next token
+func (l *lexer) next() (Token, error) {
+	// skip whitespace and comments
+	for l.i < len(l.src) {
+		r := l.src[l.i]
+		if unicode.IsSpace(r) {
+			l.advance()
+			continue
+		}
+		if r == '#' {
+			for l.i < len(l.src) && l.src[l.i] != '\n' {
+				l.advance()
+			}
+			continue
+		}
+		break
+	}
+
+	start := l.pos
+	if l.i >= len(l.src) {
+		return Token{Kind: EOF, Start: start}, nil
+	}
+
+	r := l.src[l.i]
+	switch {
+	case unicode.IsLetter(r):
+		from := l.i
+		for l.i < len(l.src) && unicode.IsLetter(l.src[l.i]) {
+			l.advance()
+		}
+		word := string(l.src[from:l.i])
+		if strings.Trim(word, "NSEWC") != "" {
+			return Token{}, &Error{Reason: InvalidCommand, Token: word, Pos: start, Message: fmt.Sprintf("invalid command '%s', command can only be one of 'N', 'S', 'E', 'W' or 'C'", word)}
+		}
+		if len(word) > 1 {
+			return Token{}, &Error{Reason: MultiLetterCommand, Token: word, Pos: start, Message: fmt.Sprintf("invalid command '%s', commands must be delimited by whitespace", word)}
+		}
+		return Token{Kind: Direction, Text: word, Start: start}, nil
+	case r == '(':
+		l.advance()
+		return Token{Kind: LParen, Text: "(", Start: start}, nil
+	case r == ')':
+		l.advance()
+		return Token{Kind: RParen, Text: ")", Start: start}, nil
+	case r >= '0' && r <= '9':
+		from := l.i
+		for l.i < len(l.src) && l.src[l.i] >= '0' && l.src[l.i] <= '9' {
+			l.advance()
+		}
+		return Token{Kind: Number, Text: string(l.src[from:l.i]), Start: start}, nil
+	}
+	return Token{}, &Error{Reason: InvalidCommand, Token: string(r), Pos: start, Message: fmt.Sprintf("invalid command '%s', command can only be one of 'N', 'S', 'E', 'W' or 'C'", string(r))}
+}
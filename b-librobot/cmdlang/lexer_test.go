@@ -0,0 +1,51 @@
+package cmdlang
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	t.Run("test tokens with positions", func(t *testing.T) {
+		tokens, err := Lex("N9 # north\n3(E)")
+		if err != nil {
+			t.Fatalf("command sequence should be lexed; %v", err)
+		}
+
+		want := []Token{
+			{Direction, "N", Position{0, 1, 1}},
+			{Number, "9", Position{1, 1, 2}},
+			{Number, "3", Position{11, 2, 1}},
+			{LParen, "(", Position{12, 2, 2}},
+			{Direction, "E", Position{13, 2, 3}},
+			{RParen, ")", Position{14, 2, 4}},
+			{EOF, "", Position{15, 2, 5}},
+		}
+		if len(tokens) != len(want) {
+			t.Fatalf("incorrect number of tokens; got: %v, want: %v", tokens, want)
+		}
+		for i := range want {
+			if tokens[i] != want[i] {
+				t.Errorf("incorrect token %d; got: %+v, want: %+v", i, tokens[i], want[i])
+			}
+		}
+	})
+
+	t.Run("test invalid character reports column", func(t *testing.T) {
+		_, err := Lex("N E\tA")
+
+		var lexErr *Error
+		if !errors.As(err, &lexErr) {
+			t.Fatalf("character `A` is invalid; got: %v", err)
+		}
+		if lexErr.Pos.Column != 5 {
+			t.Errorf("character `A` should be reported at column 5; got: %d", lexErr.Pos.Column)
+		}
+	})
+
+	t.Run("test lowercase commands are invalid", func(t *testing.T) {
+		if _, err := Lex("n"); err == nil {
+			t.Error("command `n` is invalid")
+		}
+	})
+}
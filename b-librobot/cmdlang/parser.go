@@ -0,0 +1,233 @@
+package cmdlang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxCommands is the maximum number of primitive commands a command sequence may compile to
+const MaxCommands = 10000
+
+// Syntax describes the command language for help texts, e.g. of the CLI
+var Syntax = "Commands are N (north), E (east), S (south), W (west) and C (charge the battery at a charging station),\n" +
+	"delimited by whitespace. A repeat count before or directly after a command or group repeats it, e.g. N9 or 9N;\n" +
+	"groups of commands are parenthesised, e.g. 3(N E); # starts a comment until the end of the line. A sequence may\n" +
+	fmt.Sprintf("expand to at most %d commands.", MaxCommands)
+
+// Command is a primitive robot command; one of `N`, `S`, `E` or `W` (movement), or `C` (charge)
+type Command rune
+
+// Charge is the command charging the battery of a robot at a charging station
+const Charge Command = 'C'
+
+// Sequence is a compiled stream of primitive commands
+type Sequence []Command
+
+// String formats the sequence as whitespace delimited primitive commands, e.g. `N N E`
+func (s Sequence) String() string {
+	commands := make([]string, len(s))
+	for i, c := range s {
+		commands[i] = string(c)
+	}
+	return strings.Join(commands, " ")
+}
+
+// Node is a (possibly repeated) command or group of commands within a parsed command sequence
+// - Direction is set for primitive commands, Group is set for groups
+// - Token is the text of the first token of the node (the repeat count if it precedes the command or group)
+type Node struct {
+	Pos       Position
+	Token     string
+	Direction Command
+	Group     []Node
+	Count     int
+}
+
+// Program is a parsed command sequence
+type Program []Node
+
+// parser builds a Program from tokens
+type parser struct {
+	tokens []Token
+	i      int
+}
+
+// Parse lexes and parses a command sequence
+func Parse(src string) (Program, error) {
+	tokens, err := Lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	nodes, err := p.sequence()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.Kind != EOF {
+		return nil, &Error{Reason: UnexpectedToken, Token: token.Text, Pos: token.Start, Message: fmt.Sprintf("unexpected '%s'", token.Text)}
+	}
+	return Program(nodes), nil
+}
+
+// Compile parses a command sequence and expands repeat counts and groups to primitive commands
+func Compile(src string) (Sequence, error) {
+	program, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return program.Compile()
+}
+
+// Validate compiles a command sequence, additionally rejecting sequences without any commands (e.g. only comments)
+func Validate(src string) (Sequence, error) {
+	sequence, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(sequence) == 0 {
+		return nil, &Error{Reason: EmptySequence, Pos: endOf(src), Message: "failed to execute empty commands"}
+	}
+	return sequence, nil
+}
+
+// endOf returns the position after the last character of a command sequence
+func endOf(src string) Position {
+	pos := Position{Offset: len(src), Line: strings.Count(src, "\n") + 1}
+	pos.Column = utf8.RuneCountInString(src[strings.LastIndex(src, "\n")+1:]) + 1
+	return pos
+}
+
+// Compile expands repeat counts and groups of the program to primitive commands
+func (p Program) Compile() (Sequence, error) {
+	var sequence Sequence
+	err := expand(p, &sequence)
+	return sequence, err
+}
+
+// expand appends the primitive commands of the nodes to the sequence
+func expand(nodes []Node, sequence *Sequence) error {
+	for _, node := range nodes {
+		for i := 0; i < node.Count; i++ {
+			if node.Group != nil {
+				if err := expand(node.Group, sequence); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(*sequence) >= MaxCommands {
+				return &Error{Reason: TooManyCommands, Token: node.Token, Pos: node.Pos, Message: fmt.Sprintf("command sequence expands to more than %d commands", MaxCommands)}
+			}
+			*sequence = append(*sequence, node.Direction)
+		}
+	}
+	return nil
+}
+
+func (p *parser) peek() Token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() Token {
+	token := p.tokens[p.i]
+	if token.Kind != EOF {
+		p.i++
+	}
+	return token
+}
+
+// sequence parses terms until the end of the command sequence or group
+func (p *parser) sequence() ([]Node, error) {
+	var nodes []Node
+	for {
+		switch p.peek().Kind {
+		case EOF, RParen:
+			return nodes, nil
+		}
+		node, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+}
+
+// term parses a command or group with an optional repeat count before or after it, e.g. `N9` or `3(N E)`
+// - a repeat count separated from the preceding command by whitespace is a prefix of the following command, e.g. `N 2(E)`
+func (p *parser) term() (Node, error) {
+	prefix, hasPrefix := p.peek(), p.peek().Kind == Number
+	count := 1
+	if hasPrefix {
+		p.next()
+		n, err := repeatCount(prefix)
+		if err != nil {
+			return Node{}, err
+		}
+		count = n
+		if kind := p.peek().Kind; kind != Direction && kind != LParen {
+			return Node{}, &Error{Reason: MissingCommand, Token: prefix.Text, Pos: prefix.Start, Message: fmt.Sprintf("repeat count '%s' must be followed by a command or group", prefix.Text)}
+		}
+	}
+
+	node, err := p.atom()
+	if err != nil {
+		return Node{}, err
+	}
+	if hasPrefix {
+		node.Pos, node.Token = prefix.Start, prefix.Text
+	}
+
+	// a repeat count directly following a command or group (without whitespace) is a suffix, e.g. `N9` or `(N E)3`
+	last := p.tokens[p.i-1]
+	if suffix := p.peek(); suffix.Kind == Number && suffix.Start.Offset == last.Start.Offset+len(last.Text) {
+		p.next()
+		if hasPrefix {
+			return Node{}, &Error{Reason: InvalidRepeatCount, Token: suffix.Text, Pos: suffix.Start, Message: fmt.Sprintf("repeat count '%s' follows repeat count '%s'", suffix.Text, prefix.Text)}
+		}
+		if count, err = repeatCount(suffix); err != nil {
+			return Node{}, err
+		}
+	}
+
+	node.Count = count
+	return node, nil
+}
+
+// atom parses a primitive command or a parenthesised group
+func (p *parser) atom() (Node, error) {
+	token := p.next()
+	switch token.Kind {
+	case Direction:
+		return Node{Pos: token.Start, Token: token.Text, Direction: Command(token.Text[0])}, nil
+	case LParen:
+		group, err := p.sequence()
+		if err != nil {
+			return Node{}, err
+		}
+		if p.peek().Kind != RParen {
+			return Node{}, &Error{Reason: UnclosedGroup, Token: token.Text, Pos: token.Start, Message: "group is not closed; missing ')'"}
+		}
+		p.next()
+		if len(group) == 0 {
+			return Node{}, &Error{Reason: EmptyGroup, Token: "()", Pos: token.Start, Message: "group must contain at least one command"}
+		}
+		return Node{Pos: token.Start, Token: token.Text, Group: group}, nil
+	case EOF:
+		return Node{}, &Error{Reason: UnexpectedEnd, Pos: token.Start, Message: "unexpected end of command sequence"}
+	}
+	return Node{}, &Error{Reason: UnexpectedToken, Token: token.Text, Pos: token.Start, Message: fmt.Sprintf("unexpected '%s'", token.Text)}
+}
+
+// repeatCount converts a number token to a repeat count
+func repeatCount(token Token) (int, error) {
+	n, err := strconv.Atoi(token.Text)
+	if err != nil || n > MaxCommands {
+		return 0, &Error{Reason: InvalidRepeatCount, Token: token.Text, Pos: token.Start, Message: fmt.Sprintf("repeat count '%s' exceeds %d", token.Text, MaxCommands)}
+	}
+	if n < 1 {
+		return 0, &Error{Reason: InvalidRepeatCount, Token: token.Text, Pos: token.Start, Message: fmt.Sprintf("repeat count '%s' must be at least 1", token.Text)}
+	}
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package cmdlang
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"N E S W", "N E S W"},
+		{"N\tE\n\nS  W", "N E S W"},
+		{"N3", "N N N"},
+		{"N 3E", "N E E E"},
+		{"3N", "N N N"},
+		{"2(N E)", "N E N E"},
+		{"(N E)2", "N E N E"},
+		{"2(N 2(E))", "N E E N E E"},
+		{"N # comment\nE", "N E"},
+		{"N C 2(E) C", "N C E E C"},
+		{"", ""},
+		{"# comment only", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("test `%s` compiles to `%s`", test.src, test.want), func(t *testing.T) {
+			got, err := Compile(test.src)
+			if err != nil {
+				t.Fatalf("command sequence should compile; %v", err)
+			}
+			if got.String() != test.want {
+				t.Errorf("incorrect primitive commands; got: %s, want: %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		src    string
+		column int
+	}{
+		{"N E A", 5},
+		{"N EW", 3},
+		{"N EC", 3},
+		{"N Ex", 3},
+		{"N (E", 3},
+		{"N E)", 4},
+		{"()", 1},
+		{"3", 1},
+		{"N 0", 3},
+		{"3N3", 3},
+		{"2(N E 3)", 7},
+		{"N99999", 2},
+		{"101(N100)", 5},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("test `%s` fails at column %d", test.src, test.column), func(t *testing.T) {
+			_, err := Compile(test.src)
+
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("command sequence should fail to compile; got: %v", err)
+			}
+			if parseErr.Pos.Column != test.column {
+				t.Errorf("incorrect error column; got: %d, want: %d (%v)", parseErr.Pos.Column, test.column, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("test valid command sequence", func(t *testing.T) {
+		if _, err := Validate("N E"); err != nil {
+			t.Errorf("command sequence `N E` is valid; %v", err)
+		}
+	})
+
+	t.Run("test empty command sequences are invalid", func(t *testing.T) {
+		for _, src := range []string{"", " \t", "# comment\n"} {
+			_, err := Validate(src)
+
+			var parseErr *Error
+			if !errors.As(err, &parseErr) || parseErr.Reason != EmptySequence {
+				t.Errorf("command sequence `%q` is empty; got: %v", src, err)
+			}
+		}
+	})
+}
+
+func TestErrorReasons(t *testing.T) {
+	tests := []struct {
+		src    string
+		reason Reason
+		token  string
+	}{
+		{"N A", InvalidCommand, "A"},
+		{"N Nx", InvalidCommand, "Nx"},
+		{"N NE", MultiLetterCommand, "NE"},
+		{"N )", UnexpectedToken, ")"},
+		{"2(N", UnclosedGroup, "("},
+		{"()", EmptyGroup, "()"},
+		{"3", MissingCommand, "3"},
+		{"N0", InvalidRepeatCount, "0"},
+		{"101(N100)", TooManyCommands, "N"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("test `%s` fails with reason %s", test.src, test.reason), func(t *testing.T) {
+			_, err := Compile(test.src)
+
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("command sequence should fail to compile; got: %v", err)
+			}
+			if parseErr.Reason != test.reason || parseErr.Token != test.token {
+				t.Errorf("incorrect error; got: %s `%s`, want: %s `%s`", parseErr.Reason, parseErr.Token, test.reason, test.token)
+			}
+		})
+	}
+}
+
+func TestErrorUnderline(t *testing.T) {
+	src := "N E\n\tS NE W"
+	_, err := Compile(src)
+
+	var parseErr *Error
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("command sequence should fail to compile; got: %v", err)
+	}
+
+	want := " S NE W\n   ^^"
+	if got := parseErr.Underline(src); got != want {
+		t.Errorf("incorrect underline; got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := parseErr.Error(); got != "line 2, column 4: invalid command 'NE', commands must be delimited by whitespace" {
+		t.Errorf("incorrect error message; got: %s", got)
+	}
+}
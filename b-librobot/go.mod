@@ -0,0 +1,3 @@
+module github.com/zees-dev/robot-challenge/b-librobot
+
+go 1.16
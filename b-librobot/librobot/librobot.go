@@ -23,10 +23,12 @@ type Robot interface {
 }
 
 // RobotState provides an abstraction of the state of a warehouse robot.
+// Battery is the battery level of the robot; it is 0 if the warehouse does not model batteries.
 type RobotState struct {
 	X        uint
 	Y        uint
 	HasCrate bool
+	Battery  uint
 }
 
 // ALL DONE.
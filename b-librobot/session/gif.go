@@ -0,0 +1,89 @@
+package session
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteGIF renders the timeline of a session as an animated GIF image, looping indefinitely.
+//
+// A frame is rendered whenever a robot reaches a cell or a crate is added or removed, shown until the next frame (GIFs
+// time frames in hundredths of a second, so changes less than 10ms apart are merged). Robots are circles in the colors
+// of the legend of WriteSVG, with a crate while they carry one; crates are squares. GIFs have no text, so the robots are
+// not labelled.
+func WriteGIF(w io.Writer, t *Timeline) error {
+	palette := color.Palette{color.White, parseColor("#cccccc"), parseColor(crateColor)}
+	for _, c := range robotColors {
+		palette = append(palette, parseColor(c))
+	}
+	const (
+		background = 0
+		lines      = 1
+		crate      = 2
+		robots     = 3
+	)
+
+	size := int(t.Size)
+	bounds := image.Rect(0, 0, size*cellSize+1, size*cellSize+1)
+	// the top left corner of a cell
+	corner := func(x uint, y uint) (int, int) {
+		return int(x) * cellSize, (size - 1 - int(y)) * cellSize
+	}
+	fill := func(img *image.Paletted, x0 int, y0 int, x1 int, y1 int, index uint8, inside func(x int, y int) bool) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if inside == nil || inside(x, y) {
+					img.SetColorIndex(x, y, index)
+				}
+			}
+		}
+	}
+
+	render := func(at time.Duration) *image.Paletted {
+		img := image.NewPaletted(bounds, palette)
+		for i := 0; i <= size; i++ {
+			fill(img, i*cellSize, 0, i*cellSize+1, bounds.Max.Y, lines, nil)
+			fill(img, 0, i*cellSize, bounds.Max.X, i*cellSize+1, lines, nil)
+		}
+		for _, c := range t.Crates {
+			if c.From <= at && at < c.To || (c.To == t.Duration && at >= c.From) {
+				x, y := corner(c.X, c.Y)
+				fill(img, x+cellSize/6, y+cellSize/6, x+cellSize*5/6, y+cellSize*5/6, crate, nil)
+			}
+		}
+		for i, track := range t.Robots {
+			state := track.StateAt(at)
+			x, y := corner(state.X, state.Y)
+			cx, cy, r := x+cellSize/2, y+cellSize/2, cellSize*2/5
+			fill(img, x, y, x+cellSize, y+cellSize, uint8(robots+i%len(robotColors)), func(px int, py int) bool {
+				return (px-cx)*(px-cx)+(py-cy)*(py-cy) <= r*r
+			})
+			if state.HasCrate {
+				fill(img, cx-cellSize/5, cy-cellSize/5, cx+cellSize/5, cy+cellSize/5, crate, nil)
+			}
+		}
+		return img
+	}
+
+	anim := &gif.GIF{}
+	times := append(t.Times(), t.Duration+hold)
+	for i := 0; i < len(times)-1; i++ {
+		delay := int(times[i+1]/(10*time.Millisecond)) - int(times[i]/(10*time.Millisecond))
+		if delay <= 0 {
+			continue // merged into the next frame
+		}
+		anim.Image = append(anim.Image, render(times[i]))
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// parseColor parses a color of the form `#rrggbb`.
+func parseColor(s string) color.Color {
+	v, _ := strconv.ParseUint(s[1:], 16, 32)
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
@@ -0,0 +1,237 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// DefaultTimeout is the default maximum time a replay waits for the outcome of a task.
+const DefaultTimeout = time.Minute
+
+// Replayer replays recorded sessions against a warehouse.
+type Replayer struct {
+	// Robot looks up a robot of the warehouse by ID.
+	Robot func(id string) (librobot.Robot, error)
+	// Crates is the warehouse crates are added to and removed from; recordings with crate entries diverge if nil.
+	Crates librobot.CrateWarehouse
+	// Timeout is the maximum time to wait for the outcome of a task; DefaultTimeout if zero.
+	Timeout time.Duration
+}
+
+// Report summarises the replay of a recording.
+type Report struct {
+	Entries    int         // Entries replayed, including the divergent entry.
+	Tasks      int         // Tasks queued.
+	Outcomes   int         // Outcomes matching the recording.
+	Divergence *Divergence // First divergence from the recording; nil if the replay matched the recording.
+	Duration   time.Duration
+}
+
+// Divergence is a replayed input or outcome which differs from the recording.
+type Divergence struct {
+	Index int   // Index of the entry within the recording.
+	Entry Entry // Recorded entry.
+	Want  string
+	Got   string
+}
+
+// Error describes the divergence.
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("entry %d (%s): want %s, got %s", d.Index+1, describeEntry(d.Entry), d.Want, d.Got)
+}
+
+// replayedTask is a task queued by a replay.
+type replayedTask struct {
+	id    string
+	done  chan struct{} // closed once the task is executed (or failed)
+	state librobot.RobotState
+	err   error
+}
+
+// Replay replays the inputs of a recording in order, and checks that each of them (and each task) has the recorded
+// outcome; the replay stops at the first divergence.
+//
+// The IDs of the tasks recorded are mapped to the IDs of the tasks replayed. The replay waits for each recorded outcome
+// before replaying later entries, so inputs see the same completed tasks they saw when recorded; the order in which
+// the warehouse executes the tasks of different robots between those points is up to the warehouse.
+func (r *Replayer) Replay(ctx context.Context, entries []Entry) Report {
+	var report Report
+	started := time.Now()
+	robots := make(map[string]librobot.Robot)
+	tasks := make(map[string]*replayedTask)
+
+	robot := func(id string) (librobot.Robot, error) {
+		if robot, ok := robots[id]; ok {
+			return robot, nil
+		}
+		robot, err := r.Robot(id)
+		if err != nil {
+			return nil, err
+		}
+		robots[id] = robot
+		return robot, nil
+	}
+
+	for i, entry := range entries {
+		report.Entries++
+		want, got := r.replay(ctx, entry, robot, tasks, &report)
+		if !matches(want, got) {
+			report.Divergence = &Divergence{Index: i, Entry: entry, Want: want, Got: got}
+			break
+		}
+	}
+	report.Duration = time.Since(started)
+	return report
+}
+
+// replay replays an entry; returns the recorded and the replayed outcome of the entry, which are equal unless the
+// replay diverges.
+func (r *Replayer) replay(ctx context.Context, entry Entry, lookup func(string) (librobot.Robot, error), tasks map[string]*replayedTask, report *Report) (want string, got string) {
+	want = describeResult(entry.Error, entry.Code)
+
+	switch entry.Kind {
+	case KindRobot:
+		robot, err := lookup(entry.Robot)
+		if err != nil {
+			return entry.State.String(), describeError(err)
+		}
+		return entry.State.String(), positionOf(robot.CurrentState()).String()
+
+	case KindTask:
+		robot, err := lookup(entry.Robot)
+		if err != nil {
+			return want, describeError(err)
+		}
+		taskID, position, errs := robot.EnqueueTask(entry.Commands)
+		if taskID == "" {
+			timer, timeout := r.newTimer()
+			defer timer.Stop()
+			select {
+			case err := <-errs:
+				return want, describeError(err)
+			case <-timer.C:
+				return want, fmt.Sprintf("no error of rejected task after %s", timeout)
+			case <-ctx.Done():
+				return want, describeError(ctx.Err())
+			}
+		}
+		report.Tasks++
+		task := &replayedTask{id: taskID, done: make(chan struct{})}
+		tasks[entry.Task] = task
+		go func() {
+			select {
+			case task.state = <-position:
+			case task.err = <-errs:
+			}
+			close(task.done)
+		}()
+		return want, describeResult("", "")
+
+	case KindCancel:
+		robot, err := lookup(entry.Robot)
+		if err != nil {
+			return want, describeError(err)
+		}
+		taskID := entry.Task
+		if task, ok := tasks[entry.Task]; ok {
+			taskID = task.id
+		}
+		return want, describeError(robot.CancelTask(taskID))
+
+	case KindCrate:
+		if r.Crates == nil {
+			return want, "crates not supported by the warehouse"
+		}
+		if entry.State == nil {
+			return want, "crate entry without position"
+		}
+		if entry.Op == CrateDel {
+			return want, describeError(r.Crates.DelCrate(entry.State.X, entry.State.Y))
+		}
+		return want, describeError(r.Crates.AddCrate(entry.State.X, entry.State.Y))
+
+	case KindOutcome:
+		if entry.Error == "" {
+			want = "robot at " + entry.State.String()
+		}
+		task, ok := tasks[entry.Task]
+		if !ok {
+			return want, "task not queued"
+		}
+		timer, timeout := r.newTimer()
+		defer timer.Stop()
+		select {
+		case <-task.done:
+		case <-timer.C:
+			return want, fmt.Sprintf("no outcome after %s", timeout)
+		case <-ctx.Done():
+			return want, describeError(ctx.Err())
+		}
+		if task.err != nil {
+			got = describeError(task.err)
+		} else {
+			got = "robot at " + positionOf(task.state).String()
+		}
+		if matches(want, got) {
+			report.Outcomes++
+		}
+		return want, got
+	}
+	return "known entry", fmt.Sprintf("unknown kind '%s'", entry.Kind)
+}
+
+// newTimer starts a timer expiring once the timeout of the replayer elapsed; returns the timer and the timeout.
+func (r *Replayer) newTimer() (*time.Timer, time.Duration) {
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return time.NewTimer(timeout), timeout
+}
+
+// describeError describes the result of an input: success, or the failure (and its code).
+func describeError(err error) string {
+	if err == nil {
+		return describeResult("", "")
+	}
+	return describeResult(err.Error(), codeOf(err))
+}
+
+// describeResult describes a recorded result; failures are described by their code, as error messages contain IDs
+// which differ between sessions.
+func describeResult(err string, code string) string {
+	switch {
+	case err == "":
+		return "success"
+	case code == "":
+		return "failure"
+	}
+	return fmt.Sprintf("failure (%s)", code)
+}
+
+// describeEntry describes the input of an entry, e.g. `task "N E" of robot r1`.
+func describeEntry(entry Entry) string {
+	switch entry.Kind {
+	case KindRobot:
+		return "robot " + entry.Robot
+	case KindTask:
+		return fmt.Sprintf("task %q of robot %s", entry.Commands, entry.Robot)
+	case KindCancel:
+		return fmt.Sprintf("cancel of task %s of robot %s", entry.Task, entry.Robot)
+	case KindCrate:
+		return fmt.Sprintf("crate %s at %s", entry.Op, entry.State)
+	case KindOutcome:
+		return fmt.Sprintf("outcome of task %s of robot %s", entry.Task, entry.Robot)
+	}
+	return entry.Kind
+}
+
+// matches returns whether a replayed result matches the recorded result; failures recorded without code match any
+// failure.
+func matches(want string, got string) bool {
+	return want == got || (want == "failure" && strings.HasPrefix(got, "failure"))
+}
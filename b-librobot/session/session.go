@@ -0,0 +1,262 @@
+// Package session records the inputs of a simulation session and their outcomes, replays recorded sessions and
+// renders them as animations.
+//
+// A Recorder wraps the robots (and crates) of a warehouse: every robot used, task queued, task cancelled and crate
+// placed or removed is written to the recording, together with the outcome of each task. Entries are numbered in the
+// order they are recorded, a logical clock which orders the replay independently of how long the session took; they are
+// also timestamped with the time elapsed since the start of the session, which only paces animations. Entries are
+// written as JSON Lines, so recordings can be inspected with standard tools.
+//
+//	rec := session.NewRecorder(f)
+//	robot := rec.Robot("r1", warehouse.Robots()[0])
+//	robot.EnqueueTask("N E N E") // recorded, as is its outcome
+//
+// A Replayer re-runs the inputs of a recording in order and reports the first divergence from the recorded outcomes.
+// Each recorded outcome is awaited before the inputs recorded after it are replayed, so the inputs are replayed in the
+// order of the outcomes they observed.
+//
+//	entries, err := session.Read(f)
+//	report := (&session.Replayer{Robot: lookup}).Replay(ctx, entries)
+//	if report.Divergence != nil {
+//		log.Fatal(report.Divergence)
+//	}
+//
+// A Simulator is an in-process warehouse with the robots of a recording, so recordings can be replayed without a server.
+//
+//	simulator := session.NewSimulator(entries, session.SimulatorOptions{Size: 10})
+//	report := (&session.Replayer{Robot: simulator.Robot}).Replay(ctx, entries)
+//
+// NewTimeline reconstructs the history of the robots and crates of a recording, which WriteSVG and WriteGIF render as
+// animations.
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// Kinds of entries of a recording.
+const (
+	KindRobot   = "robot"   // A robot was used; its state when first used.
+	KindTask    = "task"    // A task was queued (or rejected).
+	KindCancel  = "cancel"  // A task was cancelled (or cancelling it failed).
+	KindCrate   = "crate"   // A crate was added or removed (or adding or removing it failed).
+	KindOutcome = "outcome" // A task was executed (or failed).
+)
+
+// Crate operations of KindCrate entries.
+const (
+	CrateAdd = "add"
+	CrateDel = "del"
+)
+
+// Entry is an entry of a recording.
+type Entry struct {
+	Seq      int64     `json:"seq"` // Logical clock: position of the entry in the session, starting at 1; 0 if not recorded.
+	At       int64     `json:"at"`  // Milliseconds elapsed since the start of the session (wall clock); paces animations.
+	Kind     string    `json:"kind"`
+	Robot    string    `json:"robot,omitempty"`
+	Task     string    `json:"task,omitempty"`     // ID of the task when it was recorded; replays map it to the ID of the replayed task.
+	Commands string    `json:"commands,omitempty"` // Commands of a task.
+	Op       string    `json:"op,omitempty"`       // Crate operation.
+	State    *Position `json:"state,omitempty"`    // State of a robot, position of a crate.
+	Error    string    `json:"error,omitempty"`    // Error of a failed input or task.
+	Code     string    `json:"code,omitempty"`     // Code of the error, if the error has one (e.g. `out-of-bounds`).
+}
+
+// Position is the position of a robot (and whether it carries a crate), or of a crate.
+type Position struct {
+	X        uint `json:"x"`
+	Y        uint `json:"y"`
+	HasCrate bool `json:"hasCrate,omitempty"`
+}
+
+// positionOf returns the position of a robot state.
+func positionOf(state librobot.RobotState) *Position {
+	return &Position{X: state.X, Y: state.Y, HasCrate: state.HasCrate}
+}
+
+// String formats the position, e.g. `(1, 2)`.
+func (p *Position) String() string {
+	if p == nil {
+		return "(unknown)"
+	}
+	if p.HasCrate {
+		return fmt.Sprintf("(%d, %d) carrying a crate", p.X, p.Y)
+	}
+	return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+}
+
+// codeOf returns the code of an error which has one, e.g. the problem code of an error of the client package.
+func codeOf(err error) string {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
+
+// Read reads the entries of a recording, ordered by their sequence number; entries without one (recorded before entries
+// were numbered) keep the order of the recording.
+func Read(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: invalid entry: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, scanner.Err()
+}
+
+// Recorder records the inputs of a session and their outcomes.
+// Recording is best effort: the first error writing the recording is reported by Err, and later entries are dropped.
+type Recorder struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	start  time.Time
+	seq    int64 // sequence number of the last entry
+	robots map[string]*recordingRobot
+	err    error
+}
+
+// NewRecorder creates a recorder writing the entries of a session to w; the session starts now.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w), start: time.Now(), robots: make(map[string]*recordingRobot)}
+}
+
+// Err returns the first error writing the recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record writes an entry, numbered and timestamped with the time elapsed since the start of the session.
+func (r *Recorder) record(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.seq++
+	entry.Seq, entry.At = r.seq, time.Since(r.start).Milliseconds()
+	r.err = r.enc.Encode(entry)
+}
+
+// recordError sets the error and code of an entry.
+func recordError(entry *Entry, err error) {
+	if err != nil {
+		entry.Error, entry.Code = err.Error(), codeOf(err)
+	}
+}
+
+// Robot returns a robot recording the tasks queued and cancelled, and the outcome of the tasks.
+// The robot (and its current state) is recorded when it is first returned; later calls with the same ID return the same
+// recording robot.
+func (r *Recorder) Robot(id string, robot librobot.Robot) librobot.Robot {
+	r.mu.Lock()
+	recording, ok := r.robots[id]
+	if !ok {
+		recording = &recordingRobot{Robot: robot, id: id, recorder: r}
+		r.robots[id] = recording
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		r.record(Entry{Kind: KindRobot, Robot: id, State: positionOf(robot.CurrentState())})
+	}
+	return recording
+}
+
+// Crates returns a warehouse recording the crates added and removed.
+func (r *Recorder) Crates(w librobot.CrateWarehouse) librobot.CrateWarehouse {
+	return recordingWarehouse{w, r}
+}
+
+// recordingRobot is a robot whose inputs and outcomes are recorded.
+type recordingRobot struct {
+	librobot.Robot
+	id       string
+	recorder *Recorder
+}
+
+// EnqueueTask queues a task, recording it and its outcome.
+func (r *recordingRobot) EnqueueTask(commands string) (string, chan librobot.RobotState, chan error) {
+	taskID, position, errs := r.Robot.EnqueueTask(commands)
+	entry := Entry{Kind: KindTask, Robot: r.id, Task: taskID, Commands: commands}
+	if taskID == "" {
+		// rejected tasks report their error right away
+		err := <-errs
+		recordError(&entry, err)
+		r.recorder.record(entry)
+		rejected := make(chan error, 1)
+		rejected <- err
+		return "", make(chan librobot.RobotState, 1), rejected
+	}
+	r.recorder.record(entry)
+
+	recordedPosition, recordedErr := make(chan librobot.RobotState, 1), make(chan error, 1)
+	go func() {
+		outcome := Entry{Kind: KindOutcome, Robot: r.id, Task: taskID}
+		select {
+		case state := <-position:
+			outcome.State = positionOf(state)
+			r.recorder.record(outcome)
+			recordedPosition <- state
+		case err := <-errs:
+			recordError(&outcome, err)
+			r.recorder.record(outcome)
+			recordedErr <- err
+		}
+	}()
+	return taskID, recordedPosition, recordedErr
+}
+
+// CancelTask cancels a task, recording the cancellation.
+func (r *recordingRobot) CancelTask(taskID string) error {
+	err := r.Robot.CancelTask(taskID)
+	entry := Entry{Kind: KindCancel, Robot: r.id, Task: taskID}
+	recordError(&entry, err)
+	r.recorder.record(entry)
+	return err
+}
+
+// recordingWarehouse is a warehouse whose crates are recorded.
+type recordingWarehouse struct {
+	librobot.CrateWarehouse
+	recorder *Recorder
+}
+
+// AddCrate adds a crate, recording it.
+func (w recordingWarehouse) AddCrate(x uint, y uint) error {
+	err := w.CrateWarehouse.AddCrate(x, y)
+	entry := Entry{Kind: KindCrate, Op: CrateAdd, State: &Position{X: x, Y: y}}
+	recordError(&entry, err)
+	w.recorder.record(entry)
+	return err
+}
+
+// DelCrate removes a crate, recording it.
+func (w recordingWarehouse) DelCrate(x uint, y uint) error {
+	err := w.CrateWarehouse.DelCrate(x, y)
+	entry := Entry{Kind: KindCrate, Op: CrateDel, State: &Position{X: x, Y: y}}
+	recordError(&entry, err)
+	w.recorder.record(entry)
+	return err
+}
@@ -0,0 +1,287 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// codedError is an error with a problem code, like the errors of the client package
+type codedError struct{ code string }
+
+func (e codedError) Error() string { return "failed: " + e.code }
+func (e codedError) Code() string  { return e.code }
+
+// fakeRobot executes the `N` and `E` commands of its tasks as soon as they are queued, on a grid of a size
+type fakeRobot struct {
+	mu    sync.Mutex
+	size  uint
+	state librobot.RobotState
+	next  int
+}
+
+func (r *fakeRobot) EnqueueTask(commands string) (string, chan librobot.RobotState, chan error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	position, errs := make(chan librobot.RobotState, 1), make(chan error, 1)
+	if commands == "" {
+		errs <- codedError{"invalid-command-sequence"}
+		return "", position, errs
+	}
+	r.next++
+	state := r.state
+	for _, command := range strings.Fields(commands) {
+		switch command {
+		case "N":
+			state.Y++
+		case "E":
+			state.X++
+		}
+		if state.X >= r.size || state.Y >= r.size {
+			errs <- codedError{"out-of-bounds"}
+			return fmt.Sprintf("task-%d", r.next), position, errs
+		}
+	}
+	r.state = state
+	position <- state
+	return fmt.Sprintf("task-%d", r.next), position, errs
+}
+
+// silentRobot rejects its tasks without ever reporting the error
+type silentRobot struct{ fakeRobot }
+
+func (r *silentRobot) EnqueueTask(commands string) (string, chan librobot.RobotState, chan error) {
+	return "", make(chan librobot.RobotState, 1), make(chan error, 1)
+}
+
+func (r *fakeRobot) CancelTask(taskID string) error {
+	return codedError{"task-finished"}
+}
+
+func (r *fakeRobot) CurrentState() librobot.RobotState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// fakeCrates is a warehouse of crates; crates cannot be placed twice in a cell
+type fakeCrates struct {
+	crates map[[2]uint]bool
+}
+
+func (w *fakeCrates) Robots() []librobot.Robot { return nil }
+
+func (w *fakeCrates) AddCrate(x uint, y uint) error {
+	if w.crates[[2]uint{x, y}] {
+		return codedError{"crate-exists"}
+	}
+	w.crates[[2]uint{x, y}] = true
+	return nil
+}
+
+func (w *fakeCrates) DelCrate(x uint, y uint) error {
+	delete(w.crates, [2]uint{x, y})
+	return nil
+}
+
+// lockedBuffer is a buffer which can be read while entries are recorded
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// record records a session with a robot of a size: two tasks (the second out of bounds), a failed cancellation and a crate
+func record(t *testing.T, robot *fakeRobot) []Entry {
+	var buf lockedBuffer
+	rec := NewRecorder(&buf)
+	r := rec.Robot("r1", robot)
+	if rec.Robot("r1", robot) != r {
+		t.Errorf("robot recorded twice")
+	}
+
+	taskID, position, _ := r.EnqueueTask("N E")
+	<-position
+	_, _, errs := r.EnqueueTask("N N N N")
+	<-errs
+	r.CancelTask(taskID)
+	rec.Crates(&fakeCrates{crates: map[[2]uint]bool{}}).AddCrate(2, 3)
+	if _, _, errs := r.EnqueueTask(""); (<-errs) == nil {
+		t.Errorf("unexpected error; got: nil, want: invalid-command-sequence")
+	}
+
+	// outcomes are recorded asynchronously
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if strings.Count(buf.String(), "\n") == 8 {
+			break
+		}
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	entries, err := Read(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	return entries
+}
+
+func TestRecorder(t *testing.T) {
+	entries := record(t, &fakeRobot{size: 4})
+
+	var kinds []string
+	for _, entry := range entries {
+		kinds = append(kinds, entry.Kind)
+	}
+	want := "robot task outcome task outcome cancel crate task"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Fatalf("unexpected entries; got: %v, want: %v", got, want)
+	}
+	if got := entries[2].State.String(); got != "(1, 1)" {
+		t.Errorf("unexpected outcome; got: %v, want: %v", got, "(1, 1)")
+	}
+	if got := entries[4].Code; got != "out-of-bounds" {
+		t.Errorf("unexpected code; got: %v, want: %v", got, "out-of-bounds")
+	}
+	if got := entries[5].Code; got != "task-finished" {
+		t.Errorf("unexpected code; got: %v, want: %v", got, "task-finished")
+	}
+	if got := entries[6]; got.Op != CrateAdd || got.State.String() != "(2, 3)" {
+		t.Errorf("unexpected crate entry; got: %v %v, want: add (2, 3)", got.Op, got.State)
+	}
+	if got := entries[7]; got.Task != "" || got.Code != "invalid-command-sequence" {
+		t.Errorf("unexpected rejected task; got: %v (%v), want: invalid-command-sequence", got.Task, got.Code)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].At < entries[i-1].At {
+			t.Errorf("unexpected timestamps; got: %v after %v", entries[i].At, entries[i-1].At)
+		}
+	}
+	for i, entry := range entries {
+		if entry.Seq != int64(i+1) {
+			t.Errorf("unexpected sequence number of entry %d; got: %v, want: %v", i+1, entry.Seq, i+1)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Run("test valid recording", func(t *testing.T) {
+		entries, err := Read(strings.NewReader(`{"at":0,"kind":"robot","robot":"r1","state":{"x":1,"y":2}}` + "\n\n" + `{"at":5,"kind":"task","robot":"r1","task":"t1","commands":"N"}` + "\n"))
+		if err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+		if len(entries) != 2 || entries[1].At != 5 || entries[1].Commands != "N" {
+			t.Errorf("unexpected entries; got: %+v", entries)
+		}
+	})
+
+	t.Run("test entries ordered by sequence number", func(t *testing.T) {
+		entries, err := Read(strings.NewReader(`{"seq":2,"at":0,"kind":"task","robot":"r1","task":"t1","commands":"N"}` + "\n" + `{"seq":1,"at":0,"kind":"robot","robot":"r1","state":{"x":1,"y":2}}` + "\n"))
+		if err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+		if len(entries) != 2 || entries[0].Kind != KindRobot || entries[1].Kind != KindTask {
+			t.Errorf("unexpected entries; got: %+v, want: robot then task", entries)
+		}
+	})
+
+	t.Run("test invalid recording", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"at":0,"kind":"robot"}` + "\nnot json\n"))
+		if err == nil || !strings.HasPrefix(err.Error(), "line 2: invalid entry") {
+			t.Errorf("unexpected error; got: %v, want: line 2: invalid entry", err)
+		}
+	})
+}
+
+func TestReplay(t *testing.T) {
+	entries := record(t, &fakeRobot{size: 4})
+
+	replay := func(robot *fakeRobot, crates librobot.CrateWarehouse) Report {
+		r := &Replayer{
+			Robot: func(id string) (librobot.Robot, error) {
+				if id != "r1" {
+					return nil, codedError{"robot-not-found"}
+				}
+				return robot, nil
+			},
+			Crates:  crates,
+			Timeout: time.Second,
+		}
+		return r.Replay(context.Background(), entries)
+	}
+
+	t.Run("test replay matching the recording", func(t *testing.T) {
+		report := replay(&fakeRobot{size: 4}, &fakeCrates{crates: map[[2]uint]bool{}})
+		if report.Divergence != nil {
+			t.Fatalf("unexpected divergence; got: %v, want: nil", report.Divergence)
+		}
+		if report.Entries != 8 || report.Tasks != 2 || report.Outcomes != 2 {
+			t.Errorf("unexpected report; got: %+v, want: 8 entries, 2 tasks, 2 outcomes", report)
+		}
+	})
+
+	t.Run("test robot in a different state", func(t *testing.T) {
+		report := replay(&fakeRobot{size: 4, state: librobot.RobotState{X: 1}}, nil)
+		want := "entry 1 (robot r1): want (0, 0), got (1, 0)"
+		if report.Divergence == nil || report.Divergence.Error() != want {
+			t.Errorf("unexpected divergence; got: %v, want: %v", report.Divergence, want)
+		}
+	})
+
+	t.Run("test different outcome", func(t *testing.T) {
+		report := replay(&fakeRobot{size: 6}, nil)
+		if report.Divergence == nil {
+			t.Fatalf("unexpected divergence; got: nil, want: entry 5")
+		}
+		if d := report.Divergence; d.Index != 4 || d.Want != "failure (out-of-bounds)" || d.Got != "robot at (1, 5)" {
+			t.Errorf("unexpected divergence; got: %v, want: entry 5 want failure (out-of-bounds), got robot at (1, 5)", d)
+		}
+		if report.Entries != 5 || report.Outcomes != 1 {
+			t.Errorf("unexpected report; got: %+v, want: 5 entries, 1 outcome", report)
+		}
+	})
+
+	t.Run("test crates not supported", func(t *testing.T) {
+		report := replay(&fakeRobot{size: 4}, nil)
+		if d := report.Divergence; d == nil || d.Index != 6 || d.Got != "crates not supported by the warehouse" {
+			t.Errorf("unexpected divergence; got: %v, want: crates not supported by the warehouse", d)
+		}
+	})
+
+	t.Run("test rejected task without error times out", func(t *testing.T) {
+		r := &Replayer{
+			Robot:   func(id string) (librobot.Robot, error) { return &silentRobot{}, nil },
+			Timeout: 10 * time.Millisecond,
+		}
+		rejected := []Entry{{Kind: KindTask, Robot: "r1", Commands: "N", Error: "queue full", Code: "queue-full"}}
+		report := r.Replay(context.Background(), rejected)
+		if d := report.Divergence; d == nil || d.Got != "no error of rejected task after 10ms" {
+			t.Errorf("unexpected divergence; got: %v, want: no error of rejected task after 10ms", d)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r.Timeout = time.Hour
+		report = r.Replay(ctx, rejected)
+		if d := report.Divergence; d == nil || d.Got != "failure" {
+			t.Errorf("unexpected divergence; got: %v, want: failure", d)
+		}
+	})
+}
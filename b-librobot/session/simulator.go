@@ -0,0 +1,266 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// SimulatorOptions configures a simulator.
+type SimulatorOptions struct {
+	// Size is the size of the (square) grid of the warehouse; DefaultGridSize if zero.
+	Size uint
+	// CommandDuration is the time a robot takes to perform each command; commands are performed instantly if zero, like
+	// the server does by default.
+	CommandDuration time.Duration
+}
+
+// Simulator is an in-process warehouse, e.g. to replay recordings or to operate robots without a robot server.
+// The robots of the simulator are the robots of a recording, placed at the state recorded when they were first used.
+// Like the robots of the server, each robot executes its tasks one at a time in the order they were queued, and tasks
+// fail `out-of-bounds` without moving the robot if a command would move it beyond the grid.
+//
+// Commands are compiled with the command language of the server (package cmdlang): tasks the server would reject, and
+// tasks without commands, are rejected with `invalid-command-sequence`.
+// Robots do not collide, and their batteries are not modelled (`C` commands take the time of a command without moving
+// the robot).
+// * implements librobot.Warehouse
+type Simulator struct {
+	opts   SimulatorOptions
+	mu     sync.Mutex
+	robots map[string]*simulatedRobot
+	next   int // number of the last task queued
+}
+
+// NewSimulator creates a simulator with the robots of a recording; only robot entries are needed to place robots, e.g.
+// `[]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{}}}` for a warehouse with a robot at (0, 0).
+func NewSimulator(entries []Entry, opts SimulatorOptions) *Simulator {
+	if opts.Size == 0 {
+		opts.Size = DefaultGridSize
+	}
+	s := &Simulator{opts: opts, robots: make(map[string]*simulatedRobot)}
+	for _, entry := range entries {
+		if _, ok := s.robots[entry.Robot]; entry.Kind != KindRobot || ok || entry.State == nil {
+			continue
+		}
+		s.robots[entry.Robot] = &simulatedRobot{simulator: s, state: entry.State.robotState(), tasks: make(map[string]*simulatedTask)}
+	}
+	return s
+}
+
+// Robot looks up a robot of the simulator; fails with `robot-not-found` if the recording does not use the robot.
+func (s *Simulator) Robot(id string) (librobot.Robot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	robot, ok := s.robots[id]
+	if !ok {
+		return nil, simulatorError{"robot-not-found", fmt.Sprintf("robot '%s' not found", id)}
+	}
+	return robot, nil
+}
+
+// Robots returns the robots of the simulator, ordered by ID.
+// * implements librobot.Warehouse
+func (s *Simulator) Robots() []librobot.Robot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.robots))
+	for id := range s.robots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	robots := make([]librobot.Robot, len(ids))
+	for i, id := range ids {
+		robots[i] = s.robots[id]
+	}
+	return robots
+}
+
+// simulatorError is an error of the simulator; its code is the problem code the server reports for the error.
+type simulatorError struct {
+	code   string
+	detail string
+}
+
+func (e simulatorError) Error() string { return e.detail }
+func (e simulatorError) Code() string  { return e.code }
+
+// commandError is the `invalid-command-sequence` error of a task; it unwraps to the error locating the offending token.
+type commandError struct {
+	err *cmdlang.Error
+}
+
+func (e commandError) Error() string { return e.err.Error() }
+func (e commandError) Code() string  { return "invalid-command-sequence" }
+func (e commandError) Unwrap() error { return e.err }
+
+// simulatedTask is a task queued on a robot of the simulator.
+type simulatedTask struct {
+	id        string
+	moves     cmdlang.Sequence
+	position  chan librobot.RobotState
+	err       chan error
+	running   bool
+	finished  bool
+	cancelled bool
+}
+
+// Watch sends the state of a robot whenever it changes until the context is done; the channel is closed then.
+// Watchers receive the latest state: states a watcher has not received yet are replaced by newer ones.
+func (s *Simulator) Watch(ctx context.Context, id string) (<-chan librobot.RobotState, error) {
+	robot, err := s.Robot(id)
+	if err != nil {
+		return nil, err
+	}
+	r := robot.(*simulatedRobot)
+	states := make(chan librobot.RobotState, 1)
+	r.mu.Lock()
+	r.watchers = append(r.watchers, states)
+	r.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for i, watcher := range r.watchers {
+			if watcher == states {
+				r.watchers = append(r.watchers[:i], r.watchers[i+1:]...)
+				break
+			}
+		}
+		close(states)
+	}()
+	return states, nil
+}
+
+// simulatedRobot is a robot of the simulator; its tasks are executed by a goroutine running while tasks are queued.
+// * implements librobot.Robot
+type simulatedRobot struct {
+	simulator *Simulator
+	mu        sync.Mutex
+	state     librobot.RobotState
+	queue     []*simulatedTask
+	tasks     map[string]*simulatedTask
+	running   bool // whether the goroutine executing the tasks is running
+	watchers  []chan librobot.RobotState
+}
+
+// EnqueueTask queues a task; tasks with invalid or empty command sequences are rejected.
+// * implements librobot.Robot
+func (r *simulatedRobot) EnqueueTask(commands string) (string, chan librobot.RobotState, chan error) {
+	position, errs := make(chan librobot.RobotState, 1), make(chan error, 1)
+	moves, err := cmdlang.Validate(commands)
+	if err != nil {
+		errs <- commandError{err.(*cmdlang.Error)}
+		return "", position, errs
+	}
+
+	r.simulator.mu.Lock()
+	r.simulator.next++
+	task := &simulatedTask{id: fmt.Sprintf("task-%d", r.simulator.next), moves: moves, position: position, err: errs}
+	r.simulator.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tasks[task.id] = task
+	r.queue = append(r.queue, task)
+	if !r.running {
+		r.running = true
+		go r.run()
+	}
+	return task.id, position, errs
+}
+
+// CancelTask cancels a queued task; cancelling a cancelled task succeeds.
+// * implements librobot.Robot
+func (r *simulatedRobot) CancelTask(taskID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task, ok := r.tasks[taskID]
+	switch {
+	case !ok:
+		return simulatorError{"task-not-found", fmt.Sprintf("task %s not found", taskID)}
+	case task.running:
+		return simulatorError{"task-running", fmt.Sprintf("task %s is being executed", taskID)}
+	case task.finished:
+		return simulatorError{"task-finished", fmt.Sprintf("task %s has been executed", taskID)}
+	}
+	task.cancelled = true
+	return nil
+}
+
+// CurrentState returns the current state of the robot.
+// * implements librobot.Robot
+func (r *simulatedRobot) CurrentState() librobot.RobotState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// notify sends the state of the robot to its watchers, replacing states they have not received yet; r.mu must be held.
+func (r *simulatedRobot) notify() {
+	for _, watcher := range r.watchers {
+		select {
+		case watcher <- r.state:
+		default:
+			select {
+			case <-watcher:
+			default:
+			}
+			watcher <- r.state // the only sender is holding r.mu
+		}
+	}
+}
+
+// run executes the queued tasks of the robot until the queue is empty.
+func (r *simulatedRobot) run() {
+	for {
+		r.mu.Lock()
+		if len(r.queue) == 0 {
+			r.running = false
+			r.mu.Unlock()
+			return
+		}
+		task := r.queue[0]
+		r.queue = r.queue[1:]
+		if task.cancelled {
+			r.mu.Unlock()
+			continue
+		}
+		task.running = true
+		state := r.state
+		r.mu.Unlock()
+
+		// the route is checked before the robot moves, like the server does
+		var err error
+		for _, command := range task.moves {
+			next, ok := move(state, command, r.simulator.opts.Size)
+			if !ok {
+				err = simulatorError{"out-of-bounds", fmt.Sprintf("command '%c' of task %s exceeds warehouse dimensions", command, task.id)}
+				break
+			}
+			state = next
+		}
+		if err == nil {
+			time.Sleep(time.Duration(len(task.moves)) * r.simulator.opts.CommandDuration)
+		}
+
+		r.mu.Lock()
+		task.running, task.finished = false, true
+		if err == nil {
+			r.state = state
+			r.notify()
+		}
+		r.mu.Unlock()
+		if err != nil {
+			task.err <- err
+		} else {
+			task.position <- state
+		}
+	}
+}
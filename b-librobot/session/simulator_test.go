@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+var _ librobot.Warehouse = (*Simulator)(nil)
+
+func TestSimulator(t *testing.T) {
+	t.Run("test replay of recording", func(t *testing.T) {
+		var entries []Entry
+		for _, entry := range record(t, &fakeRobot{size: 4}) {
+			if entry.Kind != KindCrate {
+				entries = append(entries, entry)
+			}
+		}
+		simulator := NewSimulator(entries, SimulatorOptions{Size: 4})
+		report := (&Replayer{Robot: simulator.Robot, Timeout: time.Second}).Replay(context.Background(), entries)
+		if report.Divergence != nil {
+			t.Fatalf("unexpected divergence; got: %v, want: nil", report.Divergence)
+		}
+		if report.Tasks != 2 || report.Outcomes != 2 {
+			t.Errorf("unexpected report; got: %+v, want: 2 tasks, 2 outcomes", report)
+		}
+	})
+
+	t.Run("test robots of recording", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{
+			{Kind: KindRobot, Robot: "r2", State: &Position{X: 3, Y: 4}},
+			{Kind: KindRobot, Robot: "r1", State: &Position{X: 1, Y: 2}},
+			{Kind: KindRobot, Robot: "r1", State: &Position{X: 5, Y: 5}},
+		}, SimulatorOptions{})
+		robots := simulator.Robots()
+		if len(robots) != 2 || robots[0].CurrentState() != (librobot.RobotState{X: 1, Y: 2}) || robots[1].CurrentState() != (librobot.RobotState{X: 3, Y: 4}) {
+			t.Errorf("unexpected robots; got: %v, want: r1 at (1, 2), r2 at (3, 4)", robots)
+		}
+		if _, err := simulator.Robot("r3"); codeOf(err) != "robot-not-found" {
+			t.Errorf("unexpected error; got: %v, want: robot-not-found", err)
+		}
+	})
+
+	t.Run("test out of bounds task does not move the robot", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{X: 0, Y: 8}}}, SimulatorOptions{})
+		robot, _ := simulator.Robot("r1")
+		_, _, errs := robot.EnqueueTask("N N")
+		if err := <-errs; codeOf(err) != "out-of-bounds" {
+			t.Errorf("unexpected error; got: %v, want: out-of-bounds", err)
+		}
+		if state := robot.CurrentState(); state != (librobot.RobotState{X: 0, Y: 8}) {
+			t.Errorf("unexpected state; got: %v, want: (0, 8)", state)
+		}
+	})
+
+	t.Run("test groups are executed", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{}}}, SimulatorOptions{})
+		robot, _ := simulator.Robot("r1")
+		_, position, errs := robot.EnqueueTask("2(N E) N")
+		select {
+		case state := <-position:
+			if state != (librobot.RobotState{X: 2, Y: 3}) {
+				t.Errorf("unexpected state; got: %v, want: (2, 3)", state)
+			}
+		case err := <-errs:
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+	})
+
+	t.Run("test invalid command sequences are rejected", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{}}}, SimulatorOptions{})
+		robot, _ := simulator.Robot("r1")
+		for _, commands := range []string{"NE", "2(N E", "# only comment"} {
+			if taskID, _, errs := robot.EnqueueTask(commands); taskID != "" || codeOf(<-errs) != "invalid-command-sequence" {
+				t.Errorf("task %q should be rejected; got: %v", commands, taskID)
+			}
+		}
+
+		_, _, errs := robot.EnqueueTask("N E NE S")
+		var cmdErr *cmdlang.Error
+		if err := <-errs; !errors.As(err, &cmdErr) || cmdErr.Token != "NE" {
+			t.Errorf("error should locate the offending token; got: %v", err)
+		}
+	})
+
+	t.Run("test watch", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{}}}, SimulatorOptions{})
+		ctx, cancel := context.WithCancel(context.Background())
+		states, err := simulator.Watch(ctx, "r1")
+		if err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+		robot, _ := simulator.Robot("r1")
+		robot.EnqueueTask("N E")
+		if state := <-states; state != (librobot.RobotState{X: 1, Y: 1}) {
+			t.Errorf("unexpected state; got: %v, want: (1, 1)", state)
+		}
+		cancel()
+		if _, ok := <-states; ok {
+			t.Errorf("channel should be closed once the context is done")
+		}
+		if _, err := simulator.Watch(ctx, "r2"); codeOf(err) != "robot-not-found" {
+			t.Errorf("unexpected error; got: %v, want: robot-not-found", err)
+		}
+	})
+
+	t.Run("test cancel of queued task", func(t *testing.T) {
+		simulator := NewSimulator([]Entry{{Kind: KindRobot, Robot: "r1", State: &Position{}}}, SimulatorOptions{CommandDuration: 50 * time.Millisecond})
+		robot, _ := simulator.Robot("r1")
+		running, position, _ := robot.EnqueueTask("N")
+		queued, _, _ := robot.EnqueueTask("E")
+		for started := false; !started; time.Sleep(time.Millisecond) {
+			r := robot.(*simulatedRobot)
+			r.mu.Lock()
+			started = r.tasks[running].running
+			r.mu.Unlock()
+		}
+
+		if err := robot.CancelTask(running); codeOf(err) != "task-running" {
+			t.Errorf("unexpected error; got: %v, want: task-running", err)
+		}
+		if err := robot.CancelTask(queued); err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+		if state := <-position; state != (librobot.RobotState{X: 0, Y: 1}) {
+			t.Errorf("unexpected position; got: %v, want: (0, 1)", state)
+		}
+		if err := robot.CancelTask(running); codeOf(err) != "task-finished" {
+			t.Errorf("unexpected error; got: %v, want: task-finished", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if state := robot.CurrentState(); state != (librobot.RobotState{X: 0, Y: 1}) {
+			t.Errorf("cancelled task should not be executed; got: %v, want: (0, 1)", state)
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// cellSize is the size of a cell of the grid of exported animations, in pixels.
+const cellSize = 40
+
+// margin is the margin around the grid of exported SVG animations, in pixels; it fits the axis labels.
+const margin = 30
+
+// hold is the time the final state of a session is shown before an exported animation loops.
+const hold = 2 * time.Second
+
+// robotColors are the colors of the robots of exported animations, in the order the robots were first used.
+var robotColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#9467bd", "#ff7f0e", "#17becf", "#e377c2", "#7f7f7f"}
+
+// crateColor is the color of crates of exported animations.
+const crateColor = "#a0522d"
+
+// robotLabel returns the label of the i-th robot of an animation: `1`-`9`, then `A`-`Z`.
+func robotLabel(i int) string {
+	const labels = "123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	if i < len(labels) {
+		return labels[i : i+1]
+	}
+	return "?"
+}
+
+// WriteSVG renders the timeline of a session as an animated SVG image, looping indefinitely.
+//
+// Robots are labelled circles moving across the grid (north is up), filled with a crate while they carry one; crates
+// are squares. A legend below the grid maps the labels to the IDs of the robots. The animation plays in real time: one
+// second of the session is one second of the animation.
+func WriteSVG(w io.Writer, t *Timeline) error {
+	b := bufio.NewWriter(w)
+	size := int(t.Size)
+	grid := size * cellSize
+	legend := (len(t.Robots) + 3) / 4 * 20
+	width, height := grid+2*margin, grid+2*margin+legend
+	total := t.Duration + hold
+	dur := seconds(total)
+
+	// the center of a cell
+	center := func(x uint, y uint) (int, int) {
+		return margin + int(x)*cellSize + cellSize/2, margin + (size-1-int(y))*cellSize + cellSize/2
+	}
+	keyTime := func(at time.Duration) string {
+		return fmt.Sprintf("%.4f", float64(at)/float64(total))
+	}
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`+"\n", width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+
+	// grid and axes
+	fmt.Fprintf(b, `<g stroke="#ccc">`+"\n")
+	for i := 0; i <= size; i++ {
+		fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d"/>`+"\n", margin+i*cellSize, margin, margin+i*cellSize, margin+grid)
+		fmt.Fprintf(b, `<line x1="%d" y1="%d" x2="%d" y2="%d"/>`+"\n", margin, margin+i*cellSize, margin+grid, margin+i*cellSize)
+	}
+	fmt.Fprintf(b, "</g>\n")
+	fmt.Fprintf(b, `<g font-size="12" fill="#666" text-anchor="middle">`+"\n")
+	for i := 0; i < size; i++ {
+		x, y := center(uint(i), uint(i))
+		fmt.Fprintf(b, `<text x="%d" y="%d">%d</text>`+"\n", x, margin+grid+16, i)
+		fmt.Fprintf(b, `<text x="%d" y="%d">%d</text>`+"\n", margin/2, y+4, i)
+	}
+	fmt.Fprintf(b, "</g>\n")
+
+	// crates, shown from when they were added until they were removed
+	for _, crate := range t.Crates {
+		x, y := center(crate.X, crate.Y)
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" opacity="0">`, x-cellSize/3, y-cellSize/3, 2*cellSize/3, 2*cellSize/3, crateColor)
+		fmt.Fprintf(b, `<animate attributeName="opacity" values="0;1;0" keyTimes="0;%s;%s" calcMode="discrete" dur="%s" repeatCount="indefinite"/></rect>`+"\n",
+			keyTime(crate.From), keyTime(crate.To), dur)
+	}
+
+	// robots, moving linearly between the keys of their tracks
+	for i, track := range t.Robots {
+		var translations, carrying, keyTimes []string
+		add := func(at time.Duration, state librobot.RobotState) {
+			x, y := center(state.X, state.Y)
+			translations = append(translations, fmt.Sprintf("%d %d", x, y))
+			carrying = append(carrying, map[bool]string{false: "0", true: "1"}[state.HasCrate])
+			keyTimes = append(keyTimes, keyTime(at))
+		}
+		add(0, track.Keys[0].State)
+		for _, key := range track.Keys {
+			add(key.At, key.State)
+		}
+		add(total, track.Keys[len(track.Keys)-1].State)
+
+		color := robotColors[i%len(robotColors)]
+		fmt.Fprintf(b, `<g transform="translate(%s)">`+"\n", translations[0])
+		fmt.Fprintf(b, `<animateTransform attributeName="transform" type="translate" values="%s" keyTimes="%s" dur="%s" repeatCount="indefinite"/>`+"\n",
+			strings.Join(translations, ";"), strings.Join(keyTimes, ";"), dur)
+		fmt.Fprintf(b, `<circle r="%d" fill="%s"/>`+"\n", cellSize*2/5, color)
+		fmt.Fprintf(b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" opacity="%s">`, -cellSize/5, -cellSize/5, 2*cellSize/5, 2*cellSize/5, crateColor, carrying[0])
+		fmt.Fprintf(b, `<animate attributeName="opacity" values="%s" keyTimes="%s" calcMode="discrete" dur="%s" repeatCount="indefinite"/></rect>`+"\n",
+			strings.Join(carrying, ";"), strings.Join(keyTimes, ";"), dur)
+		fmt.Fprintf(b, `<text y="5" font-size="14" font-weight="bold" fill="white" text-anchor="middle">%s</text>`+"\n", robotLabel(i))
+		fmt.Fprintf(b, "</g>\n")
+	}
+
+	// legend
+	for i, track := range t.Robots {
+		x, y := margin+(i%4)*(grid/4), margin+grid+margin+(i/4)*20
+		fmt.Fprintf(b, `<circle cx="%d" cy="%d" r="6" fill="%s"/>`, x+6, y-4, robotColors[i%len(robotColors)])
+		fmt.Fprintf(b, `<text x="%d" y="%d" font-size="12">%s=%s</text>`+"\n", x+16, y, robotLabel(i), html.EscapeString(track.Robot))
+	}
+
+	fmt.Fprintf(b, "</svg>\n")
+	return b.Flush()
+}
+
+// seconds formats a duration as an SVG clock value, e.g. `1.5s`.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
@@ -0,0 +1,239 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// DefaultGridSize is the default size of the (square) grid of a warehouse.
+const DefaultGridSize = 10
+
+// DefaultCommandDuration is the duration of the commands of tasks whose duration cannot be derived from the recording,
+// e.g. of sessions recorded against a server performing commands instantly.
+const DefaultCommandDuration = 250 * time.Millisecond
+
+// TimelineOptions configures how the history of a session is reconstructed from a recording.
+type TimelineOptions struct {
+	// Size is the size of the (square) grid of the warehouse; DefaultGridSize if zero.
+	Size uint
+	// CommandDuration is the time a robot takes to perform each command. If zero, the duration of the commands of each
+	// task is derived from the recording: the time between the start of the task and its outcome, divided by the number
+	// of commands.
+	CommandDuration time.Duration
+}
+
+// Timeline is the history of the robots and crates of a recorded session.
+type Timeline struct {
+	Size     uint
+	Duration time.Duration // Time of the last event of the session.
+	Robots   []Track       // Tracks of the robots, in the order the robots were first used.
+	Crates   []CrateSpan
+}
+
+// Track is the history of the state of a robot.
+type Track struct {
+	Robot string
+	// Keys are the states of the robot in chronological order; the robot moves linearly between consecutive keys, and
+	// is in the state of the first key before it, and of the last key after it.
+	Keys []Key
+}
+
+// Key is the state of a robot at a time of the session.
+type Key struct {
+	At    time.Duration
+	State librobot.RobotState
+}
+
+// CrateSpan is the time a crate was in a cell.
+type CrateSpan struct {
+	X, Y uint
+	From time.Duration
+	To   time.Duration // Duration of the timeline if the crate was not removed.
+}
+
+// NewTimeline reconstructs the history of a session from its recording.
+//
+// The moves of each task are simulated from the recorded state of its robot, one cell per command; the recorded outcome
+// of successful tasks is authoritative. Commands are executed once the previous task of the robot is done. Robots of
+// failed tasks do not move: the server checks the route of a task before moving its robot, so tasks failing
+// `out-of-bounds` leave it in place, as do cancelled tasks; robots of tasks failing otherwise (e.g. a collision) are
+// assumed not to have moved.
+//
+// Commands are compiled with the command language of the server (package cmdlang), including repeat counts and groups.
+func NewTimeline(entries []Entry, opts TimelineOptions) *Timeline {
+	if opts.Size == 0 {
+		opts.Size = DefaultGridSize
+	}
+	t := &Timeline{Size: opts.Size}
+
+	type robot struct {
+		track int // index of the track of the robot
+		state librobot.RobotState
+		free  time.Duration // end of the last move of the robot
+	}
+	robots := make(map[string]*robot)
+	queued := make(map[string]Entry) // task entries by task ID
+	open := make(map[[2]uint]int)    // crates in a cell: index of their span
+
+	for _, entry := range entries {
+		at := time.Duration(entry.At) * time.Millisecond
+		if at > t.Duration {
+			t.Duration = at
+		}
+
+		switch entry.Kind {
+		case KindRobot:
+			if _, ok := robots[entry.Robot]; ok || entry.State == nil {
+				continue
+			}
+			state := entry.State.robotState()
+			t.Robots = append(t.Robots, Track{Robot: entry.Robot, Keys: []Key{{At: at, State: state}}})
+			robots[entry.Robot] = &robot{track: len(t.Robots) - 1, state: state, free: at}
+
+		case KindTask:
+			if entry.Error == "" {
+				queued[entry.Task] = entry
+			}
+
+		case KindCrate:
+			if entry.Error != "" || entry.State == nil {
+				continue
+			}
+			cell := [2]uint{entry.State.X, entry.State.Y}
+			i, ok := open[cell]
+			switch {
+			case entry.Op == CrateAdd && !ok:
+				open[cell] = len(t.Crates)
+				t.Crates = append(t.Crates, CrateSpan{X: cell[0], Y: cell[1], From: at, To: -1})
+			case entry.Op == CrateDel && ok:
+				t.Crates[i].To = at
+				delete(open, cell)
+			}
+
+		case KindOutcome:
+			task, ok := queued[entry.Task]
+			r := robots[entry.Robot]
+			if !ok || r == nil {
+				continue
+			}
+			states := simulate(r.state, task.Commands, entry, opts.Size)
+			if len(states) == 0 {
+				continue
+			}
+
+			start := time.Duration(task.At) * time.Millisecond
+			if r.free > start {
+				start = r.free
+			}
+			d := opts.CommandDuration
+			if d == 0 {
+				d = (at - start) / time.Duration(len(states))
+			}
+			if d <= 0 {
+				d = DefaultCommandDuration
+			}
+
+			track := &t.Robots[r.track]
+			for i, state := range states {
+				from := start + time.Duration(i)*d
+				track.Keys = append(track.Keys, Key{At: from, State: r.state}, Key{At: from + d, State: state})
+				r.state = state
+			}
+			r.free = start + time.Duration(len(states))*d
+			if r.free > t.Duration {
+				t.Duration = r.free
+			}
+		}
+	}
+
+	for i := range t.Crates {
+		if t.Crates[i].To < 0 {
+			t.Crates[i].To = t.Duration
+		}
+	}
+	return t
+}
+
+// robotState returns the robot state of a position.
+func (p *Position) robotState() librobot.RobotState {
+	return librobot.RobotState{X: p.X, Y: p.Y, HasCrate: p.HasCrate}
+}
+
+// simulate returns the states a robot moves through performing the commands of a task with a recorded outcome; failed
+// tasks return no states, as robots do not move when their route fails (e.g. `out-of-bounds`) or when cancelled.
+func simulate(state librobot.RobotState, commands string, outcome Entry, size uint) []librobot.RobotState {
+	if outcome.Error != "" || outcome.State == nil {
+		return nil
+	}
+
+	var states []librobot.RobotState
+	if moves, err := cmdlang.Compile(commands); err == nil {
+		for _, command := range moves {
+			next, ok := move(state, command, size)
+			if !ok {
+				break
+			}
+			state = next
+			states = append(states, state)
+		}
+	}
+
+	// the recorded outcome is authoritative, e.g. for replanned routes
+	if final := outcome.State.robotState(); len(states) == 0 || states[len(states)-1] != final {
+		states = append(states, final)
+	}
+	return states
+}
+
+// move returns the state of a robot after a command; returns false if the robot would leave the grid.
+func move(state librobot.RobotState, command cmdlang.Command, size uint) (librobot.RobotState, bool) {
+	switch command {
+	case 'N':
+		state.Y++
+	case 'S':
+		state.Y--
+	case 'E':
+		state.X++
+	case 'W':
+		state.X--
+	}
+	// moving south or west of the grid underflows, beyond the grid too
+	return state, state.X < size && state.Y < size
+}
+
+// StateAt returns the state of a robot at a time of the session: the state of the last key at or before the time.
+func (tr Track) StateAt(at time.Duration) librobot.RobotState {
+	i := sort.Search(len(tr.Keys), func(i int) bool { return tr.Keys[i].At > at })
+	if i == 0 {
+		return tr.Keys[0].State
+	}
+	return tr.Keys[i-1].State
+}
+
+// Times returns the times at which the state of the warehouse changes, in order, starting with 0.
+func (t *Timeline) Times() []time.Duration {
+	seen := map[time.Duration]bool{0: true}
+	times := []time.Duration{0}
+	add := func(at time.Duration) {
+		if !seen[at] {
+			seen[at] = true
+			times = append(times, at)
+		}
+	}
+	for _, track := range t.Robots {
+		for i, key := range track.Keys {
+			if i == 0 || key.State != track.Keys[i-1].State {
+				add(key.At)
+			}
+		}
+	}
+	for _, crate := range t.Crates {
+		add(crate.From)
+		add(crate.To)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
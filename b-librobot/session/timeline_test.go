@@ -0,0 +1,178 @@
+package session
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/gif"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// recording is a session of two robots: r1 moves `N E2` in 300ms, then fails moving out of the grid without moving; r2
+// is cancelled
+var recording = []Entry{
+	{At: 0, Kind: KindRobot, Robot: "r1", State: &Position{X: 0, Y: 0}},
+	{At: 0, Kind: KindRobot, Robot: "r2", State: &Position{X: 4, Y: 4}},
+	{At: 0, Kind: KindTask, Robot: "r1", Task: "t1", Commands: "N E2"},
+	{At: 100, Kind: KindCrate, Op: CrateAdd, State: &Position{X: 3, Y: 3}},
+	{At: 200, Kind: KindTask, Robot: "r1", Task: "t2", Commands: "N N N N N N N N N N"},
+	{At: 250, Kind: KindTask, Robot: "r2", Task: "t3", Commands: "S"},
+	{At: 260, Kind: KindCancel, Robot: "r2", Task: "t3"},
+	{At: 260, Kind: KindOutcome, Robot: "r2", Task: "t3", Error: "cancelled", Code: "task-cancelled"},
+	{At: 300, Kind: KindOutcome, Robot: "r1", Task: "t1", State: &Position{X: 2, Y: 1}},
+	{At: 400, Kind: KindCrate, Op: CrateDel, State: &Position{X: 3, Y: 3}},
+	{At: 1200, Kind: KindOutcome, Robot: "r1", Task: "t2", Error: "out of bounds", Code: "out-of-bounds"},
+}
+
+func TestTimeline(t *testing.T) {
+	tl := NewTimeline(recording, TimelineOptions{Size: 5})
+
+	if len(tl.Robots) != 2 || tl.Robots[0].Robot != "r1" || tl.Robots[1].Robot != "r2" {
+		t.Fatalf("unexpected tracks; got: %+v", tl.Robots)
+	}
+	var got []string
+	for _, key := range tl.Robots[0].Keys {
+		got = append(got, key.At.String()+"="+(&Position{X: key.State.X, Y: key.State.Y}).String())
+	}
+	// t1 takes 100ms per command; t2 is out of bounds, so r1 stays at (2, 1)
+	want := "0s=(0, 0) 0s=(0, 0) 100ms=(0, 1) 100ms=(0, 1) 200ms=(1, 1) 200ms=(1, 1) 300ms=(2, 1)"
+	if strings.Join(got, " ") != want {
+		t.Errorf("unexpected keys; got: %v, want: %v", strings.Join(got, " "), want)
+	}
+	if keys := tl.Robots[1].Keys; len(keys) != 1 {
+		t.Errorf("unexpected keys of cancelled robot; got: %+v, want: 1 key", keys)
+	}
+	if len(tl.Crates) != 1 || tl.Crates[0].From != 100*time.Millisecond || tl.Crates[0].To != 400*time.Millisecond {
+		t.Errorf("unexpected crates; got: %+v, want: (3, 3) from 100ms to 400ms", tl.Crates)
+	}
+	if tl.Duration != 1200*time.Millisecond {
+		t.Errorf("unexpected duration; got: %v, want: %v", tl.Duration, 1200*time.Millisecond)
+	}
+
+	if got := tl.Robots[0].StateAt(250 * time.Millisecond); got != (librobot.RobotState{X: 1, Y: 1}) {
+		t.Errorf("unexpected state; got: %+v, want: (1, 1)", got)
+	}
+	if got := tl.Robots[0].StateAt(time.Hour); got != (librobot.RobotState{X: 2, Y: 1}) {
+		t.Errorf("unexpected state; got: %+v, want: (2, 1)", got)
+	}
+
+	t.Run("test command duration", func(t *testing.T) {
+		tl := NewTimeline(recording, TimelineOptions{Size: 5, CommandDuration: time.Second})
+		if keys := tl.Robots[0].Keys; keys[len(keys)-1].At != 3*time.Second {
+			t.Errorf("unexpected end; got: %v, want: %v", keys[len(keys)-1].At, 3*time.Second)
+		}
+	})
+
+	t.Run("test times", func(t *testing.T) {
+		var got []string
+		for _, at := range tl.Times() {
+			got = append(got, at.String())
+		}
+		if want := "0s 100ms 200ms 300ms 400ms"; strings.Join(got, " ") != want {
+			t.Errorf("unexpected times; got: %v, want: %v", strings.Join(got, " "), want)
+		}
+	})
+}
+
+func TestSimulate(t *testing.T) {
+	for _, tt := range []struct {
+		commands string
+		outcome  Entry
+		want     string
+	}{
+		{"N E S W", Entry{State: &Position{}}, "(0, 1) (1, 1) (1, 0) (0, 0)"},
+		{"N3 # comment", Entry{State: &Position{Y: 3}}, "(0, 1) (0, 2) (0, 3)"},
+		{"3N", Entry{State: &Position{Y: 3}}, "(0, 1) (0, 2) (0, 3)"},
+		{"2(N E)", Entry{State: &Position{X: 2, Y: 2}}, "(0, 1) (1, 1) (1, 2) (2, 2)"},
+		{"N C\nE", Entry{State: &Position{X: 1, Y: 1}}, "(0, 1) (0, 1) (1, 1)"},
+		{"N E", Entry{State: &Position{X: 2}}, "(0, 1) (1, 1) (2, 0)"},
+		{"NE", Entry{State: &Position{X: 1, Y: 1}}, "(1, 1)"},
+		{"N N N N N N", Entry{Error: "out of bounds", Code: "out-of-bounds"}, ""},
+		{"S", Entry{Error: "cancelled", Code: "task-cancelled"}, ""},
+	} {
+		var got []string
+		for _, state := range simulate(librobot.RobotState{}, tt.commands, tt.outcome, 5) {
+			got = append(got, fmt.Sprintf("(%d, %d)", state.X, state.Y))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("unexpected states of %q; got: %v, want: %v", tt.commands, strings.Join(got, " "), tt.want)
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, NewTimeline(recording, TimelineOptions{Size: 5})); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	svg := buf.String()
+
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG; got: %v", err)
+		}
+	}
+	for _, want := range []string{
+		`dur="3.200s"`,   // session of 1.2s, and the final state held for 2s
+		`>1=r1</text>`,   // legend
+		`>2=r2</text>`,   // legend
+		`values="0;1;0"`, // crate
+		`keyTimes="0;0.0312;0.1250"`,
+		`values="50 210;50 210;50 210;50 170;`, // r1 moving north from (0, 0)
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %q; got:\n%v", want, svg)
+		}
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGIF(&buf, NewTimeline(recording, TimelineOptions{Size: 5})); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("invalid GIF; got: %v", err)
+	}
+	if len(anim.Image) != 5 {
+		t.Errorf("unexpected frames; got: %v, want: %v", len(anim.Image), 5)
+	}
+	total := 0
+	for _, delay := range anim.Delay {
+		total += delay
+	}
+	if total != 320 {
+		t.Errorf("unexpected duration; got: %v, want: %v", total, 320)
+	}
+	if size := anim.Image[0].Bounds().Size(); size.X != 5*cellSize+1 || size.Y != 5*cellSize+1 {
+		t.Errorf("unexpected size; got: %v", size)
+	}
+
+	// r1 is at (1, 1) in the frame at 200ms, r2 at (4, 4) and the crate at (3, 3)
+	frame := anim.Image[2]
+	for _, c := range []struct {
+		x, y  uint
+		color string
+	}{{1, 1, robotColors[0]}, {4, 4, robotColors[1]}, {3, 3, crateColor}, {0, 0, "#ffffff"}} {
+		px, py := int(c.x)*cellSize+cellSize/2, (4-int(c.y))*cellSize+cellSize/2
+		if got, want := frame.At(px, py), parseColor(c.color); !sameColor(got, want) {
+			t.Errorf("unexpected color at (%d, %d); got: %v, want: %v", c.x, c.y, got, want)
+		}
+	}
+}
+
+func sameColor(a color.Color, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
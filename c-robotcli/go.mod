@@ -0,0 +1,13 @@
+module github.com/zees-dev/robot-challenge/c-robotcli
+
+go 1.16
+
+require (
+	github.com/gdamore/tcell/v2 v2.5.4
+	github.com/mattn/go-runewidth v0.0.14
+	github.com/peterh/liner v1.2.2
+	github.com/zees-dev/robot-challenge/b-librobot v0.0.0
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+)
+
+replace github.com/zees-dev/robot-challenge/b-librobot => ../b-librobot
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/peterh/liner"
+	"github.com/zees-dev/robot-challenge/b-librobot/client"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+	"github.com/zees-dev/robot-challenge/b-librobot/session"
+	"golang.org/x/term"
+)
+
+// defaultGridSize is the size of the grid of the warehouse of the robot server
+const defaultGridSize = 10
+
+// defaultRobots are the robots of the simulated warehouse: the robot of the server by default
+const defaultRobots = "r1@0,0"
+
+// usage is the usage of the CLI, followed by the flags of the command
+const usage = `Usage:
+  robotcli [--server URL] [flags]                 interactive REPL
+  robotcli [--server URL] --tui [flags]           full-screen terminal UI
+  robotcli run [--server URL] [flags] <scenario>  run a scenario file non-interactively
+  robotcli replay [--server URL] [flags] <file>   replay a recorded session, reporting the first divergence
+  robotcli export [flags] <file>                  render a recorded session as an animated SVG or GIF
+
+Robots are simulated in-process unless --server is given.
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the CLI with command line arguments; returns the exit code
+// - 0 on success, 1 if the REPL fails, a scenario fails or a replay diverges, 2 on invalid arguments, scenarios or recordings
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "run" {
+		return runScenario(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "replay" {
+		return runReplay(args[1:], stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "export" {
+		return runExport(args[1:], stdout, stderr)
+	}
+
+	flags, connect := newFlagSet("robotcli", stderr)
+	fullScreen := flags.Bool("tui", false, "full-screen terminal UI instead of the REPL")
+	history := flags.String("history", defaultHistoryFile(), "file the command history of the REPL is persisted to (empty disables persistence)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	w, closeWarehouse, err := connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 2
+	}
+	defer closeWarehouse()
+
+	size := flags.Lookup("size").Value.(flag.Getter).Get().(uint)
+	r := newREPL(context.Background(), w, size, stdout)
+	switch {
+	case *fullScreen:
+		err = runTUI(r)
+	case stdin == os.Stdin && term.IsTerminal(int(os.Stdin.Fd())) && liner.TerminalSupported():
+		err = runTerminal(r, *history)
+	default:
+		err = r.run(stdin)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runTUI runs the terminal UI of a REPL on the terminal
+func runTUI(r *repl) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	if err := screen.Init(); err != nil {
+		return fmt.Errorf("failed to initialize terminal: %w", err)
+	}
+	defer screen.Fini()
+	return newTUI(screen, r).run()
+}
+
+// runTerminal runs a REPL on the terminal, with line editing, persistent history and tab completion
+func runTerminal(r *repl, history string) error {
+	// Ctrl-C is read as a key by the line editor; terminals the line editor does not support send SIGINT instead
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-interrupts:
+				r.interrupt()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	terminal := newTerminalReader(history, r.complete)
+	err := r.runLines(terminal)
+	if closeErr := terminal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// runScenario runs the scenario file given as argument, writing the report of each step and a summary to stdout
+func runScenario(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, connect := newFlagSet("robotcli run", stderr)
+	timeout := flags.Duration("timeout", time.Minute, "maximum time a step waits for tasks to be executed")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "robotcli: run requires a scenario file\n")
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 2
+	}
+	steps, err := parseScenario(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: invalid scenario %s:\n%v\n", name, err)
+		return 2
+	}
+
+	w, closeWarehouse, err := connect()
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 2
+	}
+	defer closeWarehouse()
+
+	res := (&scenarioRunner{warehouse: w, timeout: *timeout, out: stdout}).run(context.Background(), steps)
+	res.summary(stdout, name)
+	if res.err != nil {
+		return 1
+	}
+	return 0
+}
+
+// runReplay replays the recorded session given as argument, writing a summary (and the first divergence) to stdout
+func runReplay(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags, connect := newFlagSet("robotcli replay", stderr)
+	timeout := flags.Duration("timeout", session.DefaultTimeout, "maximum time to wait for the outcome of a task")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "robotcli: replay requires a recorded session\n")
+		flags.Usage()
+		return 2
+	}
+
+	name := flags.Arg(0)
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 2
+	}
+	entries, err := session.Read(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: invalid recording %s: %v\n", name, err)
+		return 2
+	}
+
+	ctx := context.Background()
+	replayer := &session.Replayer{Timeout: *timeout}
+	if flags.Lookup("server").Value.String() == "" {
+		// the robots of the recording are simulated in-process, so no server is needed
+		if flags.Lookup("record").Value.String() != "" {
+			fmt.Fprintf(stderr, "robotcli: --record requires --server\n")
+			return 2
+		}
+		size := flags.Lookup("size").Value.(flag.Getter).Get().(uint)
+		commandDuration := flags.Lookup("command-duration").Value.(flag.Getter).Get().(time.Duration)
+		simulator := session.NewSimulator(entries, session.SimulatorOptions{Size: size, CommandDuration: commandDuration})
+		replayer.Robot = simulator.Robot
+	} else {
+		w, closeWarehouse, err := connect()
+		if err != nil {
+			fmt.Fprintf(stderr, "robotcli: %v\n", err)
+			return 2
+		}
+		defer closeWarehouse()
+		replayer.Robot = func(id string) (librobot.Robot, error) { return w.Robot(ctx, id) }
+	}
+	report := replayer.Replay(ctx, entries)
+	status := "PASS"
+	if report.Divergence != nil {
+		status = "FAIL"
+		fmt.Fprintf(stdout, "diverged at %v\n", report.Divergence)
+	}
+	fmt.Fprintf(stdout, "%s %s: %d of %d entries, %d tasks, %d outcomes matched in %s\n",
+		status, name, report.Entries, len(entries), report.Tasks, report.Outcomes, report.Duration.Round(time.Millisecond))
+	if report.Divergence != nil {
+		return 1
+	}
+	return 0
+}
+
+// runExport renders the recorded session given as argument as an animated SVG or GIF
+// - the format is the extension of the output file unless set; SVG is written to stdout by default
+func runExport(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("robotcli export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	size := flags.Uint("size", defaultGridSize, "size of the (square) grid of the warehouse")
+	commandDuration := flags.Duration("command-duration", 0, "time a robot takes to perform each command (derived from the recording if 0)")
+	format := flags.String("format", "", "format of the animation: svg or gif (default: extension of --output, or svg)")
+	output := flags.String("output", "", "file the animation is written to (default: stdout)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(stderr, "robotcli: export requires a recorded session\n")
+		flags.Usage()
+		return 2
+	}
+	if *format == "" {
+		*format = "svg"
+		if strings.EqualFold(filepath.Ext(*output), ".gif") {
+			*format = "gif"
+		}
+	}
+	write := map[string]func(io.Writer, *session.Timeline) error{"svg": session.WriteSVG, "gif": session.WriteGIF}[*format]
+	if write == nil {
+		fmt.Fprintf(stderr, "robotcli: invalid format '%s'; one of 'svg' or 'gif'\n", *format)
+		return 2
+	}
+
+	name := flags.Arg(0)
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: %v\n", err)
+		return 2
+	}
+	entries, err := session.Read(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(stderr, "robotcli: invalid recording %s: %v\n", name, err)
+		return 2
+	}
+
+	timeline := session.NewTimeline(entries, session.TimelineOptions{Size: *size, CommandDuration: *commandDuration})
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "robotcli: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := write(out, timeline); err != nil {
+		fmt.Fprintf(stderr, "robotcli: failed to export %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// newFlagSet creates the flag set of a command, including the flags selecting the warehouse to connect to
+// - the warehouse is the in-process simulator of the simulator library, or the robot server given by --server
+func newFlagSet(name string, stderr io.Writer) (*flag.FlagSet, func() (warehouse, func(), error)) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	server := flags.String("server", "", "URL of the robot server (a-restful) to operate, e.g. http://localhost:8000 (default: in-process simulator)")
+	apiKey := flags.String("api-key", "", "API key authenticating requests to the server")
+	token := flags.String("token", "", "bearer token (JWT) authenticating requests to the server")
+	record := flags.String("record", "", "file the session (tasks, cancellations and their outcomes) is recorded to, for replay")
+	size := flags.Uint("size", defaultGridSize, "size of the (square) grid of the warehouse; simulated without --server")
+	commandDuration := flags.Duration("command-duration", 0, "time a simulated robot takes to perform each command (without --server)")
+	robots := flags.String("robots", defaultRobots, "robots of the simulated warehouse (without --server): `id[@x,y]` separated by spaces, e.g. \"r1 r2@4,4\"")
+	return flags, func() (warehouse, func(), error) {
+		var w warehouse
+		closeWarehouse := func() {}
+		var err error
+		if *server == "" {
+			w, err = simulate(*robots, session.SimulatorOptions{Size: *size, CommandDuration: *commandDuration})
+		} else {
+			w, closeWarehouse, err = connect(*server, *apiKey, *token)
+		}
+		if err != nil || *record == "" {
+			return w, closeWarehouse, err
+		}
+		f, err := os.Create(*record)
+		if err != nil {
+			closeWarehouse()
+			return nil, nil, err
+		}
+		recorder := session.NewRecorder(f)
+		// the recording is closed first: the outcomes of tasks failed by closing the warehouse are not part of the session
+		return recordingWarehouse{w, recorder}, func() {
+			if err := recorder.Err(); err != nil {
+				fmt.Fprintf(stderr, "robotcli: failed to record session to %s: %v\n", *record, err)
+			}
+			f.Close()
+			closeWarehouse()
+		}, nil
+	}
+}
+
+// simulate creates an in-process simulated warehouse with robots given as `id[@x,y]` separated by spaces
+// - robots are placed at (0, 0) unless positioned, like the robot of the server by default
+func simulate(robots string, opts session.SimulatorOptions) (warehouse, error) {
+	var entries []session.Entry
+	var ids []string
+	seen := make(map[string]bool)
+	for _, robot := range strings.Fields(robots) {
+		id, cell := robot, ""
+		if i := strings.IndexByte(robot, '@'); i >= 0 {
+			id, cell = robot[:i], robot[i+1:]
+		}
+		var x, y uint
+		if cell != "" {
+			var err error
+			if x, y, err = parseCell(cell); err != nil {
+				return nil, fmt.Errorf("invalid robot '%s' of --robots: %v", robot, err)
+			}
+		}
+		if id == "" || seen[id] {
+			return nil, fmt.Errorf("invalid robot '%s' of --robots: the ID is empty or not unique", robot)
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		entries = append(entries, session.Entry{Kind: session.KindRobot, Robot: id, State: &session.Position{X: x, Y: y}})
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("--robots requires at least one robot")
+	}
+	sort.Strings(ids)
+	return simulatedWarehouse{session.NewSimulator(entries, opts), ids}, nil
+}
+
+// connect connects to the warehouse of a robot server
+func connect(server string, apiKey string, token string) (warehouse, func(), error) {
+	var options []client.Option
+	switch {
+	case apiKey != "" && token != "":
+		return nil, nil, errors.New("--api-key and --token are mutually exclusive")
+	case apiKey != "":
+		options = append(options, client.WithAPIKey(apiKey))
+	case token != "":
+		options = append(options, client.WithBearerToken(token))
+	}
+	c, err := client.New(server, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return remoteWarehouse{c}, func() { c.Close() }, nil
+}
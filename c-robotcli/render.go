@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// robotLabels are the single character labels of robots within the grid, in the order of the robots
+const robotLabels = "123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// robotPosition is the state of a robot identified by ID
+type robotPosition struct {
+	ID    string
+	State librobot.RobotState
+}
+
+// label returns the label of the i-th robot; robots beyond the labels available are labelled `?`
+func label(i int) byte {
+	if i < len(robotLabels) {
+		return robotLabels[i]
+	}
+	return '?'
+}
+
+// renderGrid writes the grid of a warehouse of size x size cells, followed by a legend of the robots
+// - north (Y) is up, east (X) is right; robots are labelled in the order given
+// - robots carrying a crate are marked with `*` in the legend, robots outside of the grid are only listed in the legend
+func renderGrid(w io.Writer, size uint, robots []robotPosition) {
+	cells := make([][]byte, size)
+	for y := range cells {
+		cells[y] = []byte(strings.Repeat(".", int(size)))
+	}
+	for i, robot := range robots {
+		if robot.State.X < size && robot.State.Y < size {
+			cells[robot.State.Y][robot.State.X] = label(i)
+		}
+	}
+
+	margin := len(fmt.Sprint(size - 1))
+	border := strings.Repeat(" ", margin) + " +" + strings.Repeat("-", int(size)*2+1) + "+\n"
+	var b strings.Builder
+	b.WriteString(border)
+	for y := int(size) - 1; y >= 0; y-- {
+		fmt.Fprintf(&b, "%*d |", margin, y)
+		for _, cell := range cells[y] {
+			b.WriteByte(' ')
+			b.WriteByte(cell)
+		}
+		b.WriteString(" |\n")
+	}
+	b.WriteString(border)
+	b.WriteString(strings.Repeat(" ", margin) + "  ")
+	for x := uint(0); x < size; x++ {
+		fmt.Fprintf(&b, " %d", x%10)
+	}
+	b.WriteString("\n")
+
+	legend := make([]string, len(robots))
+	for i, robot := range robots {
+		crate := ""
+		if robot.State.HasCrate {
+			crate = "*"
+		}
+		legend[i] = fmt.Sprintf("%c=%s (%d, %d)%s", label(i), robot.ID, robot.State.X, robot.State.Y, crate)
+	}
+	if len(legend) > 0 {
+		b.WriteString(strings.Join(legend, "  ") + "\n")
+	}
+	io.WriteString(w, b.String())
+}
+
+// underline renders the commands of a task rejected as an invalid command sequence, underlining the offending token with
+// carets (see cmdlang.Error.Underline); each line is indented, and terminated by a newline
+// - empty for errors which do not locate the offending token, e.g. of older servers or failed tasks
+func underline(err error, commands string, indent string) string {
+	var cmdErr *cmdlang.Error
+	if !errors.As(err, &cmdErr) {
+		return ""
+	}
+	text := cmdErr.Underline(commands)
+	if text == "" {
+		return ""
+	}
+	return indent + strings.ReplaceAll(text, "\n", "\n"+indent) + "\n"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+func TestRenderGrid(t *testing.T) {
+	t.Run("test robots within grid", func(t *testing.T) {
+		var b strings.Builder
+		renderGrid(&b, 3, []robotPosition{
+			{"r1", librobot.RobotState{X: 1, Y: 2}},
+			{"r2", librobot.RobotState{X: 0, Y: 0, HasCrate: true}},
+		})
+		want := `  +-------+
+2 | . 1 . |
+1 | . . . |
+0 | 2 . . |
+  +-------+
+    0 1 2
+1=r1 (1, 2)  2=r2 (0, 0)*
+`
+		if b.String() != want {
+			t.Errorf("unexpected grid; got:\n%v\nwant:\n%v", b.String(), want)
+		}
+	})
+
+	t.Run("test robot outside of grid", func(t *testing.T) {
+		var b strings.Builder
+		renderGrid(&b, 2, []robotPosition{{"r1", librobot.RobotState{X: 5, Y: 5}}})
+		if strings.Contains(b.String(), "| 1") || !strings.Contains(b.String(), "1=r1 (5, 5)") {
+			t.Errorf("unexpected grid; got:\n%v", b.String())
+		}
+	})
+
+	t.Run("test labels", func(t *testing.T) {
+		if got := string([]byte{label(0), label(9), label(100)}); got != "1A?" {
+			t.Errorf("unexpected labels; got: %v, want: %v", got, "1A?")
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// replCommand is a command of the REPL
+type replCommand struct {
+	name    string // empty for tasks
+	usage   string
+	summary string
+	help    string // printed by `help <command>`
+}
+
+// replCommands are the commands of the REPL, in the order they are listed by `help`
+var replCommands = []replCommand{
+	{"", "<robot> <commands>", "queue a task moving a robot, e.g. \"r1 N E2 3(N E)\"",
+		"Queues a task moving a robot by a sequence of commands. The outcome is printed once the task is executed. The\n" +
+			"robot becomes the current robot, whose task Ctrl-C cancels.\n" + cmdlang.Syntax},
+	{"cancel", "cancel <task-id>", "cancel a task queued within the session",
+		"Cancels a task queued within the session which has not been executed yet; tasks being executed cannot be\n" +
+			"cancelled. Ctrl-C cancels the oldest such task of the current robot. Task IDs are completed with Tab."},
+	{"tasks", "tasks", "list the tasks queued within the session which have not been executed yet",
+		"Lists the tasks queued within the session which have not been executed yet, in the order they were queued."},
+	{"robots", "robots", "list the robots of the warehouse (robots added since are watched too)",
+		"Lists the robots of the warehouse and their positions. Robots added to the warehouse since the CLI started\n" +
+			"are watched from then on."},
+	{"grid", "grid", "print the grid of the warehouse",
+		"Prints the grid of the warehouse; north is up. Robots are labelled 1-9, A-Z in the order of the legend."},
+	{"help", "help [command]", "print the commands, or the help of a command", "Prints the commands, or the help of a command."},
+	{"quit", "quit", "exit (also Ctrl-D)", "Exits; \"exit\" and Ctrl-D exit too. Tasks queued on the server are executed regardless."},
+}
+
+// help prints the commands, or the help of a command
+func (r *repl) help(command string) {
+	var b strings.Builder
+	if command == "" {
+		b.WriteString("Commands:\n")
+		for _, c := range replCommands {
+			fmt.Fprintf(&b, "  %-20s%s\n", c.usage, c.summary)
+		}
+		b.WriteString(`Type "help <command>" for the help of a command; Tab completes commands, robots and task IDs.` + "\n")
+		r.printf("%s", b.String())
+		return
+	}
+	for _, c := range replCommands {
+		if c.name == command || (c.name == "" && (command == "task" || command == "robot")) {
+			r.printf("usage: %s\n%s\n", c.usage, c.help)
+			return
+		}
+	}
+	r.printf("unknown command '%s'; see \"help\"\n", command)
+}
+
+// repl is a read-eval-print loop operating the robots of a warehouse
+// - the grid is printed whenever the state of a robot changes, including changes by tasks queued by other clients
+// - the outcome of a task is printed once the task is executed; tasks run in the background, so several robots can move at once
+type repl struct {
+	ctx       context.Context // watches of robots end once done
+	warehouse warehouse
+	size      uint
+
+	printGrid bool   // whether the grid is printed whenever the state of a robot changes
+	changed   func() // called whenever the state of a robot changes, e.g. to redraw a terminal UI
+
+	mu      sync.Mutex
+	out     io.Writer
+	robots  []robotPosition        // robots watched, in the order they are labelled
+	tasks   map[string]sessionTask // tasks queued within the session which have not been executed yet, by ID
+	queued  int                    // number of tasks queued within the session
+	current string                 // robot of the last task queued within the session
+}
+
+// sessionTask is a task queued within the session
+type sessionTask struct {
+	ID       string
+	Robot    string
+	Commands string
+	seq      int // order in which the task was queued
+}
+
+// newREPL creates a REPL of a warehouse of size x size cells, writing to out
+func newREPL(ctx context.Context, w warehouse, size uint, out io.Writer) *repl {
+	return &repl{ctx: ctx, warehouse: w, size: size, printGrid: true, changed: func() {}, out: out, tasks: make(map[string]sessionTask)}
+}
+
+// errInterrupted is returned by lineReader.Prompt if the prompt is interrupted (Ctrl-C)
+var errInterrupted = errors.New("interrupted")
+
+// lineReader reads the command lines of the REPL
+type lineReader interface {
+	// Prompt reads a line; returns errInterrupted if interrupted, io.EOF at the end of the input
+	Prompt(prompt string) (string, error)
+}
+
+// scannerReader reads lines of an input which is not a terminal, e.g. piped commands
+// * implements lineReader
+type scannerReader struct {
+	scanner *bufio.Scanner
+	printf  func(format string, a ...interface{})
+}
+
+// Prompt prints the prompt and reads a line
+func (s scannerReader) Prompt(prompt string) (string, error) {
+	s.printf("%s", prompt)
+	if !s.scanner.Scan() {
+		s.printf("\n")
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return s.scanner.Text(), nil
+}
+
+// run watches the robots of the warehouse, then reads commands from in until `quit` or the end of the input
+func (r *repl) run(in io.Reader) error {
+	return r.runLines(scannerReader{bufio.NewScanner(in), r.printf})
+}
+
+// runLines watches the robots of the warehouse, then executes the lines read until `quit` or the end of the input
+// - interrupting the prompt (Ctrl-C) cancels the task of the current robot rather than exiting
+func (r *repl) runLines(lines lineReader) error {
+	if err := r.watchRobots(); err != nil {
+		return fmt.Errorf("failed to list robots: %w", err)
+	}
+	r.mu.Lock()
+	renderGrid(r.out, r.size, r.robots)
+	fmt.Fprintln(r.out, `Type "help" for commands.`)
+	r.mu.Unlock()
+
+	for {
+		line, err := lines.Prompt("> ")
+		switch {
+		case err == errInterrupted:
+			r.interrupt()
+			continue
+		case err == io.EOF:
+			return nil
+		case err != nil:
+			return err
+		}
+		if quit := r.execute(line); quit {
+			return nil
+		}
+	}
+}
+
+// execute executes a command line; returns whether to quit
+func (r *repl) execute(line string) (quit bool) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case "help", "?":
+		if len(fields) > 1 {
+			r.help(fields[1])
+		} else {
+			r.help("")
+		}
+	case "quit", "exit":
+		return true
+	case "grid":
+		r.mu.Lock()
+		renderGrid(r.out, r.size, r.robots)
+		r.mu.Unlock()
+	case "robots":
+		if err := r.watchRobots(); err != nil {
+			r.printf("error: %v\n", err)
+			return false
+		}
+		r.mu.Lock()
+		for _, robot := range r.robots {
+			fmt.Fprintf(r.out, "%s (%d, %d)\n", robot.ID, robot.State.X, robot.State.Y)
+		}
+		r.mu.Unlock()
+	case "tasks":
+		_, tasks := r.snapshot()
+		for _, task := range tasks {
+			r.printf("%s %s \"%s\"\n", task.ID, task.Robot, task.Commands)
+		}
+	case "cancel":
+		if len(fields) != 2 {
+			r.printf("usage: cancel <task-id>\n")
+			return false
+		}
+		r.cancel(fields[1])
+	default:
+		if len(fields) < 2 {
+			r.printf("unknown command '%s'; usage: <robot> <commands>, or see \"help\"\n", fields[0])
+			return false
+		}
+		r.enqueue(fields[0], strings.Join(fields[1:], " "))
+	}
+	return false
+}
+
+// enqueue queues a task of a robot, printing its outcome once the task is executed
+func (r *repl) enqueue(robotID string, commands string) {
+	robot, err := r.warehouse.Robot(r.ctx, robotID)
+	if err != nil {
+		r.printf("error: %v\n", err)
+		return
+	}
+	if err := r.watch(robotID, robot.CurrentState()); err != nil {
+		r.printf("error: %v\n", err)
+		return
+	}
+
+	taskID, position, errs := robot.EnqueueTask(commands)
+	if taskID == "" {
+		err := <-errs
+		r.printf("error: %v\n%s", err, underline(err, commands, "  "))
+		return
+	}
+	r.mu.Lock()
+	r.queued++
+	r.tasks[taskID] = sessionTask{taskID, robotID, commands, r.queued}
+	r.current = robotID
+	fmt.Fprintf(r.out, "task %s queued for %s\n", taskID, robotID)
+	r.mu.Unlock()
+
+	go func() {
+		select {
+		case state := <-position:
+			r.finish(taskID, fmt.Sprintf("task %s: robot %s at (%d, %d)", taskID, robotID, state.X, state.Y))
+		case err := <-errs:
+			r.finish(taskID, err.Error()) // the errors of tasks identify the task
+		}
+	}()
+}
+
+// finish prints the outcome of an executed task of the session
+func (r *repl) finish(taskID string, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tasks[taskID]; !ok {
+		return // cancelled
+	}
+	delete(r.tasks, taskID)
+	fmt.Fprintln(r.out, outcome)
+}
+
+// cancel cancels a task queued within the session
+func (r *repl) cancel(taskID string) {
+	r.mu.Lock()
+	task, ok := r.tasks[taskID]
+	r.mu.Unlock()
+	if !ok {
+		r.printf("error: task %s is not pending; only tasks queued within the session can be cancelled\n", taskID)
+		return
+	}
+
+	robot, err := r.warehouse.Robot(r.ctx, task.Robot)
+	if err == nil {
+		err = robot.CancelTask(taskID)
+	}
+	if err != nil {
+		r.printf("error: %v\n", err)
+		return
+	}
+	r.mu.Lock()
+	delete(r.tasks, taskID)
+	fmt.Fprintf(r.out, "task %s cancelled\n", taskID)
+	r.mu.Unlock()
+}
+
+// interrupt cancels the current task of the current robot (the robot of the last task queued within the session)
+func (r *repl) interrupt() {
+	r.mu.Lock()
+	robot := r.current
+	r.mu.Unlock()
+	if robot == "" {
+		r.printf("no task queued within the session to cancel; type \"quit\" or Ctrl-D to exit\n")
+		return
+	}
+	r.cancelRobotTask(robot)
+}
+
+// cancelRobotTask cancels the current task of a robot: the oldest task queued within the session which has not been
+// executed yet; the server rejects cancelling the task if it is being executed already (`task-running`)
+func (r *repl) cancelRobotTask(robotID string) {
+	_, tasks := r.snapshot()
+	for _, task := range tasks {
+		if task.Robot == robotID {
+			r.cancel(task.ID)
+			return
+		}
+	}
+	r.printf("robot %s has no pending task queued within the session\n", robotID)
+}
+
+// complete returns the completions of a line: commands and robots, the commands of `help`, and the tasks of `cancel`
+func (r *repl) complete(line string) []string {
+	robots, tasks := r.snapshot()
+	var candidates []string
+	head, word := "", line
+	if i := strings.LastIndexByte(line, ' '); i >= 0 {
+		head, word = line[:i+1], line[i+1:]
+	}
+
+	switch fields := strings.Fields(head); {
+	case len(fields) == 0:
+		for _, c := range replCommands {
+			if c.name != "" {
+				candidates = append(candidates, c.name)
+			}
+		}
+		for _, robot := range robots {
+			candidates = append(candidates, robot.ID)
+		}
+	case len(fields) == 1 && fields[0] == "help":
+		for _, c := range replCommands {
+			if c.name != "" {
+				candidates = append(candidates, c.name)
+			}
+		}
+	case len(fields) == 1 && fields[0] == "cancel":
+		for _, task := range tasks {
+			candidates = append(candidates, task.ID)
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			completions = append(completions, head+candidate)
+		}
+	}
+	return completions
+}
+
+// watchRobots watches the robots of the warehouse which are not watched yet
+func (r *repl) watchRobots() error {
+	ids, err := r.warehouse.RobotIDs(r.ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		robot, err := r.warehouse.Robot(r.ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := r.watch(id, robot.CurrentState()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watch prints the grid whenever the state of a robot changes, unless the robot is watched already
+func (r *repl) watch(robotID string, state librobot.RobotState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, robot := range r.robots {
+		if robot.ID == robotID {
+			return nil
+		}
+	}
+
+	states, err := r.warehouse.Watch(r.ctx, robotID)
+	if err != nil {
+		return err
+	}
+	i := len(r.robots)
+	r.robots = append(r.robots, robotPosition{robotID, state})
+	go func() {
+		for state := range states {
+			r.mu.Lock()
+			r.robots[i].State = state
+			if r.printGrid {
+				renderGrid(r.out, r.size, r.robots)
+			}
+			r.mu.Unlock()
+			r.changed()
+		}
+	}()
+	return nil
+}
+
+// snapshot returns the robots watched, and the tasks queued within the session which have not been executed yet in the
+// order they were queued
+func (r *repl) snapshot() ([]robotPosition, []sessionTask) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	robots := append([]robotPosition(nil), r.robots...)
+	tasks := make([]sessionTask, 0, len(r.tasks))
+	for _, task := range r.tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].seq < tasks[j].seq })
+	return robots, tasks
+}
+
+// printf writes to the output in a concurrent-safe way
+func (r *repl) printf(format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.out, format, a...)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/cmdlang"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// fakeWarehouse is a warehouse of robots which execute tasks once released
+type fakeWarehouse struct {
+	mu     sync.Mutex
+	robots map[string]*fakeRobot
+}
+
+type fakeRobot struct {
+	mu       sync.Mutex
+	state    librobot.RobotState
+	next     *int32 // number of tasks queued in the warehouse, so task IDs are unique within the warehouse
+	queue    []fakeTask
+	watchers []chan librobot.RobotState
+	auto     bool // tasks are executed once queued
+}
+
+type fakeTask struct {
+	id       string
+	commands string
+	position chan librobot.RobotState
+	err      chan error
+}
+
+func newFakeWarehouse(ids ...string) *fakeWarehouse {
+	w := &fakeWarehouse{robots: make(map[string]*fakeRobot)}
+	next := new(int32)
+	for _, id := range ids {
+		w.robots[id] = &fakeRobot{next: next}
+	}
+	return w
+}
+
+func (w *fakeWarehouse) RobotIDs(ctx context.Context) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []string
+	for id := range w.robots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (w *fakeWarehouse) Robot(ctx context.Context, id string) (librobot.Robot, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	robot, ok := w.robots[id]
+	if !ok {
+		return nil, fmt.Errorf("robot '%s' not found", id)
+	}
+	return robot, nil
+}
+
+func (w *fakeWarehouse) Watch(ctx context.Context, id string) (<-chan librobot.RobotState, error) {
+	robot, err := w.Robot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	states := make(chan librobot.RobotState, 16)
+	r := robot.(*fakeRobot)
+	r.mu.Lock()
+	r.watchers = append(r.watchers, states)
+	r.mu.Unlock()
+	return states, nil
+}
+
+func (r *fakeRobot) EnqueueTask(commands string) (string, chan librobot.RobotState, chan error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	position, err := make(chan librobot.RobotState, 1), make(chan error, 1)
+	if commands == "reject" {
+		err <- errors.New("queue-full")
+		return "", position, err
+	}
+	// commands which are not delimited are rejected like the server does; other commands fail once executed
+	if _, e := cmdlang.Validate(commands); e != nil && e.(*cmdlang.Error).Reason == cmdlang.MultiLetterCommand {
+		err <- e
+		return "", position, err
+	}
+	id := fmt.Sprintf("t%d", atomic.AddInt32(r.next, 1))
+	r.queue = append(r.queue, fakeTask{id, commands, position, err})
+	if r.auto {
+		go r.execute()
+	}
+	return id, position, err
+}
+
+func (r *fakeRobot) CancelTask(taskID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, task := range r.queue {
+		if task.id == taskID {
+			r.queue = append(r.queue[:i], r.queue[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("task-finished")
+}
+
+func (r *fakeRobot) CurrentState() librobot.RobotState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// execute executes the next queued task: `N` and `E` commands move the robot, other commands fail
+func (r *fakeRobot) execute() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	task := r.queue[0]
+	r.queue = r.queue[1:]
+	state := r.state
+	for _, command := range strings.Fields(task.commands) {
+		switch command {
+		case "N":
+			state.Y++
+		case "E":
+			state.X++
+		default:
+			task.err <- fmt.Errorf("task %s failed: out-of-bounds", task.id)
+			return
+		}
+	}
+	r.state = state
+	for _, watcher := range r.watchers {
+		watcher <- state
+	}
+	task.position <- state
+}
+
+// syncBuffer is a buffer written by the goroutines of the REPL
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits for the output to contain a string
+func waitFor(t *testing.T, out *syncBuffer, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(out.String(), s) {
+			return
+		}
+	}
+	t.Errorf("output does not contain %q; got: %v", s, out.String())
+}
+
+func TestREPL(t *testing.T) {
+	t.Run("test task moves robot", func(t *testing.T) {
+		w := newFakeWarehouse("r1")
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), w, 3, out)
+		if err := r.watchRobots(); err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+
+		r.execute("r1 N E")
+		waitFor(t, out, "task t1 queued for r1")
+		w.robots["r1"].execute()
+		waitFor(t, out, "task t1: robot r1 at (1, 1)")
+		waitFor(t, out, "1 | . 1 . |")
+	})
+
+	t.Run("test failed task", func(t *testing.T) {
+		w := newFakeWarehouse("r1")
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), w, 3, out)
+
+		r.execute("r1 S")
+		w.robots["r1"].execute()
+		waitFor(t, out, "task t1 failed: out-of-bounds")
+	})
+
+	t.Run("test rejected task", func(t *testing.T) {
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), newFakeWarehouse("r1"), 3, out)
+
+		r.execute("r1 reject")
+		waitFor(t, out, "error: queue-full")
+	})
+
+	t.Run("test invalid command sequence is underlined", func(t *testing.T) {
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), newFakeWarehouse("r1"), 3, out)
+
+		r.execute("r1 N E  NE S")
+		waitFor(t, out, "invalid command 'NE', commands must be delimited by whitespace\n  N E NE S\n      ^^\n")
+	})
+
+	t.Run("test unknown robot", func(t *testing.T) {
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), newFakeWarehouse("r1"), 3, out)
+
+		r.execute("r2 N")
+		waitFor(t, out, "error: robot 'r2' not found")
+	})
+
+	t.Run("test cancel task", func(t *testing.T) {
+		w := newFakeWarehouse("r1")
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), w, 3, out)
+
+		r.execute("r1 N")
+		r.execute("tasks")
+		waitFor(t, out, `t1 r1 "N"`)
+		r.execute("cancel t1")
+		waitFor(t, out, "task t1 cancelled")
+		if len(w.robots["r1"].queue) != 0 {
+			t.Errorf("unexpected queued tasks; got: %v, want: 0", len(w.robots["r1"].queue))
+		}
+		r.execute("cancel t1")
+		waitFor(t, out, "error: task t1 is not pending")
+	})
+
+	t.Run("test quit", func(t *testing.T) {
+		r := newREPL(context.Background(), newFakeWarehouse(), 3, &syncBuffer{})
+		if quit := r.execute("quit"); !quit {
+			t.Errorf("unexpected quit; got: %v, want: %v", quit, true)
+		}
+		if quit := r.execute("help"); quit {
+			t.Errorf("unexpected quit; got: %v, want: %v", quit, false)
+		}
+	})
+
+	t.Run("test run until end of input", func(t *testing.T) {
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), newFakeWarehouse("r1", "r2"), 3, out)
+		if err := r.run(strings.NewReader("robots\n")); err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+		waitFor(t, out, "r1 (0, 0)\nr2 (0, 0)")
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("test simulator by default", func(t *testing.T) {
+		stdout, stderr := &syncBuffer{}, &bytes.Buffer{} // outcomes of tasks may be printed once the REPL exited
+		if code := run([]string{"--robots", "r1 r2@2,2", "--size", "3"}, strings.NewReader("r2 N\nr1 N N N\n"), stdout, stderr); code != 0 {
+			t.Errorf("unexpected exit code; got: %v, want: %v (%v)", code, 0, stderr.String())
+		}
+		for _, want := range []string{"task task-1 queued for r2", "task task-2 queued for r1"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("output does not contain %q; got: %v", want, stdout.String())
+			}
+		}
+	})
+
+	t.Run("test invalid simulated robots", func(t *testing.T) {
+		for _, robots := range []string{"", "r1 r1", "r1@1", "@1,1"} {
+			var stderr bytes.Buffer
+			if code := run([]string{"--robots", robots}, strings.NewReader(""), &bytes.Buffer{}, &stderr); code != 2 {
+				t.Errorf("unexpected exit code of --robots %q; got: %v, want: %v", robots, code, 2)
+			}
+			if !strings.Contains(stderr.String(), "--robots") {
+				t.Errorf("unexpected error of --robots %q; got: %v", robots, stderr.String())
+			}
+		}
+	})
+
+	t.Run("test invalid server URL", func(t *testing.T) {
+		if code := run([]string{"--server", "localhost:8000"}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+	})
+}
+
+// fakeLines are the lines read by a REPL; errors are returned instead of lines
+type fakeLines struct {
+	lines []interface{}
+}
+
+func (f *fakeLines) Prompt(string) (string, error) {
+	if len(f.lines) == 0 {
+		return "", io.EOF
+	}
+	line := f.lines[0]
+	f.lines = f.lines[1:]
+	if err, ok := line.(error); ok {
+		return "", err
+	}
+	return line.(string), nil
+}
+
+func TestREPLInterrupt(t *testing.T) {
+	t.Run("test interrupt cancels task of current robot", func(t *testing.T) {
+		w := newFakeWarehouse("r1", "r2")
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), w, 3, out)
+
+		err := r.runLines(&fakeLines{[]interface{}{"r2 N", "r1 E", "r1 N", errInterrupted, errInterrupted, errInterrupted, "tasks"}})
+		if err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+		waitFor(t, out, "task t2 cancelled\ntask t3 cancelled\n")
+		waitFor(t, out, "robot r1 has no pending task queued within the session")
+		waitFor(t, out, `t1 r2 "N"`)
+	})
+
+	t.Run("test interrupt without task", func(t *testing.T) {
+		out := &syncBuffer{}
+		r := newREPL(context.Background(), newFakeWarehouse("r1"), 3, out)
+		if err := r.runLines(&fakeLines{[]interface{}{errInterrupted}}); err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+		waitFor(t, out, "no task queued within the session to cancel")
+	})
+}
+
+func TestREPLHelp(t *testing.T) {
+	out := &syncBuffer{}
+	r := newREPL(context.Background(), newFakeWarehouse(), 3, out)
+
+	r.execute("help")
+	waitFor(t, out, "  cancel <task-id>    cancel a task queued within the session\n")
+	r.execute("help cancel")
+	waitFor(t, out, "usage: cancel <task-id>\nCancels a task")
+	r.execute("help task")
+	waitFor(t, out, "usage: <robot> <commands>\n")
+	waitFor(t, out, "e.g. 3(N E); # starts a comment")
+	r.execute("help move")
+	waitFor(t, out, "unknown command 'move'")
+}
+
+func TestREPLComplete(t *testing.T) {
+	w := newFakeWarehouse("r1", "r2", "rover")
+	r := newREPL(context.Background(), w, 3, &syncBuffer{})
+	if err := r.watchRobots(); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	r.execute("r1 N")
+	r.execute("r2 N")
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"r", []string{"robots", "r1", "r2", "rover"}},
+		{"ro", []string{"robots", "rover"}},
+		{"ca", []string{"cancel"}},
+		{"help g", []string{"help grid"}},
+		{"cancel ", []string{"cancel t1", "cancel t2"}},
+		{"cancel t2", []string{"cancel t2"}},
+		{"r1 N", nil},
+	}
+	for _, tt := range tests {
+		if got := r.complete(tt.line); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("unexpected completions of %q; got: %v, want: %v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+)
+
+// step kinds of a scenario
+const (
+	stepTask   = "task"   // <robot> <commands>
+	stepWait   = "wait"   // wait [task|all|<robot>]
+	stepSleep  = "sleep"  // sleep <duration>
+	stepExpect = "expect" // expect <robot> at <x>,<y> [carrying|empty] | expect task ok|failed [code]
+)
+
+// step is a line of a scenario
+type step struct {
+	line int    // line number within the scenario
+	text string // the line, without comment
+	kind string
+
+	robot    string        // task, wait (of a robot) and expect (of a robot)
+	commands string        // task
+	target   string        // wait: `task` (last task), `all` or `robot`; expect: `robot` or `task`
+	x, y     uint          // expect (of a robot)
+	crate    *bool         // expect (of a robot): whether the robot carries a crate; unchecked if nil
+	success  bool          // expect (of a task)
+	code     string        // expect (of a failed task): problem code; unchecked if empty
+	duration time.Duration // sleep
+}
+
+// parseScenario parses a scenario, one step per line; `#` starts a comment
+// - all syntax errors are reported (one per line), so scenarios can be fixed at once
+func parseScenario(r io.Reader) ([]step, error) {
+	var steps []step
+	var errs []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		s, err := parseStep(fields)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		s.line, s.text = n, strings.Join(fields, " ")
+		steps = append(steps, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return steps, nil
+}
+
+// parseStep parses the fields of a line of a scenario
+func parseStep(fields []string) (step, error) {
+	switch fields[0] {
+	case "crate":
+		// rather than a task of a robot named `crate`
+		return step{}, errors.New("crates are not supported; neither the simulator nor the robot server models crates")
+	case stepWait:
+		switch {
+		case len(fields) == 1:
+			return step{kind: stepWait, target: "all"}, nil
+		case len(fields) == 2 && (fields[1] == "task" || fields[1] == "all"):
+			return step{kind: stepWait, target: fields[1]}, nil
+		case len(fields) == 2:
+			return step{kind: stepWait, target: "robot", robot: fields[1]}, nil
+		}
+		return step{}, errors.New("usage: wait [task|all|<robot>]")
+	case stepSleep:
+		if len(fields) != 2 {
+			return step{}, errors.New("usage: sleep <duration>, e.g. sleep 3s")
+		}
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return step{}, fmt.Errorf("invalid duration '%s', e.g. 3s or 500ms", fields[1])
+		}
+		return step{kind: stepSleep, duration: d}, nil
+	case stepExpect:
+		return parseExpect(fields)
+	}
+	if len(fields) < 2 {
+		return step{}, fmt.Errorf("unknown step '%s'; usage: <robot> <commands>", fields[0])
+	}
+	return step{kind: stepTask, robot: fields[0], commands: strings.Join(fields[1:], " ")}, nil
+}
+
+// parseExpect parses an assertion: `expect <robot> at <x>,<y> [carrying|empty]` or `expect task ok|failed [code]`
+func parseExpect(fields []string) (step, error) {
+	if len(fields) >= 3 && fields[1] == "task" && (fields[2] == "ok" || fields[2] == "failed") {
+		s := step{kind: stepExpect, target: "task", success: fields[2] == "ok"}
+		switch {
+		case len(fields) == 4 && !s.success:
+			s.code = fields[3]
+		case len(fields) != 3:
+			return step{}, errors.New("usage: expect task ok|failed [code]")
+		}
+		return s, nil
+	}
+
+	if len(fields) < 4 || len(fields) > 5 || fields[2] != "at" {
+		return step{}, errors.New("usage: expect <robot> at <x>,<y> [carrying|empty], or expect task ok|failed [code]")
+	}
+	x, y, err := parseCell(fields[3])
+	if err != nil {
+		return step{}, err
+	}
+	s := step{kind: stepExpect, target: "robot", robot: fields[1], x: x, y: y}
+	if len(fields) == 5 {
+		if fields[4] != "carrying" && fields[4] != "empty" {
+			return step{}, fmt.Errorf("invalid crate expectation '%s'; one of 'carrying' or 'empty'", fields[4])
+		}
+		carrying := fields[4] == "carrying"
+		s.crate = &carrying
+	}
+	return s, nil
+}
+
+// parseCell parses the coordinates of a cell, e.g. `4,4`
+func parseCell(s string) (x uint, y uint, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) == 2 {
+		x, errX := strconv.ParseUint(parts[0], 10, 0)
+		y, errY := strconv.ParseUint(parts[1], 10, 0)
+		if errX == nil && errY == nil {
+			return uint(x), uint(y), nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid cell '%s', e.g. 4,4", s)
+}
+
+// scenarioTask is a task queued by a scenario
+type scenarioTask struct {
+	id    string
+	robot string
+	done  chan struct{} // closed once the task is executed (or failed)
+	state librobot.RobotState
+	err   error
+}
+
+// scenarioRunner executes the steps of a scenario against a warehouse, reporting each step
+type scenarioRunner struct {
+	warehouse warehouse
+	timeout   time.Duration // maximum time a step waits for tasks
+	out       io.Writer
+
+	tasks []*scenarioTask
+}
+
+// scenarioResult summarises the execution of a scenario
+type scenarioResult struct {
+	steps      int // steps executed
+	tasks      int // tasks queued
+	failed     int // tasks which failed
+	assertions int // assertions which passed
+	err        error
+	duration   time.Duration
+}
+
+// run executes the steps of a scenario until the first failed step: a failed assertion, a task which cannot be queued,
+// or a wait timing out
+// - tasks which fail are not failed steps (unless asserted with `expect task ok`), as scenarios may test failures
+func (s *scenarioRunner) run(ctx context.Context, steps []step) scenarioResult {
+	var res scenarioResult
+	started := time.Now()
+	for _, st := range steps {
+		res.steps++
+		detail, err := s.execute(ctx, st, &res)
+		if err != nil {
+			fmt.Fprintf(s.out, "FAIL line %d: %s: %v\n%s", st.line, st.text, err, underline(err, st.commands, "     "))
+			res.err = fmt.Errorf("line %d: %s: %w", st.line, st.text, err)
+			break
+		}
+		fmt.Fprintf(s.out, "ok   line %d: %s%s\n", st.line, st.text, detail)
+	}
+	for _, task := range s.tasks {
+		select {
+		case <-task.done:
+			if task.err != nil {
+				res.failed++
+			}
+		default:
+		}
+	}
+	res.duration = time.Since(started)
+	return res
+}
+
+// execute executes a step; returns the detail of the step reported alongside it
+func (s *scenarioRunner) execute(ctx context.Context, st step, res *scenarioResult) (string, error) {
+	switch st.kind {
+	case stepTask:
+		task, err := s.enqueue(ctx, st.robot, st.commands)
+		if err != nil {
+			return "", err
+		}
+		res.tasks++
+		return fmt.Sprintf(" (task %s)", task.id), nil
+	case stepWait:
+		return "", s.wait(ctx, st)
+	case stepSleep:
+		select {
+		case <-time.After(st.duration):
+			return "", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	case stepExpect:
+		if err := s.expect(ctx, st); err != nil {
+			return "", err
+		}
+		res.assertions++
+		return "", nil
+	}
+	return "", fmt.Errorf("unknown step '%s'", st.kind)
+}
+
+// enqueue queues a task, recording its outcome once it is executed
+func (s *scenarioRunner) enqueue(ctx context.Context, robotID string, commands string) (*scenarioTask, error) {
+	robot, err := s.warehouse.Robot(ctx, robotID)
+	if err != nil {
+		return nil, err
+	}
+	taskID, position, errs := robot.EnqueueTask(commands)
+	if taskID == "" {
+		return nil, <-errs
+	}
+
+	task := &scenarioTask{id: taskID, robot: robotID, done: make(chan struct{})}
+	s.tasks = append(s.tasks, task)
+	go func() {
+		select {
+		case task.state = <-position:
+		case task.err = <-errs:
+		}
+		close(task.done)
+	}()
+	return task, nil
+}
+
+// wait waits for the last task, the tasks of a robot or all tasks queued by the scenario to be executed
+func (s *scenarioRunner) wait(ctx context.Context, st step) error {
+	var tasks []*scenarioTask
+	switch st.target {
+	case "task":
+		if len(s.tasks) == 0 {
+			return errors.New("no task queued")
+		}
+		tasks = s.tasks[len(s.tasks)-1:]
+	case "robot":
+		for _, task := range s.tasks {
+			if task.robot == st.robot {
+				tasks = append(tasks, task)
+			}
+		}
+	default:
+		tasks = s.tasks
+	}
+
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	for _, task := range tasks {
+		select {
+		case <-task.done:
+		case <-timeout.C:
+			return fmt.Errorf("timed out after %s waiting for task %s of robot %s", s.timeout, task.id, task.robot)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// expect checks an assertion of the state of a robot, or of the outcome of the last task (waiting for it to be executed)
+func (s *scenarioRunner) expect(ctx context.Context, st step) error {
+	if st.target == "task" {
+		if err := s.wait(ctx, step{target: "task"}); err != nil {
+			return err
+		}
+		task := s.tasks[len(s.tasks)-1]
+		switch {
+		case st.success && task.err != nil:
+			return fmt.Errorf("task %s failed: %v", task.id, task.err)
+		case !st.success && task.err == nil:
+			return fmt.Errorf("task %s succeeded; robot %s at (%d, %d)", task.id, task.robot, task.state.X, task.state.Y)
+		case st.code != "" && codeOf(task.err) != st.code:
+			return fmt.Errorf("task %s failed with code '%s', want '%s': %v", task.id, codeOf(task.err), st.code, task.err)
+		}
+		return nil
+	}
+
+	robot, err := s.warehouse.Robot(ctx, st.robot)
+	if err != nil {
+		return err
+	}
+	state := robot.CurrentState()
+	if state.X != st.x || state.Y != st.y || (st.crate != nil && state.HasCrate != *st.crate) {
+		return fmt.Errorf("robot %s at (%d, %d)%s", st.robot, state.X, state.Y, carrying(state))
+	}
+	return nil
+}
+
+// carrying describes whether a robot carries a crate
+func carrying(state librobot.RobotState) string {
+	if state.HasCrate {
+		return " carrying a crate"
+	}
+	return " not carrying a crate"
+}
+
+// summary writes the summary of the execution of a scenario, e.g. for CI logs
+func (res scenarioResult) summary(w io.Writer, name string) {
+	status := "PASS"
+	if res.err != nil {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%s %s: %d steps, %d tasks (%d failed), %d assertions passed in %s\n",
+		status, name, res.steps, res.tasks, res.failed, res.assertions, res.duration.Round(time.Millisecond))
+}
+
+// codeOf returns the problem code of an error which has one, e.g. of the server or of the simulator
+func codeOf(err error) string {
+	var coded interface{ Code() string }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
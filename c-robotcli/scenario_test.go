@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScenario(t *testing.T) {
+	t.Run("test valid scenario", func(t *testing.T) {
+		steps, err := parseScenario(strings.NewReader(`
+# move r1 to the north east
+r1 N E N E   # comment
+wait task
+wait r1
+wait
+sleep 3s
+expect r1 at 4,4 carrying
+expect r1 at 4,4
+expect task ok
+expect task failed out-of-bounds
+`))
+		if err != nil {
+			t.Fatalf("unexpected error; got: %v, want: nil", err)
+		}
+		kinds := make([]string, len(steps))
+		for i, s := range steps {
+			kinds[i] = s.kind
+		}
+		if got, want := strings.Join(kinds, " "), "task wait wait wait sleep expect expect expect expect"; got != want {
+			t.Errorf("unexpected steps; got: %v, want: %v", got, want)
+		}
+		if s := steps[0]; s.line != 3 || s.robot != "r1" || s.commands != "N E N E" || s.text != "r1 N E N E" {
+			t.Errorf("unexpected task step; got: %+v", s)
+		}
+		if s := steps[4]; s.duration != 3*time.Second {
+			t.Errorf("unexpected sleep duration; got: %v, want: %v", s.duration, 3*time.Second)
+		}
+		if s := steps[5]; s.x != 4 || s.y != 4 || s.crate == nil || !*s.crate {
+			t.Errorf("unexpected expect step; got: %+v", s)
+		}
+		if s := steps[8]; s.success || s.code != "out-of-bounds" {
+			t.Errorf("unexpected expect step; got: %+v", s)
+		}
+	})
+
+	t.Run("test crates unsupported", func(t *testing.T) {
+		_, err := parseScenario(strings.NewReader("r1 N\ncrate add 1,1\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 2: crates are not supported") {
+			t.Errorf("unexpected error; got: %v, want: line 2: crates are not supported", err)
+		}
+	})
+
+	t.Run("test syntax errors of every line", func(t *testing.T) {
+		_, err := parseScenario(strings.NewReader("r1\nsleep soon\nexpect r1 at 4\ncrate move 1,1\n"))
+		if err == nil {
+			t.Fatalf("unexpected success")
+		}
+		for _, want := range []string{"line 1:", "line 2:", "line 3:", "line 4:"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("unexpected error; got: %v, want: %v", err, want)
+			}
+		}
+	})
+}
+
+// runSteps runs a scenario against a warehouse of robots executing tasks once queued
+func runSteps(t *testing.T, scenario string) (scenarioResult, string) {
+	steps, err := parseScenario(strings.NewReader(scenario))
+	if err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	w := newFakeWarehouse("r1", "r2")
+	for _, robot := range w.robots {
+		robot.auto = true
+	}
+	var out bytes.Buffer
+	res := (&scenarioRunner{warehouse: w, timeout: time.Second, out: &out}).run(context.Background(), steps)
+	return res, out.String()
+}
+
+func TestScenarioRunner(t *testing.T) {
+	t.Run("test passing scenario", func(t *testing.T) {
+		res, out := runSteps(t, "r1 N E\nr2 S\nwait\nexpect r1 at 1,1 empty\nexpect r2 at 0,0\nr2 N\nexpect task ok\nr2 W\nexpect task failed\n")
+		if res.err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil\n%s", res.err, out)
+		}
+		if res.steps != 9 || res.tasks != 4 || res.failed != 2 || res.assertions != 4 {
+			t.Errorf("unexpected result; got: %+v", res)
+		}
+		if !strings.Contains(out, "ok   line 1: r1 N E (task t1)") {
+			t.Errorf("unexpected report; got: %v", out)
+		}
+	})
+
+	t.Run("test stops at first failed assertion", func(t *testing.T) {
+		res, out := runSteps(t, "r1 N\nwait task\nexpect r1 at 1,1\nr1 N\n")
+		if res.err == nil || res.steps != 3 || res.tasks != 1 {
+			t.Errorf("unexpected result; got: %+v", res)
+		}
+		if want := "FAIL line 3: expect r1 at 1,1: robot r1 at (0, 1) not carrying a crate"; !strings.Contains(out, want) {
+			t.Errorf("unexpected report; got: %v, want: %v", out, want)
+		}
+	})
+
+	t.Run("test invalid command sequence is underlined", func(t *testing.T) {
+		res, out := runSteps(t, "r1 N E NE S\n")
+		if res.err == nil {
+			t.Fatalf("unexpected result; got: %+v, want: error", res)
+		}
+		if want := "FAIL line 1: r1 N E NE S: column 5: invalid command 'NE', commands must be delimited by whitespace\n     N E NE S\n         ^^\n"; out != want {
+			t.Errorf("unexpected report; got: %q, want: %q", out, want)
+		}
+	})
+
+	t.Run("test unknown robot", func(t *testing.T) {
+		res, _ := runSteps(t, "r3 N\n")
+		if res.err == nil || !strings.Contains(res.err.Error(), "robot 'r3' not found") {
+			t.Errorf("unexpected error; got: %v, want: robot 'r3' not found", res.err)
+		}
+	})
+
+	t.Run("test wait times out", func(t *testing.T) {
+		steps, _ := parseScenario(strings.NewReader("r1 N\nwait task\n"))
+		var out bytes.Buffer
+		res := (&scenarioRunner{warehouse: newFakeWarehouse("r1"), timeout: 10 * time.Millisecond, out: &out}).run(context.Background(), steps)
+		if res.err == nil || !strings.Contains(res.err.Error(), "timed out") {
+			t.Errorf("unexpected error; got: %v, want: timed out", res.err)
+		}
+	})
+
+	t.Run("test summary", func(t *testing.T) {
+		var out bytes.Buffer
+		scenarioResult{steps: 3, tasks: 1, assertions: 2, duration: time.Second}.summary(&out, "s.txt")
+		if want := "PASS s.txt: 3 steps, 1 tasks (0 failed), 2 assertions passed in 1s\n"; out.String() != want {
+			t.Errorf("unexpected summary; got: %v, want: %v", out.String(), want)
+		}
+	})
+}
+
+func TestRunScenario(t *testing.T) {
+	t.Run("test invalid scenario", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "scenario.txt")
+		os.WriteFile(name, []byte("expect r1\n"), 0o644)
+		var stderr bytes.Buffer
+		if code := run([]string{"run", "--server", "http://localhost:1", name}, nil, &bytes.Buffer{}, &stderr); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+		if !strings.Contains(stderr.String(), "line 1:") {
+			t.Errorf("unexpected error; got: %v, want: line 1", stderr.String())
+		}
+	})
+
+	t.Run("test scenario against simulator", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "scenario.txt")
+		os.WriteFile(name, []byte("r1 2(N E)\nwait\nexpect r1 at 2,2\nr1 S3\nexpect task failed out-of-bounds\n"), 0o644)
+		var stdout bytes.Buffer
+		if code := run([]string{"run", name}, nil, &stdout, &bytes.Buffer{}); code != 0 {
+			t.Errorf("unexpected exit code; got: %v, want: %v (%v)", code, 0, stdout.String())
+		}
+		if !strings.Contains(stdout.String(), "PASS "+name) {
+			t.Errorf("unexpected report; got: %v, want: PASS", stdout.String())
+		}
+	})
+
+	t.Run("test missing scenario", func(t *testing.T) {
+		if code := run([]string{"run", "--server", "http://localhost:1"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/peterh/liner"
+)
+
+// historyFileName is the name of the history file within the home directory of the user
+const historyFileName = ".robotcli_history"
+
+// terminalReader reads lines of a terminal with line editing, persistent history and tab completion
+// * implements lineReader
+type terminalReader struct {
+	state   *liner.State
+	history string // file the history is read from and written to; history is not persisted if empty
+}
+
+// newTerminalReader creates a reader of the terminal, reading the history of previous sessions from a file (if it exists)
+func newTerminalReader(history string, complete func(line string) []string) *terminalReader {
+	state := liner.NewLiner()
+	state.SetCtrlCAborts(true)
+	state.SetTabCompletionStyle(liner.TabPrints)
+	state.SetCompleter(complete)
+	if history != "" {
+		if f, err := os.Open(history); err == nil {
+			state.ReadHistory(f)
+			f.Close()
+		}
+	}
+	return &terminalReader{state: state, history: history}
+}
+
+// Prompt reads a line; non-empty lines are added to the history
+func (t *terminalReader) Prompt(prompt string) (string, error) {
+	line, err := t.state.Prompt(prompt)
+	if err == liner.ErrPromptAborted {
+		return "", errInterrupted
+	}
+	if err != nil {
+		return "", err
+	}
+	if line != "" {
+		t.state.AppendHistory(line)
+	}
+	return line, nil
+}
+
+// Close restores the terminal and writes the history (at most liner.HistoryLimit lines) to the history file
+func (t *terminalReader) Close() error {
+	defer t.state.Close()
+	if t.history == "" {
+		return nil
+	}
+	f, err := os.OpenFile(t.history, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer f.Close()
+	_, err = t.state.WriteHistory(f)
+	return err
+}
+
+// defaultHistoryFile returns the history file within the home directory of the user; empty if there is no home directory
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFileName)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+// tuiHelp is the title bar of the terminal UI, listing the keyboard shortcuts
+const tuiHelp = " robotcli | Tab/Shift-Tab: select robot | Ctrl-X: cancel task of robot | Esc/Ctrl-C: quit "
+
+// maxLogLines is the number of lines of the event log kept by the terminal UI
+const maxLogLines = 1000
+
+// tui is a full-screen terminal UI of a REPL
+// - the grid pane, the status pane of the robots (and their tasks queued within the session), the event log pane and the
+// input line are redrawn whenever the state of a robot changes, a line is logged, a key is pressed or the terminal is resized
+// - the input line accepts the commands of the REPL; commands without robot are tasks of the selected robot, e.g. `N E`
+type tui struct {
+	screen tcell.Screen
+	repl   *repl
+	log    *logBuffer
+
+	input    []rune
+	selected int           // index of the selected robot
+	redraw   chan struct{} // requests a redraw; buffered, so requests are coalesced
+}
+
+// newTUI creates a terminal UI of a REPL on a screen; the output of the REPL is written to the event log
+func newTUI(screen tcell.Screen, r *repl) *tui {
+	t := &tui{screen: screen, repl: r, redraw: make(chan struct{}, 1)}
+	t.log = &logBuffer{changed: t.requestRedraw}
+	r.out = t.log
+	r.printGrid = false
+	r.changed = t.requestRedraw
+	return t
+}
+
+// run watches the robots of the warehouse, then handles the events of the (initialized) screen until quit
+func (t *tui) run() error {
+	if err := t.repl.watchRobots(); err != nil {
+		return fmt.Errorf("failed to list robots: %w", err)
+	}
+	fmt.Fprintln(t.log, `Type "help" for commands.`)
+
+	events := make(chan tcell.Event)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		for {
+			event := t.screen.PollEvent()
+			if event == nil {
+				return // screen finalized
+			}
+			select {
+			case events <- event:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	t.draw()
+	for {
+		select {
+		case event := <-events:
+			if done := t.handle(event); done {
+				return nil
+			}
+		case <-t.redraw:
+		}
+		t.draw()
+	}
+}
+
+// handle handles an event of the screen; returns whether to quit
+func (t *tui) handle(event tcell.Event) (quit bool) {
+	switch event := event.(type) {
+	case *tcell.EventResize:
+		t.screen.Sync()
+	case *tcell.EventKey:
+		switch event.Key() {
+		case tcell.KeyEscape, tcell.KeyCtrlC:
+			return true
+		case tcell.KeyTab:
+			t.selectRobot(1)
+		case tcell.KeyBacktab:
+			t.selectRobot(-1)
+		case tcell.KeyCtrlX:
+			t.cancelSelected()
+		case tcell.KeyEnter:
+			line := string(t.input)
+			t.input = t.input[:0]
+			fmt.Fprintf(t.log, "> %s\n", line)
+			return t.repl.execute(t.command(line))
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		case tcell.KeyRune:
+			t.input = append(t.input, event.Rune())
+		}
+	}
+	return false
+}
+
+// command returns the REPL command of an input line: lines not starting with a command or robot are tasks of the selected robot
+func (t *tui) command(line string) string {
+	fields := strings.Fields(line)
+	robot, ok := t.selectedRobot()
+	if len(fields) == 0 || !ok {
+		return line
+	}
+	switch fields[0] {
+	case "?", "exit":
+		return line
+	}
+	for _, c := range replCommands {
+		if c.name == fields[0] {
+			return line
+		}
+	}
+	robots, _ := t.repl.snapshot()
+	for _, r := range robots {
+		if r.ID == fields[0] {
+			return line
+		}
+	}
+	return robot + " " + line
+}
+
+// selectRobot selects the next (or previous) robot
+func (t *tui) selectRobot(delta int) {
+	robots, _ := t.repl.snapshot()
+	if len(robots) == 0 {
+		return
+	}
+	t.selected = ((t.selected+delta)%len(robots) + len(robots)) % len(robots)
+}
+
+// selectedRobot returns the ID of the selected robot
+func (t *tui) selectedRobot() (string, bool) {
+	robots, _ := t.repl.snapshot()
+	if t.selected >= len(robots) {
+		return "", false
+	}
+	return robots[t.selected].ID, true
+}
+
+// cancelSelected cancels the current task of the selected robot
+func (t *tui) cancelSelected() {
+	if robot, ok := t.selectedRobot(); ok {
+		t.repl.cancelRobotTask(robot)
+	}
+}
+
+// requestRedraw requests the screen to be redrawn; concurrent-safe
+func (t *tui) requestRedraw() {
+	select {
+	case t.redraw <- struct{}{}:
+	default:
+	}
+}
+
+// draw draws the panes and the input line
+// - the grid pane is at the top left, the status pane at the top right, the event log below and the input line at the bottom
+func (t *tui) draw() {
+	t.screen.Clear()
+	width, height := t.screen.Size()
+	robots, tasks := t.repl.snapshot()
+	title := tcell.StyleDefault.Reverse(true)
+	bold := tcell.StyleDefault.Bold(true)
+
+	drawText(t.screen, 0, 0, width, title, tuiHelp+strings.Repeat(" ", width))
+
+	// grid pane
+	var grid strings.Builder
+	renderGrid(&grid, t.repl.size, robots)
+	gridLines := strings.Split(strings.TrimSuffix(grid.String(), "\n"), "\n")
+	if len(robots) > 0 {
+		gridLines = gridLines[:len(gridLines)-1] // the legend is part of the status pane
+	}
+	gridWidth := 0
+	for i, line := range gridLines {
+		drawText(t.screen, 0, 1+i, width, tcell.StyleDefault, line)
+		if len(line) > gridWidth {
+			gridWidth = len(line)
+		}
+	}
+
+	// status pane
+	x, y := gridWidth+2, 1
+	drawText(t.screen, x, y, width-x, bold, "Robots")
+	for i, robot := range robots {
+		y++
+		pending := 0
+		for _, task := range tasks {
+			if task.Robot == robot.ID {
+				pending++
+			}
+		}
+		style, marker := tcell.StyleDefault, " "
+		if i == t.selected {
+			style, marker = style.Reverse(true), ">"
+		}
+		drawText(t.screen, x, y, width-x, style, fmt.Sprintf("%s%c %s (%d, %d)%s %d queued", marker, label(i), robot.ID, robot.State.X, robot.State.Y, crateMarker(robot.State.HasCrate), pending))
+	}
+	if robot, ok := t.selectedRobot(); ok {
+		y += 2
+		drawText(t.screen, x, y, width-x, bold, "Tasks of "+robot)
+		for _, task := range tasks {
+			if task.Robot == robot {
+				y++
+				drawText(t.screen, x, y, width-x, tcell.StyleDefault, fmt.Sprintf("%s \"%s\"", task.ID, task.Commands))
+			}
+		}
+	}
+
+	// event log pane: the most recent lines fitting between the panes and the input line
+	top := 1 + len(gridLines)
+	if y+1 > top {
+		top = y + 1
+	}
+	drawText(t.screen, 0, top, width, bold, "Events"+strings.Repeat("─", width))
+	lines := t.log.lines()
+	rows := height - 2 - top
+	if rows < 0 {
+		rows = 0
+	}
+	if len(lines) > rows {
+		lines = lines[len(lines)-rows:]
+	}
+	for i, line := range lines {
+		drawText(t.screen, 0, top+1+i, width, tcell.StyleDefault, line)
+	}
+
+	// input line
+	prompt := "> " + string(t.input)
+	drawText(t.screen, 0, height-1, width, tcell.StyleDefault, prompt)
+	t.screen.ShowCursor(runewidth.StringWidth(prompt), height-1)
+	t.screen.Show()
+}
+
+// crateMarker marks robots carrying a crate
+func crateMarker(hasCrate bool) string {
+	if hasCrate {
+		return "*"
+	}
+	return ""
+}
+
+// drawText draws a line of text at a position, clipped to a width
+func drawText(screen tcell.Screen, x int, y int, width int, style tcell.Style, text string) {
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if w > width {
+			return
+		}
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+		width -= w
+	}
+}
+
+// logBuffer is the event log of the terminal UI; the output of the REPL is written to it
+// * implements io.Writer
+type logBuffer struct {
+	changed func() // called whenever a line is logged
+
+	mu      sync.Mutex
+	logged  []string
+	partial string // last line, until terminated
+}
+
+// Write appends the lines of p to the log in a concurrent-safe way; at most maxLogLines are kept
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	lines := strings.Split(b.partial+string(p), "\n")
+	b.partial = lines[len(lines)-1]
+	b.logged = append(b.logged, lines[:len(lines)-1]...)
+	if len(b.logged) > maxLogLines {
+		b.logged = append([]string(nil), b.logged[len(b.logged)-maxLogLines:]...)
+	}
+	b.mu.Unlock()
+
+	b.changed()
+	return len(p), nil
+}
+
+// lines returns the lines of the log
+func (b *logBuffer) lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.logged...)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// lockedScreen is a simulated screen which can be read while the terminal UI draws it
+// - the contents of a simulated screen are the cells the screen draws (not a copy), so reading and drawing are serialized
+type lockedScreen struct {
+	tcell.SimulationScreen
+	mu sync.Mutex
+}
+
+func (s *lockedScreen) Show() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SimulationScreen.Show()
+}
+
+func (s *lockedScreen) Sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.SimulationScreen.Sync()
+}
+
+// resize resizes the screen, notifying the terminal UI
+func (s *lockedScreen) resize(width int, height int) {
+	s.mu.Lock()
+	s.SetSize(width, height)
+	s.mu.Unlock()
+	s.PostEvent(tcell.NewEventResize(width, height))
+}
+
+// text returns the text of the screen, one line per row
+func (s *lockedScreen) text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cells, width, _ := s.GetContents()
+	var b strings.Builder
+	for i, cell := range cells {
+		if len(cell.Runes) > 0 {
+			b.WriteRune(cell.Runes[0])
+		} else {
+			b.WriteByte(' ')
+		}
+		if (i+1)%width == 0 {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// size returns the size of the contents of the screen
+func (s *lockedScreen) size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, width, height := s.GetContents()
+	return width, height
+}
+
+// startTUI runs a terminal UI of a warehouse on a simulated screen (resized to 100x30); returns a channel receiving the result of run
+func startTUI(t *testing.T, w *fakeWarehouse) (*lockedScreen, chan error) {
+	screen := &lockedScreen{SimulationScreen: tcell.NewSimulationScreen("")}
+	if err := screen.Init(); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	ui := newTUI(screen, newREPL(context.Background(), w, 5, nil))
+	done := make(chan error, 1)
+	go func() {
+		done <- ui.run()
+		screen.Fini()
+	}()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(screen.text(), "Events") {
+			screen.resize(100, 30)
+			return screen, done
+		}
+	}
+	t.Fatalf("terminal UI not drawn")
+	return nil, nil
+}
+
+// waitForScreen waits for the screen to contain a string
+func waitForScreen(t *testing.T, screen *lockedScreen, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(screen.text(), s) {
+			return
+		}
+	}
+	t.Errorf("screen does not contain %q; got:\n%v", s, screen.text())
+}
+
+// typeLine types a line into the input line and submits it
+func typeLine(screen *lockedScreen, line string) {
+	for _, r := range line {
+		screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	screen.InjectKey(tcell.KeyEnter, 0, tcell.ModNone)
+}
+
+func TestTUI(t *testing.T) {
+	t.Run("test task of selected robot", func(t *testing.T) {
+		w := newFakeWarehouse("r1", "r2")
+		w.robots["r1"].auto = true
+		screen, done := startTUI(t, w)
+
+		waitForScreen(t, screen, ">1 r1 (0, 0) 0 queued")
+		typeLine(screen, "N E")
+		waitForScreen(t, screen, "task t1: robot r1 at (1, 1)")
+		waitForScreen(t, screen, "1 | . 1 . . . |")
+		waitForScreen(t, screen, ">1 r1 (1, 1) 0 queued")
+
+		screen.InjectKey(tcell.KeyEscape, 0, tcell.ModNone)
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+	})
+
+	t.Run("test select robot and cancel its task", func(t *testing.T) {
+		w := newFakeWarehouse("r1", "r2")
+		screen, done := startTUI(t, w)
+
+		screen.InjectKey(tcell.KeyTab, 0, tcell.ModNone)
+		waitForScreen(t, screen, ">2 r2 (0, 0) 0 queued")
+		typeLine(screen, "r1 N")
+		typeLine(screen, "N N")
+		waitForScreen(t, screen, ">2 r2 (0, 0) 1 queued")
+		waitForScreen(t, screen, `t2 "N N"`)
+
+		screen.InjectKey(tcell.KeyCtrlX, 0, tcell.ModNone)
+		waitForScreen(t, screen, "task t2 cancelled")
+		waitForScreen(t, screen, ">2 r2 (0, 0) 0 queued")
+		screen.InjectKey(tcell.KeyCtrlX, 0, tcell.ModNone)
+		waitForScreen(t, screen, "robot r2 has no pending task")
+
+		screen.InjectKey(tcell.KeyBacktab, 0, tcell.ModNone)
+		waitForScreen(t, screen, ">1 r1 (0, 0) 1 queued")
+
+		typeLine(screen, "quit")
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error; got: %v, want: nil", err)
+		}
+	})
+
+	t.Run("test resize", func(t *testing.T) {
+		screen, done := startTUI(t, newFakeWarehouse("r1"))
+
+		screen.resize(60, 20)
+		waitForScreen(t, screen, "Events"+strings.Repeat("─", 54))
+		if width, height := screen.size(); width != 60 || height != 20 {
+			t.Errorf("unexpected size; got: %vx%v, want: 60x20", width, height)
+		}
+		waitForScreen(t, screen, ">1 r1 (0, 0) 0 queued")
+
+		screen.InjectKey(tcell.KeyCtrlC, 0, tcell.ModNone)
+		<-done
+	})
+}
+
+func TestLogBuffer(t *testing.T) {
+	changed := 0
+	b := &logBuffer{changed: func() { changed++ }}
+	b.Write([]byte("a\nb"))
+	b.Write([]byte("c\n"))
+	if got := strings.Join(b.lines(), "|"); got != "a|bc" {
+		t.Errorf("unexpected lines; got: %v, want: %v", got, "a|bc")
+	}
+	if changed != 2 {
+		t.Errorf("unexpected changes; got: %v, want: %v", changed, 2)
+	}
+
+	for i := 0; i < maxLogLines+10; i++ {
+		b.Write([]byte("line\n"))
+	}
+	if got := len(b.lines()); got != maxLogLines {
+		t.Errorf("unexpected number of lines; got: %v, want: %v", got, maxLogLines)
+	}
+}
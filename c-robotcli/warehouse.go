@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/client"
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+	"github.com/zees-dev/robot-challenge/b-librobot/session"
+)
+
+// warehouse is the warehouse operated by the CLI
+type warehouse interface {
+	// RobotIDs lists the IDs of the robots of the warehouse in order
+	RobotIDs(ctx context.Context) ([]string, error)
+	// Robot looks up a robot of the warehouse
+	Robot(ctx context.Context, id string) (librobot.Robot, error)
+	// Watch sends the state of a robot whenever it changes until the context is done; the channel is closed then
+	Watch(ctx context.Context, id string) (<-chan librobot.RobotState, error)
+}
+
+// remoteWarehouse is the warehouse of a robot server (a-restful), operated over its RESTful API
+// - tasks are queued over HTTP; states are received from the server-sent events of the robots
+// * implements warehouse
+type remoteWarehouse struct {
+	client *client.Client
+}
+
+// RobotIDs lists the robots of the server
+func (w remoteWarehouse) RobotIDs(ctx context.Context) ([]string, error) {
+	robots, err := w.client.ListRobots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(robots))
+	for i, robot := range robots {
+		ids[i] = robot.ID()
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Robot looks up a robot of the server; fails with the `robot-not-found` problem if it does not exist
+func (w remoteWarehouse) Robot(ctx context.Context, id string) (librobot.Robot, error) {
+	robot := w.client.Robot(id)
+	if _, err := robot.State(ctx); err != nil {
+		return nil, err
+	}
+	return robot, nil
+}
+
+// Watch watches the state of a robot of the server
+func (w remoteWarehouse) Watch(ctx context.Context, id string) (<-chan librobot.RobotState, error) {
+	return w.client.Robot(id).Watch(ctx)
+}
+
+// simulatedWarehouse is the in-process simulator of the simulator library (see `session.Simulator`), the default warehouse
+// - tasks are performed by the simulated robots; robots do not collide, and batteries and crates are not modelled
+// * implements warehouse
+type simulatedWarehouse struct {
+	simulator *session.Simulator
+	ids       []string // IDs of the robots of the simulator, in order
+}
+
+// RobotIDs lists the robots of the simulator
+func (w simulatedWarehouse) RobotIDs(ctx context.Context) ([]string, error) {
+	return append([]string(nil), w.ids...), nil
+}
+
+// Robot looks up a robot of the simulator; fails with `robot-not-found` if it does not exist
+func (w simulatedWarehouse) Robot(ctx context.Context, id string) (librobot.Robot, error) {
+	return w.simulator.Robot(id)
+}
+
+// Watch watches the state of a robot of the simulator
+func (w simulatedWarehouse) Watch(ctx context.Context, id string) (<-chan librobot.RobotState, error) {
+	return w.simulator.Watch(ctx, id)
+}
+
+// recordingWarehouse is a warehouse whose robots record the tasks queued and cancelled, and their outcomes
+// - crates are not recorded, as the warehouses of the CLI do not model crates
+// * implements warehouse
+type recordingWarehouse struct {
+	warehouse
+	recorder *session.Recorder
+}
+
+// Robot looks up a robot of the warehouse, recording it when first used
+func (w recordingWarehouse) Robot(ctx context.Context, id string) (librobot.Robot, error) {
+	robot, err := w.warehouse.Robot(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return w.recorder.Robot(id, robot), nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zees-dev/robot-challenge/b-librobot/librobot"
+	"github.com/zees-dev/robot-challenge/b-librobot/session"
+)
+
+func TestRecordingWarehouse(t *testing.T) {
+	var recording syncBuffer
+	w := newFakeWarehouse("r1", "r2")
+	w.robots["r1"].auto = true
+	var out syncBuffer
+	r := newREPL(context.Background(), recordingWarehouse{w, session.NewRecorder(&recording)}, 5, &out)
+	if err := r.watchRobots(); err != nil {
+		t.Fatalf("unexpected error; got: %v, want: nil", err)
+	}
+	r.execute("r1 N E")
+	waitFor(t, &out, "task t1: robot r1 at (1, 1)")
+	r.execute("r1 N N N X")
+	waitFor(t, &out, "task t2 failed")
+	r.execute("r2 N")
+	r.execute("cancel t3")
+	waitFor(t, &out, "task t3 cancelled")
+
+	// outcomes are recorded asynchronously
+	var entries []session.Entry
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline) && len(entries) != 8; time.Sleep(10 * time.Millisecond) {
+		entries, _ = session.Read(strings.NewReader(recording.String()))
+	}
+	var kinds []string
+	for _, entry := range entries {
+		kinds = append(kinds, entry.Kind+" "+entry.Robot)
+	}
+	if got, want := strings.Join(kinds, ", "), "robot r1, robot r2, task r1, outcome r1, task r1, outcome r1, task r2, cancel r2"; got != want {
+		t.Fatalf("unexpected entries; got: %v, want: %v", got, want)
+	}
+
+	t.Run("test replay", func(t *testing.T) {
+		replayed := newFakeWarehouse("r1", "r2")
+		replayed.robots["r1"].auto = true
+		replayer := &session.Replayer{
+			Robot:   func(id string) (librobot.Robot, error) { return replayed.Robot(context.Background(), id) },
+			Timeout: time.Second,
+		}
+		report := replayer.Replay(context.Background(), entries)
+		if report.Divergence != nil || report.Tasks != 3 || report.Outcomes != 2 {
+			t.Errorf("unexpected report; got: %+v (%v), want: 3 tasks, 2 outcomes", report, report.Divergence)
+		}
+	})
+
+	t.Run("test replay diverging", func(t *testing.T) {
+		replayed := newFakeWarehouse("r1", "r2")
+		replayed.robots["r1"].auto = true
+		replayed.robots["r1"].state = librobot.RobotState{X: 1, Y: 1}
+		replayer := &session.Replayer{
+			Robot:   func(id string) (librobot.Robot, error) { return replayed.Robot(context.Background(), id) },
+			Timeout: time.Second,
+		}
+		report := replayer.Replay(context.Background(), entries)
+		if d := report.Divergence; d == nil || d.Index != 0 || d.Got != "(1, 1)" {
+			t.Errorf("unexpected divergence; got: %v, want: entry 1 got (1, 1)", d)
+		}
+	})
+}
+
+func TestRunReplay(t *testing.T) {
+	t.Run("test invalid recording", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "session.jsonl")
+		os.WriteFile(name, []byte("r1 N\n"), 0o644)
+		var stderr bytes.Buffer
+		if code := run([]string{"replay", "--server", "http://localhost:1", name}, nil, &bytes.Buffer{}, &stderr); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+		if !strings.Contains(stderr.String(), "invalid recording") {
+			t.Errorf("unexpected error; got: %v, want: invalid recording", stderr.String())
+		}
+	})
+
+	t.Run("test replay without server", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "session.jsonl")
+		os.WriteFile(name, []byte(`{"seq":1,"at":0,"kind":"robot","robot":"r1","state":{"x":0,"y":0}}
+{"seq":2,"at":10,"kind":"task","robot":"r1","task":"t1","commands":"N E"}
+{"seq":3,"at":210,"kind":"outcome","robot":"r1","task":"t1","state":{"x":1,"y":1}}
+{"seq":4,"at":220,"kind":"task","robot":"r1","task":"t2","commands":"N4"}
+{"seq":5,"at":230,"kind":"outcome","robot":"r1","task":"t2","error":"out of bounds","code":"out-of-bounds"}
+`), 0o644)
+		var stdout bytes.Buffer
+		if code := run([]string{"replay", "--size", "5", name}, nil, &stdout, &bytes.Buffer{}); code != 0 {
+			t.Errorf("unexpected exit code; got: %v, want: %v (%v)", code, 0, stdout.String())
+		}
+		if !strings.HasPrefix(stdout.String(), "PASS") {
+			t.Errorf("unexpected report; got: %v, want: PASS", stdout.String())
+		}
+
+		stdout.Reset()
+		if code := run([]string{"replay", name}, nil, &stdout, &bytes.Buffer{}); code != 1 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 1)
+		}
+		if !strings.Contains(stdout.String(), "want failure (out-of-bounds), got robot at (1, 5)") {
+			t.Errorf("unexpected divergence; got: %v", stdout.String())
+		}
+
+		var stderr bytes.Buffer
+		if code := run([]string{"replay", "--record", filepath.Join(t.TempDir(), "replay.jsonl"), name}, nil, &bytes.Buffer{}, &stderr); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+	})
+
+	t.Run("test missing recording", func(t *testing.T) {
+		if code := run([]string{"replay", "--server", "http://localhost:1"}, nil, &bytes.Buffer{}, &bytes.Buffer{}); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+	})
+}
+
+func TestRunExport(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "session.jsonl")
+	os.WriteFile(name, []byte(`{"at":0,"kind":"robot","robot":"r1","state":{"x":0,"y":0}}
+{"at":10,"kind":"task","robot":"r1","task":"t1","commands":"N E"}
+{"at":210,"kind":"outcome","robot":"r1","task":"t1","state":{"x":1,"y":1}}
+`), 0o644)
+
+	t.Run("test svg to stdout", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if code := run([]string{"export", "--size", "4", name}, nil, &stdout, &bytes.Buffer{}); code != 0 {
+			t.Fatalf("unexpected exit code; got: %v, want: %v", code, 0)
+		}
+		if !strings.HasPrefix(stdout.String(), "<svg") || !strings.Contains(stdout.String(), `values="50 170;50 170;50 170;50 130;50 130;90 130;90 130"`) {
+			t.Errorf("unexpected SVG; got:\n%v", stdout.String())
+		}
+	})
+
+	t.Run("test gif file", func(t *testing.T) {
+		output := filepath.Join(dir, "session.gif")
+		if code := run([]string{"export", "--output", output, name}, nil, &bytes.Buffer{}, &bytes.Buffer{}); code != 0 {
+			t.Fatalf("unexpected exit code; got: %v, want: %v", code, 0)
+		}
+		if b, _ := os.ReadFile(output); !bytes.HasPrefix(b, []byte("GIF89a")) {
+			t.Errorf("unexpected GIF; got: %.10q", b)
+		}
+	})
+
+	t.Run("test invalid format", func(t *testing.T) {
+		var stderr bytes.Buffer
+		if code := run([]string{"export", "--format", "png", name}, nil, &bytes.Buffer{}, &stderr); code != 2 {
+			t.Errorf("unexpected exit code; got: %v, want: %v", code, 2)
+		}
+		if !strings.Contains(stderr.String(), "invalid format 'png'") {
+			t.Errorf("unexpected error; got: %v, want: invalid format 'png'", stderr.String())
+		}
+	})
+}